@@ -1,26 +1,82 @@
 package assistant
 
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
 const (
 	RoleSystem    = "system"
 	RoleUser      = "user"
 	RoleAssistant = "assistant"
+	RoleTool      = "tool"
 )
 
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
 }
 
 type Usage struct {
 	PromptTokens     int
 	CompletionTokens int
 	TotalTokens      int
+	// CachedTokens is the part of PromptTokens served from the provider's
+	// prompt cache.
+	CachedTokens int
+	// Cost is the estimated cost in USD. It is set by Assistant when a price
+	// table is configured on its UsageTracker.
+	Cost float64
+	// Model is set by clients that served the request with a model other
+	// than the one requested, such as a fallback route. Assistant records
+	// and prices the usage under it.
+	Model string `json:",omitempty"`
 }
 
 type HttpClient interface {
 	Request(model string, msgs []Message) (msg Message, usage Usage, err error)
 }
 
+// ContextHttpClient is implemented by clients that accept a context for
+// cancellation and deadlines. Assistant prefers it over HttpClient.Request.
+type ContextHttpClient interface {
+	RequestContext(ctx context.Context, model string, msgs []Message, opts RequestOptions) (msg Message, usage Usage, err error)
+}
+
+// StreamHttpClient is implemented by clients that can deliver the response
+// incrementally. onDelta is called for every content fragment in order;
+// returning an error from it aborts the stream. The assembled message and the
+// final usage are returned once the stream ends.
+type StreamHttpClient interface {
+	RequestStream(ctx context.Context, model string, msgs []Message, opts RequestOptions, onDelta func(delta string) error) (msg Message, usage Usage, err error)
+}
+
+// StatefulHttpClient is implemented by clients whose API keeps the
+// conversation on the server, such as the OpenAI Responses API. msgs are sent
+// as the continuation of the response previousResponseID, or as the whole
+// conversation when it is empty. The returned responseID continues the
+// conversation on the next call. An expired previous response is reported
+// with an error matching ErrResponseNotFound.
+type StatefulHttpClient interface {
+	RequestStateful(ctx context.Context, model string, previousResponseID string, msgs []Message, opts RequestOptions) (msg Message, usage Usage, responseID string, err error)
+}
+
+var (
+	// ErrThreadNotFound is matched by errors.Is when a repository is asked
+	// for a thread that does not exist.
+	ErrThreadNotFound = errors.New("thread not found")
+	// ErrThreadExists is matched by errors.Is when a repository is asked to
+	// create a thread that already exists.
+	ErrThreadExists = errors.New("thread already exists")
+)
+
+// ThreadRepository stores threads of messages. GetMessages returns messages in
+// the order they were appended. Operations on an unknown thread fail with an
+// error matching ErrThreadNotFound, and creating an existing thread fails
+// with one matching ErrThreadExists.
 type ThreadRepository interface {
 	ThreadExists(tid string) (bool, error)
 	CreateThread(tid string) error
@@ -28,75 +84,243 @@ type ThreadRepository interface {
 	GetMessages(tid string) ([]Message, error)
 }
 
+// ThreadMetadataRepository is implemented by repositories that can store
+// key/value metadata alongside a thread. Features that keep per-thread state,
+// such as conversation summaries, require it.
+type ThreadMetadataRepository interface {
+	GetMetadata(tid string, key string) (value string, ok bool, err error)
+	SetMetadata(tid string, key string, value string) error
+}
+
+// ContextThreadMetadataRepository is implemented by metadata repositories that
+// accept a context. Assistant prefers it over the ThreadMetadataRepository
+// methods.
+type ContextThreadMetadataRepository interface {
+	GetMetadataContext(ctx context.Context, tid string, key string) (value string, ok bool, err error)
+	SetMetadataContext(ctx context.Context, tid string, key string, value string) error
+}
+
+// ContextThreadRepository is implemented by repositories that accept a context.
+// Assistant prefers it over the ThreadRepository methods.
+type ContextThreadRepository interface {
+	ThreadExistsContext(ctx context.Context, tid string) (bool, error)
+	CreateThreadContext(ctx context.Context, tid string) error
+	AppendMessageContext(ctx context.Context, tid string, msg Message) error
+	GetMessagesContext(ctx context.Context, tid string) ([]Message, error)
+}
+
+// Assistant is safe for concurrent use. Turns on the same thread are
+// serialised so their messages never interleave; turns on different threads
+// run in parallel.
 type Assistant struct {
-	model   string
-	system  string
-	client  HttpClient
-	threads ThreadRepository
-	usage   Usage
+	model             string
+	system            string
+	client            HttpClient
+	threads           ThreadRepository
+	usage             Usage
+	tools             []Tool
+	maxToolIterations int
+	maxDecodeRetries  int
+	options           RequestOptions
+	strategy          ContextStrategy
+	summarizer        *Summarizer
+	tracker           *UsageTracker
+	budget            Budget
+	serverState       bool
+	threadLocks       *keyedMutex
+
+	// mu guards the settings above and usage.
+	mu sync.RWMutex
 }
 
-func NewAssistant(model string, system string, client HttpClient, threads ThreadRepository) *Assistant {
+// NewAssistant creates an assistant. The optional request options are applied
+// to every request and may be overridden per call.
+func NewAssistant(model string, system string, client HttpClient, threads ThreadRepository, opts ...RequestOption) *Assistant {
 	return &Assistant{
-		model:   model,
-		system:  system,
-		client:  client,
-		threads: threads,
-		usage:   Usage{},
+		model:             model,
+		system:            system,
+		client:            client,
+		threads:           threads,
+		usage:             Usage{},
+		maxToolIterations: DefaultMaxToolIterations,
+		maxDecodeRetries:  DefaultMaxDecodeRetries,
+		options:           applyOptions(RequestOptions{}, opts),
+		tracker:           &UsageTracker{snapshot: newUsageSnapshot()},
+		threadLocks:       newKeyedMutex(),
 	}
 }
 
-func (a *Assistant) Ask(tid string, msg string) (string, error) {
-	if err := a.getThread(tid); err != nil {
-		return "", err
-	}
-
-	if err := a.threads.AppendMessage(tid, Message{Role: RoleUser, Content: msg}); err != nil {
-		return "", err
-	}
+func (a *Assistant) Ask(tid string, msg string, opts ...RequestOption) (string, error) {
+	return a.AskContext(context.Background(), tid, msg, opts...)
+}
 
-	messages, err := a.threads.GetMessages(tid)
+// AskContext is like Ask but aborts the thread lookup, storage calls and the
+// in-flight API request when ctx is cancelled or its deadline passes.
+func (a *Assistant) AskContext(ctx context.Context, tid string, msg string, opts ...RequestOption) (string, error) {
+	unlock, err := a.threadLocks.Lock(ctx, tid)
 	if err != nil {
 		return "", err
 	}
+	defer unlock()
 
-	response, usage, err := a.client.Request(a.model, messages)
+	messages, err := a.prepare(ctx, tid, msg)
 	if err != nil {
 		return "", err
 	}
 
-	a.usage = usage
-
-	if err := a.threads.AppendMessage(tid, response); err != nil {
+	response, err := a.converse(ctx, tid, messages, a.requestOptions(opts), a.sender(tid))
+	if err != nil && !errors.Is(err, ErrResponseStateNotStored) {
 		return "", err
 	}
 
-	return response.Content, nil
+	return response.Content, err
 }
 
 func (a *Assistant) GetMessages(tid string) ([]Message, error) {
-	return a.threads.GetMessages(tid)
+	return a.GetMessagesContext(context.Background(), tid)
+}
+
+func (a *Assistant) GetMessagesContext(ctx context.Context, tid string) ([]Message, error) {
+	return a.getMessages(ctx, tid)
 }
 
+// GetUsage returns the usage of the most recent Ask. Accumulated totals are
+// available from GetThreadUsage and GetUsageSnapshot.
 func (a *Assistant) GetUsage() Usage {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
 	return a.usage
 }
 
-func (a *Assistant) getThread(tid string) error {
-	exists, err := a.threads.ThreadExists(tid)
+// prepare makes sure the thread exists, appends the user message and returns
+// the messages to be sent to the model.
+func (a *Assistant) prepare(ctx context.Context, tid string, msg string) ([]Message, error) {
+	if err := a.checkBudget(tid); err != nil {
+		return nil, err
+	}
+
+	if err := a.getThread(ctx, tid); err != nil {
+		return nil, err
+	}
+
+	if err := a.appendMessage(ctx, tid, Message{Role: RoleUser, Content: msg}); err != nil {
+		return nil, err
+	}
+
+	messages, err := a.getMessages(ctx, tid)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.summarize(ctx, tid, messages)
+}
+
+// requestOptions combines the assistant defaults, the registered tools and
+// the per-call options, in that order of precedence.
+func (a *Assistant) requestOptions(opts []RequestOption) RequestOptions {
+	a.mu.RLock()
+	options := a.options
+	a.mu.RUnlock()
+
+	if tools := a.toolDefinitions(); tools != nil {
+		options.Tools = tools
+	}
+	return applyOptions(options, opts)
+}
+
+func (a *Assistant) getThread(ctx context.Context, tid string) error {
+	exists, err := a.threadExists(ctx, tid)
 	if err != nil {
 		return err
 	}
 	if exists {
 		return nil
 	}
-	return a.createThread(tid)
+	return a.createThread(ctx, tid)
+}
+
+func (a *Assistant) createThread(ctx context.Context, tid string) error {
+	if r, ok := a.threads.(ContextThreadRepository); ok {
+		if err := r.CreateThreadContext(ctx, tid); err != nil {
+			return err
+		}
+	} else {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := a.threads.CreateThread(tid); err != nil {
+			return err
+		}
+	}
+
+	return a.appendMessage(ctx, tid, Message{Role: RoleSystem, Content: a.system})
+}
+
+func (a *Assistant) request(ctx context.Context, msgs []Message, opts RequestOptions) (Message, Usage, error) {
+	return a.requestModel(ctx, a.model, msgs, opts)
+}
+
+func (a *Assistant) requestModel(ctx context.Context, model string, msgs []Message, opts RequestOptions) (Message, Usage, error) {
+	if c, ok := a.client.(ContextHttpClient); ok {
+		return c.RequestContext(ctx, model, msgs, opts)
+	}
+	if err := ctx.Err(); err != nil {
+		return Message{}, Usage{}, err
+	}
+	// Dropping options would silently ignore tools or a response format.
+	if !opts.IsZero() {
+		return Message{}, Usage{}, ErrOptionsUnsupported
+	}
+	return a.client.Request(model, msgs)
 }
 
-func (a *Assistant) createThread(tid string) error {
-	if err := a.threads.CreateThread(tid); err != nil {
+func (a *Assistant) threadExists(ctx context.Context, tid string) (bool, error) {
+	if r, ok := a.threads.(ContextThreadRepository); ok {
+		return r.ThreadExistsContext(ctx, tid)
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return a.threads.ThreadExists(tid)
+}
+
+func (a *Assistant) appendMessage(ctx context.Context, tid string, msg Message) error {
+	if r, ok := a.threads.(ContextThreadRepository); ok {
+		return r.AppendMessageContext(ctx, tid, msg)
+	}
+	if err := ctx.Err(); err != nil {
 		return err
 	}
+	return a.threads.AppendMessage(tid, msg)
+}
+
+func (a *Assistant) getMessages(ctx context.Context, tid string) ([]Message, error) {
+	if r, ok := a.threads.(ContextThreadRepository); ok {
+		return r.GetMessagesContext(ctx, tid)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.threads.GetMessages(tid)
+}
 
-	return a.threads.AppendMessage(tid, Message{Role: RoleSystem, Content: a.system})
+func getMetadata(ctx context.Context, repo ThreadMetadataRepository, tid string, key string) (string, bool, error) {
+	if r, ok := repo.(ContextThreadMetadataRepository); ok {
+		return r.GetMetadataContext(ctx, tid, key)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", false, err
+	}
+	return repo.GetMetadata(tid, key)
+}
+
+func setMetadata(ctx context.Context, repo ThreadMetadataRepository, tid string, key string, value string) error {
+	if r, ok := repo.(ContextThreadMetadataRepository); ok {
+		return r.SetMetadataContext(ctx, tid, key, value)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return repo.SetMetadata(tid, key, value)
 }
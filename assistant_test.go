@@ -1,6 +1,7 @@
 package assistant
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -277,3 +278,55 @@ func TestGetUsage(t *testing.T) {
 
 	assert.Equal(t, expectedUsage, usage, "GetUsage should return the correct usage statistics")
 }
+
+// MockContextHttpClient records the context passed to RequestContext
+type MockContextHttpClient struct {
+	mock.Mock
+}
+
+func (c *MockContextHttpClient) Request(model string, msgs []Message) (Message, Usage, error) {
+	panic("Request must not be called when RequestContext is available")
+}
+
+func (c *MockContextHttpClient) RequestContext(ctx context.Context, model string, msgs []Message, opts RequestOptions) (Message, Usage, error) {
+	args := c.Called(ctx, model, msgs, opts)
+	return args.Get(0).(Message), args.Get(1).(Usage), args.Error(2)
+}
+
+func TestAskContext_UsesContextClient(t *testing.T) {
+	tid := "thread-1"
+	ctx := context.WithValue(context.Background(), struct{}{}, "marker")
+	expectedRequest := Message{Role: RoleUser, Content: "What is 2+2?"}
+	expectedResponse := Message{Role: RoleAssistant, Content: "Mock response"}
+
+	client := &MockContextHttpClient{}
+	threads := &MockThreadRepo{}
+	threads.On("ThreadExists", tid).Return(true, nil)
+	threads.On("AppendMessage", tid, expectedRequest).Return(nil)
+	threads.On("GetMessages", tid).Return([]Message{expectedRequest}, nil)
+	client.On("RequestContext", ctx, "gpt-4", []Message{expectedRequest}, RequestOptions{}).Return(expectedResponse, Usage{}, nil)
+	threads.On("AppendMessage", tid, expectedResponse).Return(nil)
+
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads)
+	response, err := assistant.AskContext(ctx, tid, "What is 2+2?")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Mock response", response)
+	threads.AssertExpectations(t)
+	client.AssertExpectations(t)
+}
+
+func TestAskContext_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	client := &MockHttpClient{}
+	threads := &MockThreadRepo{}
+
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads)
+	_, err := assistant.AskContext(ctx, "thread-1", "What is 2+2?")
+
+	assert.ErrorIs(t, err, context.Canceled)
+	threads.AssertNotCalled(t, "ThreadExists", mock.Anything)
+	client.AssertNotCalled(t, "Request", mock.Anything, mock.Anything)
+}
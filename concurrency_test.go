@@ -0,0 +1,150 @@
+package assistant
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncThreadRepo is a goroutine-safe in-memory thread repository
+type syncThreadRepo struct {
+	mu      sync.Mutex
+	threads map[string][]Message
+}
+
+func newSyncThreadRepo() *syncThreadRepo {
+	return &syncThreadRepo{threads: map[string][]Message{}}
+}
+
+func (r *syncThreadRepo) ThreadExists(tid string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.threads[tid]
+	return ok, nil
+}
+
+func (r *syncThreadRepo) CreateThread(tid string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.threads[tid] = nil
+	return nil
+}
+
+func (r *syncThreadRepo) AppendMessage(tid string, msg Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.threads[tid] = append(r.threads[tid], msg)
+	return nil
+}
+
+func (r *syncThreadRepo) GetMessages(tid string) ([]Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Message(nil), r.threads[tid]...), nil
+}
+
+// echoClient replies with the content of the last message after a short delay
+type echoClient struct{}
+
+func (echoClient) Request(model string, msgs []Message) (Message, Usage, error) {
+	time.Sleep(100 * time.Microsecond)
+	return Message{Role: RoleAssistant, Content: msgs[len(msgs)-1].Content}, Usage{PromptTokens: 1, CompletionTokens: 1, TotalTokens: 2}, nil
+}
+
+// RequestContext accepts the tools registered while turns are in flight
+func (c echoClient) RequestContext(ctx context.Context, model string, msgs []Message, opts RequestOptions) (Message, Usage, error) {
+	return c.Request(model, msgs)
+}
+
+func TestAsk_Concurrent(t *testing.T) {
+	const threads, turns = 4, 25
+
+	repo := newSyncThreadRepo()
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", echoClient{}, repo)
+
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		for j := 0; j < turns; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				tid := fmt.Sprintf("thread-%d", i)
+				msg := fmt.Sprintf("message-%d", j)
+				response, err := assistant.Ask(tid, msg)
+				assert.NoError(t, err)
+				assert.Equal(t, msg, response)
+				_ = assistant.GetUsage()
+				_ = assistant.GetThreadUsage(tid)
+			}()
+		}
+	}
+
+	// reconfigure while turns are in flight
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assistant.SetMaxToolIterations(5)
+		assistant.SetContextStrategy(KeepLastN(100))
+		assistant.RegisterTool(Tool{ToolDefinition: ToolDefinition{Name: "noop"}})
+		assistant.SetBudget(Budget{})
+	}()
+	wg.Wait()
+
+	for i := 0; i < threads; i++ {
+		msgs, err := repo.GetMessages(fmt.Sprintf("thread-%d", i))
+		require.NoError(t, err)
+		require.Len(t, msgs, 1+2*turns)
+		assert.Equal(t, RoleSystem, msgs[0].Role)
+		for k := 1; k < len(msgs); k += 2 {
+			assert.Equal(t, RoleUser, msgs[k].Role)
+			assert.Equal(t, Message{Role: RoleAssistant, Content: msgs[k].Content}, msgs[k+1])
+		}
+	}
+	assert.Equal(t, Usage{PromptTokens: threads * turns, CompletionTokens: threads * turns, TotalTokens: 2 * threads * turns}, assistant.GetUsageSnapshot().Total)
+}
+
+// blockingClient blocks until released
+type blockingClient struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c blockingClient) Request(model string, msgs []Message) (Message, Usage, error) {
+	c.started <- struct{}{}
+	<-c.release
+	return Message{Role: RoleAssistant, Content: "done"}, Usage{}, nil
+}
+
+func TestAskContext_WaitsForThreadLock(t *testing.T) {
+	client := blockingClient{started: make(chan struct{}, 1), release: make(chan struct{})}
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, newSyncThreadRepo())
+
+	done := make(chan error)
+	go func() {
+		_, err := assistant.Ask("thread-1", "first")
+		done <- err
+	}()
+	<-client.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := assistant.AskContext(ctx, "thread-1", "second")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	for _, err := range assistant.AskStream(ctx, "thread-1", "third") {
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	}
+	_, err = AskIntoContext[Positive](ctx, assistant, "thread-1", "fourth")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(client.release)
+	assert.NoError(t, <-done)
+
+	msgs, _ := assistant.GetMessages("thread-1")
+	assert.Len(t, msgs, 3)
+}
@@ -10,14 +10,18 @@
 // - Assistant: The main struct that manages conversations with AI models
 // - Message: Represents a single message in a conversation
 // - Usage: Tracks token consumption for billing and monitoring
+// - UsageTracker: Accumulates usage per thread, per model and globally
 // - HttpClient: Interface for making requests to AI service APIs
 // - ThreadRepository: Interface for storing and retrieving conversation threads
+// - storage/memory: Goroutine-safe in-memory ThreadRepository
+// - storage/file: ThreadRepository keeping one JSONL file per thread
+// - storage/sql: ThreadRepository over database/sql for SQLite and PostgreSQL
 //
 // # Basic Usage
 //
 //	// Initialize components
 //	httpClient := client.NewOpenAiClient(apiUrl, apiKey)
-//	threadRepo := storage.NewInMemoryThreadRepository()
+//	threadRepo := memory.NewThreadRepository()
 //
 //	// Create a new assistant
 //	assistant := assistant.NewAssistant(
@@ -47,4 +51,111 @@
 //	// Get token usage statistics
 //	usage := assistant.GetUsage()
 //	fmt.Printf("Tokens used: %d\n", usage.TotalTokens)
+//
+//	// Get usage accumulated by the thread
+//	total := assistant.GetThreadUsage(threadID)
+//	fmt.Printf("Thread tokens: %d\n", total.TotalTokens)
+//
+// # Cancellation
+//
+// AskContext and GetMessagesContext accept a context.Context which is passed
+// to clients implementing ContextHttpClient and repositories implementing
+// ContextThreadRepository or ContextThreadMetadataRepository. Ask and
+// GetMessages use context.Background().
+//
+//	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+//	defer cancel()
+//	response, err := assistant.AskContext(ctx, threadID, "What is the capital of France?")
+//
+// # Streaming
+//
+// AskStream yields the response as it is generated. The reply is stored in
+// the thread once the stream completes.
+//
+//	for delta, err := range assistant.AskStream(ctx, threadID, "Tell me a story") {
+//		if err != nil {
+//			log.Fatal(err)
+//		}
+//		fmt.Print(delta)
+//	}
+//
+// # Tools
+//
+// Go functions registered with RegisterTool are offered to the model. When
+// the model requests tool calls, Ask executes them, appends the RoleTool
+// results to the thread and asks again until a final answer is produced or
+// the SetMaxToolIterations limit is reached.
+//
+//	assistant.RegisterTool(assistant.Tool{
+//		ToolDefinition: assistant.ToolDefinition{
+//			Name:        "get_weather",
+//			Description: "Get the current weather in a city",
+//			Parameters:  json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}}}`),
+//		},
+//		Func: func(ctx context.Context, arguments string) (string, error) {
+//			return "sunny", nil
+//		},
+//	})
+//
+// # Structured Output
+//
+// AskInto derives a strict JSON Schema from the result type, requests a reply
+// matching it and decodes the reply. Decode and validation errors are sent
+// back to the model for a bounded number of retries.
+//
+//	type City struct {
+//		Name       string `json:"name"`
+//		Population int    `json:"population"`
+//	}
+//	city, err := assistant.AskInto[City](a, threadID, "Largest city in France?")
+//
+// # Request Options
+//
+// Generation settings such as temperature, max tokens, stop sequences and
+// seed are set with RequestOption values. Options given to NewAssistant are
+// defaults; options given to Ask override them for one call. Options require a
+// ContextHttpClient; with a plain HttpClient, Ask fails with
+// ErrOptionsUnsupported instead of dropping them.
+//
+//	a := assistant.NewAssistant(model, system, httpClient, threadRepo, assistant.WithTemperature(0.2))
+//	response, err := a.Ask(threadID, "Write a haiku", assistant.WithTemperature(0.9), assistant.WithMaxTokens(60))
+//
+// # Context Window
+//
+// A ContextStrategy trims the history sent with each request without changing
+// the stored thread. KeepLastN, TokenBudget and DropToolResultsFirst always
+// preserve the leading system message.
+//
+//	a.SetContextStrategy(assistant.TokenBudget(8000, nil))
+//
+// # Summarization
+//
+// With a Summarizer set, the oldest part of a long thread is condensed by the
+// model and the summary is stored in thread metadata. Subsequent requests send
+// the summary followed by the recent turns. The repository must implement
+// ThreadMetadataRepository.
+//
+//	a.SetSummarizer(&assistant.Summarizer{Threshold: 6000, KeepRecent: 10})
+//
+// # Cost and Budgets
+//
+// With a PriceTable set on the UsageTracker every recorded Usage carries an
+// estimated Cost. A Budget makes Ask fail with ErrBudgetExceeded before a
+// request is sent once a thread or the tracker total reaches its limit.
+//
+//	prices, err := assistant.LoadPriceTableYAML(f)
+//	tracker, err := assistant.NewUsageTracker(assistant.NewFileUsageStore("usage.json"))
+//	tracker.SetPriceTable(prices)
+//	a.SetUsageTracker(tracker)
+//	a.SetBudget(assistant.Budget{PerThread: 0.50})
+//
+// # Server-Side State
+//
+// With a StatefulHttpClient such as client.ResponsesClient, SetServerState
+// keeps the conversation on the provider. The last response ID is stored in
+// thread metadata and only new messages are sent; when the provider has
+// forgotten the response the full history is replayed.
+//
+//	a := assistant.NewAssistant("gpt-4o", system, client.NewResponsesClient(client.DefaultResponsesURL, apiKey), threads)
+//	a.SetServerState(true)
 package assistant
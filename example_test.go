@@ -5,6 +5,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/mwazovzky/assistant"
+	"github.com/mwazovzky/assistant/storage/memory"
 )
 
 type MockHttpClient struct{}
@@ -17,49 +18,11 @@ func (c MockHttpClient) Request(model string, msgs []assistant.Message) (msg ass
 	}, nil
 }
 
-type ThreadRepository struct {
-	data map[string][]assistant.Message
-}
-
-func NewThreadRepository() *ThreadRepository {
-	data := make(map[string][]assistant.Message)
-	return &ThreadRepository{data}
-}
-
-func (tr *ThreadRepository) CreateThread(tid string) error {
-	tr.data[tid] = []assistant.Message{}
-	return nil
-}
-
-func (tr *ThreadRepository) AppendMessage(tid string, msg assistant.Message) error {
-	messages, ok := tr.data[tid]
-	if !ok {
-		return fmt.Errorf("thread [%s] does not exist", tid)
-	}
-
-	tr.data[tid] = append(messages, msg)
-	return nil
-}
-
-func (tr *ThreadRepository) GetMessages(tid string) ([]assistant.Message, error) {
-	messages, ok := tr.data[tid]
-	if !ok {
-		return nil, fmt.Errorf("thread [%s] does not exist", tid)
-	}
-
-	return messages, nil
-}
-
-func (tr *ThreadRepository) ThreadExists(tid string) (bool, error) {
-	_, ok := tr.data[tid]
-	return ok, nil
-}
-
 func ExampleAssistant_Ask() {
 	model := "gpt-4o-mini"
 	system := "You are assistant"
 	client := MockHttpClient{} // Use the mock client
-	threads := NewThreadRepository()
+	threads := memory.NewThreadRepository()
 
 	a := assistant.NewAssistant(model, system, client, threads)
 
@@ -77,7 +40,7 @@ func ExampleAssistant_GetMessages() {
 	model := "gpt-4o-mini"
 	system := "You are assistant"
 	client := MockHttpClient{} // Use the mock client
-	threads := NewThreadRepository()
+	threads := memory.NewThreadRepository()
 
 	a := assistant.NewAssistant(model, system, client, threads)
 
@@ -100,5 +63,5 @@ func ExampleAssistant_GetMessages() {
 	}
 
 	fmt.Println(messages[0])
-	// Output: {system You are assistant}
+	// Output: {system You are assistant [] }
 }
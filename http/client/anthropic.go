@@ -0,0 +1,282 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mwazovzky/assistant"
+)
+
+const (
+	DefaultAnthropicURL = "https://api.anthropic.com/v1/messages"
+	AnthropicVersion    = "2023-06-01"
+	// DefaultAnthropicMaxTokens is sent when no max tokens option is set, as
+	// the Messages API requires one.
+	DefaultAnthropicMaxTokens = 4096
+)
+
+type anthropicContent struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string             `json:"role"`
+	Content []anthropicContent `json:"content"`
+}
+
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type anthropicMetadata struct {
+	UserID string `json:"user_id,omitempty"`
+}
+
+type anthropicRequest struct {
+	Model         string             `json:"model"`
+	System        string             `json:"system,omitempty"`
+	Messages      []anthropicMessage `json:"messages"`
+	MaxTokens     int                `json:"max_tokens"`
+	Tools         []anthropicTool    `json:"tools,omitempty"`
+	Temperature   *float64           `json:"temperature,omitempty"`
+	TopP          *float64           `json:"top_p,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+	Metadata      *anthropicMetadata `json:"metadata,omitempty"`
+}
+
+type anthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+// toUsage maps Anthropic usage to assistant.Usage. Anthropic reports cached
+// and cache-writing input separately from input_tokens; they are all prompt
+// tokens.
+func (u anthropicUsage) toUsage() assistant.Usage {
+	prompt := u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+	return assistant.Usage{
+		PromptTokens:     prompt,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      prompt + u.OutputTokens,
+		CachedTokens:     u.CacheReadInputTokens,
+	}
+}
+
+type anthropicResponse struct {
+	Role       string             `json:"role"`
+	Content    []anthropicContent `json:"content"`
+	StopReason string             `json:"stop_reason"`
+	Usage      anthropicUsage     `json:"usage"`
+}
+
+// AnthropicClient implements assistant.HttpClient over the Anthropic Messages
+// API. System messages are sent as the top-level system prompt, tool results
+// as tool_result blocks of a user turn, and consecutive messages of the same
+// role are merged, as the API requires alternating turns.
+//
+// Structured output, seeds and penalties have no equivalent in the Messages
+// API; requests setting the response format, seed or penalty options fail
+// with an error matching assistant.ErrOptionsUnsupported.
+type AnthropicClient struct {
+	url        string
+	apiKey     string
+	httpClient HttpDoer
+}
+
+func NewAnthropicClient(url string, apiKey string) *AnthropicClient {
+	return &AnthropicClient{
+		url:        url,
+		apiKey:     apiKey,
+		httpClient: &http.Client{},
+	}
+}
+
+func (c *AnthropicClient) SetHttpClient(httpClient HttpDoer) {
+	c.httpClient = httpClient
+}
+
+// SetRetryPolicy wraps the current HttpDoer of the client in a RetryDoer.
+func (c *AnthropicClient) SetRetryPolicy(policy RetryPolicy) {
+	c.httpClient = NewRetryDoer(c.httpClient, policy)
+}
+
+func (c *AnthropicClient) Request(model string, messages []assistant.Message) (assistant.Message, assistant.Usage, error) {
+	return c.RequestContext(context.Background(), model, messages, assistant.RequestOptions{})
+}
+
+func (c *AnthropicClient) RequestContext(ctx context.Context, model string, messages []assistant.Message, opts assistant.RequestOptions) (assistant.Message, assistant.Usage, error) {
+	req, err := newAnthropicRequest(model, messages, opts)
+	if err != nil {
+		return assistant.Message{}, assistant.Usage{}, err
+	}
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		return assistant.Message{}, assistant.Usage{}, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(reqBody))
+	if err != nil {
+		return assistant.Message{}, assistant.Usage{}, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", c.apiKey)
+	httpReq.Header.Set("anthropic-version", AnthropicVersion)
+
+	httpRes, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return assistant.Message{}, assistant.Usage{}, fmt.Errorf("http request failed: %w", err)
+	}
+	defer httpRes.Body.Close()
+
+	if httpRes.StatusCode != http.StatusOK {
+		return assistant.Message{}, assistant.Usage{}, anthropicError(httpRes)
+	}
+
+	var res anthropicResponse
+	if err := json.NewDecoder(httpRes.Body).Decode(&res); err != nil {
+		return assistant.Message{}, assistant.Usage{}, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if res.StopReason == "refusal" {
+		apiErr := contentFilterError(httpRes)
+		apiErr.Message = "the model refused to answer"
+		return assistant.Message{}, res.Usage.toUsage(), apiErr
+	}
+
+	return res.message(), res.Usage.toUsage(), nil
+}
+
+func (r anthropicResponse) message() assistant.Message {
+	msg := assistant.Message{Role: assistant.RoleAssistant}
+	var content strings.Builder
+	for _, block := range r.Content {
+		switch block.Type {
+		case "text":
+			content.WriteString(block.Text)
+		case "tool_use":
+			msg.ToolCalls = append(msg.ToolCalls, assistant.ToolCall{
+				ID:       block.ID,
+				Type:     "function",
+				Function: assistant.FunctionCall{Name: block.Name, Arguments: string(block.Input)},
+			})
+		}
+	}
+	msg.Content = content.String()
+	return msg
+}
+
+// anthropicError parses an Anthropic error response. Overflowing the context
+// window is reported with the OpenAI code so IsContextLengthExceeded works
+// for both providers.
+func anthropicError(res *http.Response) *APIError {
+	apiErr := newAPIError(res)
+	if apiErr.StatusCode == http.StatusBadRequest && strings.Contains(apiErr.Message, "prompt is too long") {
+		apiErr.Code = "context_length_exceeded"
+	}
+	return apiErr
+}
+
+func newAnthropicRequest(model string, messages []assistant.Message, opts assistant.RequestOptions) (anthropicRequest, error) {
+	if unsupported := unsupportedAnthropicOptions(opts); len(unsupported) > 0 {
+		return anthropicRequest{}, fmt.Errorf("%w: %s", assistant.ErrOptionsUnsupported, strings.Join(unsupported, ", "))
+	}
+
+	req := anthropicRequest{
+		Model:         model,
+		MaxTokens:     DefaultAnthropicMaxTokens,
+		Temperature:   opts.Temperature,
+		TopP:          opts.TopP,
+		StopSequences: opts.Stop,
+	}
+	if opts.MaxTokens != nil {
+		req.MaxTokens = *opts.MaxTokens
+	}
+	if opts.User != "" {
+		req.Metadata = &anthropicMetadata{UserID: opts.User}
+	}
+	for _, t := range opts.Tools {
+		schema := t.Parameters
+		if len(schema) == 0 {
+			schema = json.RawMessage(`{"type":"object"}`)
+		}
+		req.Tools = append(req.Tools, anthropicTool{Name: t.Name, Description: t.Description, InputSchema: schema})
+	}
+
+	var system []string
+	for _, msg := range messages {
+		if msg.Role == assistant.RoleSystem {
+			if msg.Content != "" {
+				system = append(system, msg.Content)
+			}
+			continue
+		}
+
+		role, blocks := anthropicBlocks(msg)
+		if n := len(req.Messages); n > 0 && req.Messages[n-1].Role == role {
+			req.Messages[n-1].Content = append(req.Messages[n-1].Content, blocks...)
+			continue
+		}
+		req.Messages = append(req.Messages, anthropicMessage{Role: role, Content: blocks})
+	}
+	req.System = strings.Join(system, "\n\n")
+	return req, nil
+}
+
+// unsupportedAnthropicOptions names the options set in opts that the
+// Messages API cannot carry.
+func unsupportedAnthropicOptions(opts assistant.RequestOptions) []string {
+	var names []string
+	if opts.ResponseFormat != nil {
+		names = append(names, "response format")
+	}
+	if opts.Seed != nil {
+		names = append(names, "seed")
+	}
+	if opts.PresencePenalty != nil {
+		names = append(names, "presence penalty")
+	}
+	if opts.FrequencyPenalty != nil {
+		names = append(names, "frequency penalty")
+	}
+	return names
+}
+
+// anthropicBlocks converts msg to the role and content blocks of an Anthropic
+// message. Tool results belong to the user turn.
+func anthropicBlocks(msg assistant.Message) (string, []anthropicContent) {
+	if msg.Role == assistant.RoleTool {
+		return "user", []anthropicContent{{Type: "tool_result", ToolUseID: msg.ToolCallID, Content: msg.Content}}
+	}
+
+	var blocks []anthropicContent
+	if msg.Content != "" {
+		blocks = append(blocks, anthropicContent{Type: "text", Text: msg.Content})
+	}
+	for _, call := range msg.ToolCalls {
+		input := json.RawMessage(call.Function.Arguments)
+		if !json.Valid(input) {
+			input = json.RawMessage(`{}`)
+		}
+		blocks = append(blocks, anthropicContent{Type: "tool_use", ID: call.ID, Name: call.Function.Name, Input: input})
+	}
+
+	role := "user"
+	if msg.Role == assistant.RoleAssistant {
+		role = "assistant"
+	}
+	return role, blocks
+}
@@ -0,0 +1,232 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mwazovzky/assistant"
+	"github.com/mwazovzky/assistant/http/client"
+)
+
+func anthropicServer(t *testing.T, status int, response string, body *map[string]any, header *http.Header) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if body != nil {
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(body))
+		}
+		if header != nil {
+			*header = r.Header.Clone()
+		}
+		w.Header().Set("request-id", "req_011")
+		w.WriteHeader(status)
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAnthropicClient_Request(t *testing.T) {
+	var body map[string]any
+	var header http.Header
+	server := anthropicServer(t, http.StatusOK, `{
+		"role": "assistant",
+		"content": [{"type": "text", "text": "2+2=4"}],
+		"stop_reason": "end_turn",
+		"usage": {"input_tokens": 10, "output_tokens": 5, "cache_read_input_tokens": 20}
+	}`, &body, &header)
+
+	c := client.NewAnthropicClient(server.URL, "test-api-key")
+	msg, usage, err := c.Request("claude-sonnet-4", []assistant.Message{
+		{Role: assistant.RoleSystem, Content: "You are a calculator"},
+		{Role: assistant.RoleUser, Content: "What is"},
+		{Role: assistant.RoleUser, Content: "2+2?"},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, assistant.Message{Role: assistant.RoleAssistant, Content: "2+2=4"}, msg)
+	assert.Equal(t, assistant.Usage{PromptTokens: 30, CompletionTokens: 5, TotalTokens: 35, CachedTokens: 20}, usage)
+
+	assert.Equal(t, "test-api-key", header.Get("x-api-key"))
+	assert.Equal(t, client.AnthropicVersion, header.Get("anthropic-version"))
+	assert.Empty(t, header.Get("Authorization"))
+
+	assert.Equal(t, "claude-sonnet-4", body["model"])
+	assert.Equal(t, "You are a calculator", body["system"])
+	assert.Equal(t, float64(client.DefaultAnthropicMaxTokens), body["max_tokens"])
+	assert.Equal(t, []any{
+		map[string]any{"role": "user", "content": []any{
+			map[string]any{"type": "text", "text": "What is"},
+			map[string]any{"type": "text", "text": "2+2?"},
+		}},
+	}, body["messages"])
+}
+
+func TestAnthropicClient_Options(t *testing.T) {
+	var body map[string]any
+	server := anthropicServer(t, http.StatusOK, `{"content": [{"type": "text", "text": "ok"}]}`, &body, nil)
+
+	temperature, maxTokens := 0.2, 100
+	c := client.NewAnthropicClient(server.URL, "test-api-key")
+	_, _, err := c.RequestContext(context.Background(), "claude-sonnet-4", []assistant.Message{{Role: assistant.RoleUser, Content: "hi"}}, assistant.RequestOptions{
+		Temperature: &temperature,
+		MaxTokens:   &maxTokens,
+		Stop:        []string{"END"},
+		User:        "user-42",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 0.2, body["temperature"])
+	assert.Equal(t, float64(100), body["max_tokens"])
+	assert.Equal(t, []any{"END"}, body["stop_sequences"])
+	assert.Equal(t, map[string]any{"user_id": "user-42"}, body["metadata"])
+	assert.NotContains(t, body, "top_p")
+}
+
+func TestAnthropicClient_UnsupportedOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("unexpected request")
+	}))
+	t.Cleanup(server.Close)
+
+	seed, penalty := 42, 0.5
+	tests := []struct {
+		name string
+		opts assistant.RequestOptions
+	}{
+		{"Response Format", assistant.RequestOptions{ResponseFormat: &assistant.ResponseFormat{Name: "Answer", Schema: json.RawMessage(`{"type":"object"}`)}}},
+		{"Seed", assistant.RequestOptions{Seed: &seed}},
+		{"Presence Penalty", assistant.RequestOptions{PresencePenalty: &penalty}},
+		{"Frequency Penalty", assistant.RequestOptions{FrequencyPenalty: &penalty}},
+	}
+
+	c := client.NewAnthropicClient(server.URL, "test-api-key")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := c.RequestContext(context.Background(), "claude-sonnet-4", []assistant.Message{{Role: assistant.RoleUser, Content: "hi"}}, tt.opts)
+			assert.ErrorIs(t, err, assistant.ErrOptionsUnsupported)
+		})
+	}
+}
+
+func TestAnthropicClient_Tools(t *testing.T) {
+	var body map[string]any
+	server := anthropicServer(t, http.StatusOK, `{
+		"role": "assistant",
+		"content": [
+			{"type": "text", "text": "Let me check."},
+			{"type": "tool_use", "id": "toolu_2", "name": "get_weather", "input": {"city": "Rome"}}
+		],
+		"stop_reason": "tool_use",
+		"usage": {"input_tokens": 10, "output_tokens": 5}
+	}`, &body, nil)
+
+	c := client.NewAnthropicClient(server.URL, "test-api-key")
+	msg, _, err := c.RequestContext(context.Background(), "claude-sonnet-4", []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Weather in Paris and Rome?"},
+		{Role: assistant.RoleAssistant, ToolCalls: []assistant.ToolCall{
+			{ID: "toolu_1", Type: "function", Function: assistant.FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+		}},
+		{Role: assistant.RoleTool, ToolCallID: "toolu_1", Content: "sunny"},
+	}, assistant.RequestOptions{Tools: []assistant.ToolDefinition{{
+		Name:        "get_weather",
+		Description: "Get the current weather in a city",
+		Parameters:  json.RawMessage(`{"type":"object"}`),
+	}}})
+
+	require.NoError(t, err)
+	assert.Equal(t, assistant.Message{
+		Role:    assistant.RoleAssistant,
+		Content: "Let me check.",
+		ToolCalls: []assistant.ToolCall{
+			{ID: "toolu_2", Type: "function", Function: assistant.FunctionCall{Name: "get_weather", Arguments: `{"city": "Rome"}`}},
+		},
+	}, msg)
+
+	assert.Equal(t, []any{map[string]any{
+		"name":         "get_weather",
+		"description":  "Get the current weather in a city",
+		"input_schema": map[string]any{"type": "object"},
+	}}, body["tools"])
+	assert.Equal(t, []any{
+		map[string]any{"role": "user", "content": []any{
+			map[string]any{"type": "text", "text": "Weather in Paris and Rome?"},
+		}},
+		map[string]any{"role": "assistant", "content": []any{
+			map[string]any{"type": "tool_use", "id": "toolu_1", "name": "get_weather", "input": map[string]any{"city": "Paris"}},
+		}},
+		map[string]any{"role": "user", "content": []any{
+			map[string]any{"type": "tool_result", "tool_use_id": "toolu_1", "content": "sunny"},
+		}},
+	}, body["messages"])
+}
+
+func TestAnthropicClient_Errors(t *testing.T) {
+	type testCase struct {
+		name     string
+		status   int
+		response string
+		check    func(error) bool
+		text     string
+	}
+
+	tests := []testCase{
+		{
+			name:     "Rate Limited",
+			status:   http.StatusTooManyRequests,
+			response: `{"type": "error", "error": {"type": "rate_limit_error", "message": "Number of requests has exceeded your rate limit"}}`,
+			check:    client.IsRateLimited,
+			text:     "http request error, status 429: rate_limit_error: Number of requests has exceeded your rate limit",
+		},
+		{
+			name:     "Invalid API Key",
+			status:   http.StatusUnauthorized,
+			response: `{"type": "error", "error": {"type": "authentication_error", "message": "invalid x-api-key"}}`,
+			check:    client.IsAuthError,
+			text:     "http request error, status 401: authentication_error: invalid x-api-key",
+		},
+		{
+			name:     "Overloaded",
+			status:   529,
+			response: `{"type": "error", "error": {"type": "overloaded_error", "message": "Overloaded"}}`,
+			check:    client.IsServerError,
+			text:     "http request error, status 529: overloaded_error: Overloaded",
+		},
+		{
+			name:     "Prompt Too Long",
+			status:   http.StatusBadRequest,
+			response: `{"type": "error", "error": {"type": "invalid_request_error", "message": "prompt is too long: 210000 tokens > 200000 maximum"}}`,
+			check:    client.IsContextLengthExceeded,
+			text:     "http request error, status 400: context_length_exceeded: prompt is too long: 210000 tokens > 200000 maximum",
+		},
+		{
+			name:     "Refusal",
+			status:   http.StatusOK,
+			response: `{"content": [], "stop_reason": "refusal"}`,
+			check:    client.IsContentFiltered,
+			text:     "http request error, status 200: content_filter: the model refused to answer",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server := anthropicServer(t, tc.status, tc.response, nil, nil)
+
+			c := client.NewAnthropicClient(server.URL, "test-api-key")
+			_, _, err := c.Request("claude-sonnet-4", []assistant.Message{{Role: assistant.RoleUser, Content: "hi"}})
+
+			require.Error(t, err)
+			assert.True(t, tc.check(err))
+			assert.EqualError(t, err, tc.text)
+
+			var apiErr *client.APIError
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, "req_011", apiErr.RequestID)
+		})
+	}
+}
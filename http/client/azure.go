@@ -0,0 +1,88 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const DefaultAzureAPIVersion = "2024-10-21"
+
+// TokenSource supplies bearer tokens, such as Microsoft Entra ID access
+// tokens. It is called for every request and is expected to cache and
+// refresh tokens itself.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenSourceFunc adapts a function to TokenSource.
+type TokenSourceFunc func(ctx context.Context) (string, error)
+
+func (f TokenSourceFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// AzureConfig configures an OpenAiClient for Azure OpenAI.
+type AzureConfig struct {
+	// Endpoint is the resource endpoint, such as
+	// https://my-resource.openai.azure.com.
+	Endpoint string
+	// APIVersion is sent as the api-version query parameter. It defaults to
+	// DefaultAzureAPIVersion.
+	APIVersion string
+	// Deployments maps model names used by the assistant to deployment
+	// names. A model without an entry is used as the deployment name.
+	Deployments map[string]string
+	// APIKey is sent in the api-key header unless TokenSource is set.
+	APIKey string
+	// TokenSource, when set, authenticates with an Authorization bearer
+	// token instead of the API key.
+	TokenSource TokenSource
+}
+
+// NewAzureOpenAiClient creates an OpenAiClient for Azure OpenAI. Requests go
+// to the deployment of the requested model:
+//
+//	{Endpoint}/openai/deployments/{deployment}/chat/completions?api-version={APIVersion}
+func NewAzureOpenAiClient(config AzureConfig) *OpenAiClient {
+	if config.APIVersion == "" {
+		config.APIVersion = DefaultAzureAPIVersion
+	}
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+
+	return &OpenAiClient{
+		url:        config.Endpoint,
+		httpClient: &http.Client{},
+		azure:      &config,
+	}
+}
+
+func (c *OpenAiClient) createAzureRequest(ctx context.Context, model string, body []byte) (*http.Request, error) {
+	deployment, ok := c.azure.Deployments[model]
+	if !ok {
+		deployment = model
+	}
+	endpoint := fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+		c.azure.Endpoint, url.PathEscape(deployment), url.QueryEscape(c.azure.APIVersion))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if c.azure.TokenSource == nil {
+		req.Header.Set("api-key", c.azure.APIKey)
+		return req, nil
+	}
+
+	token, err := c.azure.TokenSource.Token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token: %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	return req, nil
+}
@@ -0,0 +1,107 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mwazovzky/assistant"
+	"github.com/mwazovzky/assistant/http/client"
+)
+
+func azureServer(t *testing.T, response string) (*httptest.Server, *http.Request) {
+	t.Helper()
+	received := &http.Request{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*received = *r.Clone(context.Background())
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+func TestAzureOpenAiClient_Request(t *testing.T) {
+	server, received := azureServer(t, okBody)
+
+	c := client.NewAzureOpenAiClient(client.AzureConfig{
+		Endpoint:    server.URL + "/",
+		Deployments: map[string]string{"gpt-4o": "prod-gpt4o"},
+		APIKey:      "azure-key",
+	})
+	msg, _, err := c.Request("gpt-4o", []assistant.Message{{Role: assistant.RoleUser, Content: "hi"}})
+
+	require.NoError(t, err)
+	assert.Equal(t, "ok", msg.Content)
+	assert.Equal(t, "/openai/deployments/prod-gpt4o/chat/completions", received.URL.Path)
+	assert.Equal(t, client.DefaultAzureAPIVersion, received.URL.Query().Get("api-version"))
+	assert.Equal(t, "azure-key", received.Header.Get("api-key"))
+	assert.Empty(t, received.Header.Get("Authorization"))
+}
+
+func TestAzureOpenAiClient_UnmappedModel(t *testing.T) {
+	server, received := azureServer(t, okBody)
+
+	c := client.NewAzureOpenAiClient(client.AzureConfig{Endpoint: server.URL, APIVersion: "2025-01-01-preview", APIKey: "azure-key"})
+	_, _, err := c.Request("gpt-4o-mini", []assistant.Message{{Role: assistant.RoleUser, Content: "hi"}})
+
+	require.NoError(t, err)
+	assert.Equal(t, "/openai/deployments/gpt-4o-mini/chat/completions", received.URL.Path)
+	assert.Equal(t, "2025-01-01-preview", received.URL.Query().Get("api-version"))
+}
+
+func TestAzureOpenAiClient_TokenSource(t *testing.T) {
+	server, received := azureServer(t, okBody)
+
+	c := client.NewAzureOpenAiClient(client.AzureConfig{
+		Endpoint: server.URL,
+		APIKey:   "unused",
+		TokenSource: client.TokenSourceFunc(func(ctx context.Context) (string, error) {
+			return "entra-token", nil
+		}),
+	})
+	_, _, err := c.Request("gpt-4o", []assistant.Message{{Role: assistant.RoleUser, Content: "hi"}})
+
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer entra-token", received.Header.Get("Authorization"))
+	assert.Empty(t, received.Header.Get("api-key"))
+}
+
+func TestAzureOpenAiClient_TokenSourceError(t *testing.T) {
+	doer := &ScriptedDoer{steps: []step{{status: http.StatusOK, body: okBody}}}
+	tokenErr := errors.New("token expired")
+
+	c := client.NewAzureOpenAiClient(client.AzureConfig{
+		Endpoint: "https://example.openai.azure.com",
+		TokenSource: client.TokenSourceFunc(func(ctx context.Context) (string, error) {
+			return "", tokenErr
+		}),
+	})
+	c.SetHttpClient(doer)
+	_, _, err := c.Request("gpt-4o", []assistant.Message{{Role: assistant.RoleUser, Content: "hi"}})
+
+	assert.ErrorIs(t, err, tokenErr)
+	assert.Contains(t, err.Error(), "failed to get token")
+	assert.Equal(t, 0, doer.Attempts())
+}
+
+func TestAzureOpenAiClient_RequestStream(t *testing.T) {
+	var path, key string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, key = r.URL.Path, r.Header.Get("api-key")
+		w.Write([]byte("data: {\"choices\": [{\"index\": 0, \"delta\": {\"content\": \"hi\"}}]}\n\ndata: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	c := client.NewAzureOpenAiClient(client.AzureConfig{Endpoint: server.URL, APIKey: "azure-key", Deployments: map[string]string{"gpt-4o": "prod"}})
+	msg, _, err := c.RequestStream(context.Background(), "gpt-4o", []assistant.Message{{Role: assistant.RoleUser, Content: "hi"}}, assistant.RequestOptions{}, func(string) error { return nil })
+
+	require.NoError(t, err)
+	assert.Equal(t, "hi", msg.Content)
+	assert.Equal(t, "/openai/deployments/prod/chat/completions", path)
+	assert.Equal(t, "azure-key", key)
+}
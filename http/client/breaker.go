@@ -0,0 +1,275 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mwazovzky/assistant"
+)
+
+// ErrCircuitOpen is returned without contacting the provider while a
+// CircuitBreaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// BreakerPolicy configures CircuitBreaker. Zero fields take the defaults
+// below.
+type BreakerPolicy struct {
+	// Window is the period over which the failure rate is measured.
+	Window time.Duration
+	// MinRequests is the number of requests in the window below which the
+	// circuit never opens.
+	MinRequests int
+	// FailureRate is the share of failed requests in the window, between 0
+	// and 1, that opens the circuit.
+	FailureRate float64
+	// CoolDown is how long the circuit stays open before probing.
+	CoolDown time.Duration
+	// Probes is the number of successful probe requests that close a
+	// half-open circuit. Only this many requests are let through at a time.
+	Probes int
+}
+
+const (
+	DefaultBreakerWindow      = time.Minute
+	DefaultBreakerMinRequests = 10
+	DefaultBreakerFailureRate = 0.5
+	DefaultBreakerCoolDown    = 30 * time.Second
+	DefaultBreakerProbes      = 1
+)
+
+// windowSlots is the resolution of the sliding failure-rate window.
+const windowSlots = 10
+
+type windowSlot struct {
+	start     time.Time
+	successes int
+	failures  int
+}
+
+// CircuitBreaker is an assistant.HttpClient decorator that stops sending
+// requests to a failing provider. While closed it counts failures over a
+// sliding window; when the failure rate is reached it opens and fails every
+// request with ErrCircuitOpen. After the cool-down it lets probe requests
+// through: enough successes close it again, a failure reopens it.
+//
+// By default failures are the errors ShouldFallback reports as provider
+// problems; invalid requests and cancellations do not count.
+type CircuitBreaker struct {
+	next          assistant.HttpClient
+	policy        BreakerPolicy
+	isFailure     func(error) bool
+	onStateChange func(from, to CircuitState)
+
+	state    CircuitState
+	slots    [windowSlots]windowSlot
+	openedAt time.Time
+	probing  int
+	probed   int
+	mu       sync.Mutex
+}
+
+func NewCircuitBreaker(next assistant.HttpClient, policy BreakerPolicy) *CircuitBreaker {
+	if policy.Window <= 0 {
+		policy.Window = DefaultBreakerWindow
+	}
+	if policy.MinRequests <= 0 {
+		policy.MinRequests = DefaultBreakerMinRequests
+	}
+	if policy.FailureRate <= 0 || policy.FailureRate > 1 {
+		policy.FailureRate = DefaultBreakerFailureRate
+	}
+	if policy.CoolDown <= 0 {
+		policy.CoolDown = DefaultBreakerCoolDown
+	}
+	if policy.Probes <= 0 {
+		policy.Probes = DefaultBreakerProbes
+	}
+	return &CircuitBreaker{next: next, policy: policy, isFailure: ShouldFallback}
+}
+
+// SetIsFailure replaces the classification of errors that count as failures.
+func (b *CircuitBreaker) SetIsFailure(isFailure func(error) bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.isFailure = isFailure
+}
+
+// SetOnStateChange registers a callback for state transitions, for example
+// to log or export metrics. It is called without the breaker's lock held.
+func (b *CircuitBreaker) SetOnStateChange(onStateChange func(from, to CircuitState)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.onStateChange = onStateChange
+}
+
+// State returns the current state, for health checks. An open circuit whose
+// cool-down has passed reports half-open.
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitOpen && time.Since(b.openedAt) >= b.policy.CoolDown {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+func (b *CircuitBreaker) Request(model string, msgs []assistant.Message) (assistant.Message, assistant.Usage, error) {
+	return b.RequestContext(context.Background(), model, msgs, assistant.RequestOptions{})
+}
+
+func (b *CircuitBreaker) RequestContext(ctx context.Context, model string, msgs []assistant.Message, opts assistant.RequestOptions) (assistant.Message, assistant.Usage, error) {
+	return b.do(func() (assistant.Message, assistant.Usage, error) {
+		return requestContext(b.next, ctx, model, msgs, opts)
+	})
+}
+
+func (b *CircuitBreaker) RequestStream(ctx context.Context, model string, msgs []assistant.Message, opts assistant.RequestOptions, onDelta func(string) error) (assistant.Message, assistant.Usage, error) {
+	return b.do(func() (assistant.Message, assistant.Usage, error) {
+		return requestStream(b.next, ctx, model, msgs, opts, onDelta)
+	})
+}
+
+// RequestStateful forwards server-side conversation state to the wrapped
+// client, so a breaker around a ResponsesClient keeps Assistant's server state
+// working.
+func (b *CircuitBreaker) RequestStateful(ctx context.Context, model string, previousResponseID string, msgs []assistant.Message, opts assistant.RequestOptions) (assistant.Message, assistant.Usage, string, error) {
+	var id string
+	msg, usage, err := b.do(func() (assistant.Message, assistant.Usage, error) {
+		msg, usage, responseID, err := requestStateful(b.next, ctx, model, previousResponseID, msgs, opts)
+		id = responseID
+		return msg, usage, err
+	})
+	return msg, usage, id, err
+}
+
+func (b *CircuitBreaker) do(send func() (assistant.Message, assistant.Usage, error)) (assistant.Message, assistant.Usage, error) {
+	probe, err := b.allow()
+	if err != nil {
+		return assistant.Message{}, assistant.Usage{}, err
+	}
+
+	msg, usage, err := send()
+	b.record(probe, err)
+	return msg, usage, err
+}
+
+// allow reports whether a request may be sent and whether it is a probe.
+func (b *CircuitBreaker) allow() (bool, error) {
+	b.mu.Lock()
+	from := b.state
+	probe, err := false, error(nil)
+
+	switch b.state {
+	case CircuitOpen:
+		if time.Since(b.openedAt) < b.policy.CoolDown {
+			err = ErrCircuitOpen
+			break
+		}
+		b.state, b.probing, b.probed = CircuitHalfOpen, 0, 0
+		fallthrough
+	case CircuitHalfOpen:
+		if b.probing+b.probed >= b.policy.Probes {
+			err = ErrCircuitOpen
+			break
+		}
+		b.probing++
+		probe = true
+	}
+
+	b.unlock(from)
+	return probe, err
+}
+
+func (b *CircuitBreaker) record(probe bool, err error) {
+	b.mu.Lock()
+	from := b.state
+	failed := err != nil && b.isFailure(err)
+
+	if probe {
+		b.probing--
+		switch {
+		case b.state != CircuitHalfOpen:
+		case failed:
+			b.open()
+		case err == nil:
+			b.probed++
+			if b.probed >= b.policy.Probes {
+				b.state = CircuitClosed
+				b.slots = [windowSlots]windowSlot{}
+			}
+		}
+	} else if b.state == CircuitClosed {
+		b.count(failed)
+	}
+
+	b.unlock(from)
+}
+
+// count adds a result to the window and opens the circuit when the failure
+// rate is reached. The caller holds b.mu.
+func (b *CircuitBreaker) count(failed bool) {
+	now := time.Now()
+	span := b.policy.Window / windowSlots
+	start := now.Truncate(span)
+
+	slot := &b.slots[(start.UnixNano()/int64(span))%windowSlots]
+	if !slot.start.Equal(start) {
+		*slot = windowSlot{start: start}
+	}
+	if failed {
+		slot.failures++
+	} else {
+		slot.successes++
+	}
+
+	total, failures := 0, 0
+	for _, s := range b.slots {
+		if now.Sub(s.start) < b.policy.Window {
+			total += s.successes + s.failures
+			failures += s.failures
+		}
+	}
+	if total >= b.policy.MinRequests && float64(failures) >= b.policy.FailureRate*float64(total) {
+		b.open()
+	}
+}
+
+func (b *CircuitBreaker) open() {
+	b.state = CircuitOpen
+	b.openedAt = time.Now()
+}
+
+// unlock releases b.mu and reports a transition from the state from.
+func (b *CircuitBreaker) unlock(from CircuitState) {
+	to, onStateChange := b.state, b.onStateChange
+	b.mu.Unlock()
+
+	if from != to && onStateChange != nil {
+		onStateChange(from, to)
+	}
+}
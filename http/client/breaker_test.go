@@ -0,0 +1,175 @@
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mwazovzky/assistant"
+	"github.com/mwazovzky/assistant/http/client"
+)
+
+var (
+	serverError = &client.APIError{StatusCode: http.StatusServiceUnavailable}
+	badRequest  = &client.APIError{StatusCode: http.StatusBadRequest}
+)
+
+func testPolicy() client.BreakerPolicy {
+	return client.BreakerPolicy{Window: time.Minute, MinRequests: 4, FailureRate: 0.5, CoolDown: 20 * time.Millisecond, Probes: 1}
+}
+
+func tripBreaker(t *testing.T, b *client.CircuitBreaker, stub *StubClient) {
+	t.Helper()
+	stub.err = serverError
+	for range 4 {
+		_, _, err := b.Request("gpt-4o", hello)
+		require.ErrorIs(t, err, serverError)
+	}
+	require.Equal(t, client.CircuitOpen, b.State())
+}
+
+func TestCircuitBreaker_OpensOnFailureRate(t *testing.T) {
+	stub := &StubClient{reply: "ok"}
+	b := client.NewCircuitBreaker(stub, testPolicy())
+
+	// 2 failures out of 3 requests stay closed below MinRequests.
+	_, _, err := b.Request("gpt-4o", hello)
+	require.NoError(t, err)
+	stub.err = serverError
+	b.Request("gpt-4o", hello)
+	b.Request("gpt-4o", hello)
+	assert.Equal(t, client.CircuitClosed, b.State())
+
+	b.Request("gpt-4o", hello)
+	assert.Equal(t, client.CircuitOpen, b.State())
+
+	_, _, err = b.Request("gpt-4o", hello)
+	assert.ErrorIs(t, err, client.ErrCircuitOpen)
+	assert.Equal(t, 4, stub.Calls())
+}
+
+func TestCircuitBreaker_IgnoresRequestErrors(t *testing.T) {
+	stub := &StubClient{err: badRequest}
+	b := client.NewCircuitBreaker(stub, testPolicy())
+
+	for range 10 {
+		_, _, err := b.Request("gpt-4o", hello)
+		assert.ErrorIs(t, err, badRequest)
+	}
+
+	assert.Equal(t, client.CircuitClosed, b.State())
+}
+
+func TestCircuitBreaker_ClosesAfterSuccessfulProbe(t *testing.T) {
+	stub := &StubClient{reply: "ok"}
+	b := client.NewCircuitBreaker(stub, testPolicy())
+	tripBreaker(t, b, stub)
+
+	time.Sleep(25 * time.Millisecond)
+	assert.Equal(t, client.CircuitHalfOpen, b.State())
+
+	stub.err = nil
+	msg, _, err := b.Request("gpt-4o", hello)
+
+	require.NoError(t, err)
+	assert.Equal(t, "ok", msg.Content)
+	assert.Equal(t, client.CircuitClosed, b.State())
+}
+
+func TestCircuitBreaker_ReopensAfterFailedProbe(t *testing.T) {
+	stub := &StubClient{reply: "ok"}
+	b := client.NewCircuitBreaker(stub, testPolicy())
+	tripBreaker(t, b, stub)
+
+	time.Sleep(25 * time.Millisecond)
+	_, _, err := b.Request("gpt-4o", hello)
+
+	assert.ErrorIs(t, err, serverError)
+	assert.Equal(t, client.CircuitOpen, b.State())
+	_, _, err = b.Request("gpt-4o", hello)
+	assert.ErrorIs(t, err, client.ErrCircuitOpen)
+}
+
+func TestCircuitBreaker_LimitsConcurrentProbes(t *testing.T) {
+	release := make(chan struct{})
+	slow := &blockingStub{release: release, entered: make(chan struct{})}
+	policy := testPolicy()
+	b := client.NewCircuitBreaker(slow, policy)
+
+	slow.err = serverError
+	for range 4 {
+		b.Request("gpt-4o", hello)
+	}
+	require.Equal(t, client.CircuitOpen, b.State())
+	time.Sleep(25 * time.Millisecond)
+
+	slow.err = nil
+	slow.block = true
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, _, err := b.Request("gpt-4o", hello)
+		assert.NoError(t, err)
+	}()
+	<-slow.entered
+
+	_, _, err := b.Request("gpt-4o", hello)
+	assert.ErrorIs(t, err, client.ErrCircuitOpen)
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, client.CircuitClosed, b.State())
+}
+
+func TestCircuitBreaker_StateChanges(t *testing.T) {
+	stub := &StubClient{reply: "ok"}
+	b := client.NewCircuitBreaker(stub, testPolicy())
+
+	var changes []string
+	b.SetOnStateChange(func(from, to client.CircuitState) {
+		changes = append(changes, from.String()+" -> "+to.String())
+	})
+
+	tripBreaker(t, b, stub)
+	time.Sleep(25 * time.Millisecond)
+	stub.err = nil
+	_, _, err := b.Request("gpt-4o", hello)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"closed -> open", "open -> half-open", "half-open -> closed"}, changes)
+}
+
+func TestCircuitBreaker_CustomFailures(t *testing.T) {
+	stub := &StubClient{err: badRequest}
+	b := client.NewCircuitBreaker(stub, testPolicy())
+	b.SetIsFailure(func(err error) bool { return err != nil })
+
+	for range 4 {
+		b.Request("gpt-4o", hello)
+	}
+
+	assert.Equal(t, client.CircuitOpen, b.State())
+}
+
+// blockingStub optionally blocks in RequestContext until release is closed.
+type blockingStub struct {
+	StubClient
+	block   bool
+	release chan struct{}
+	entered chan struct{}
+	once    sync.Once
+}
+
+func (s *blockingStub) RequestContext(ctx context.Context, model string, msgs []assistant.Message, opts assistant.RequestOptions) (assistant.Message, assistant.Usage, error) {
+	if s.block {
+		s.once.Do(func() { close(s.entered) })
+		<-s.release
+	}
+	return s.StubClient.RequestContext(ctx, model, msgs, opts)
+}
@@ -15,20 +15,69 @@ type HttpDoer interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
+type function struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+type tool struct {
+	Type     string   `json:"type"`
+	Function function `json:"function"`
+}
+
+type jsonSchema struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+	Strict bool            `json:"strict"`
+}
+
+type responseFormat struct {
+	Type       string      `json:"type"`
+	JsonSchema *jsonSchema `json:"json_schema,omitempty"`
+}
+
 type openAiRequest struct {
-	Model    string              `json:"model"`
-	Messages []assistant.Message `json:"messages"`
+	Model            string              `json:"model"`
+	Messages         []assistant.Message `json:"messages"`
+	Tools            []tool              `json:"tools,omitempty"`
+	ResponseFormat   *responseFormat     `json:"response_format,omitempty"`
+	Temperature      *float64            `json:"temperature,omitempty"`
+	TopP             *float64            `json:"top_p,omitempty"`
+	MaxTokens        *int                `json:"max_tokens,omitempty"`
+	Stop             []string            `json:"stop,omitempty"`
+	Seed             *int                `json:"seed,omitempty"`
+	PresencePenalty  *float64            `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64            `json:"frequency_penalty,omitempty"`
+	User             string              `json:"user,omitempty"`
+	Stream           bool                `json:"stream,omitempty"`
+	StreamOptions    *streamOptions      `json:"stream_options,omitempty"`
 }
 
 type choice struct {
-	Index   int               `json:"index"`
-	Message assistant.Message `json:"message"`
+	Index        int               `json:"index"`
+	Message      assistant.Message `json:"message"`
+	FinishReason string            `json:"finish_reason"`
+}
+
+type promptTokensDetails struct {
+	CachedTokens int `json:"cached_tokens"`
 }
 
 type usage struct {
-	PromptTokens     int `json:"prompt_tokens"`
-	CompletionTokens int `json:"completion_tokens"`
-	TotalTokens      int `json:"total_tokens"`
+	PromptTokens        int                 `json:"prompt_tokens"`
+	CompletionTokens    int                 `json:"completion_tokens"`
+	TotalTokens         int                 `json:"total_tokens"`
+	PromptTokensDetails promptTokensDetails `json:"prompt_tokens_details"`
+}
+
+func (u usage) toUsage() assistant.Usage {
+	return assistant.Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+		CachedTokens:     u.PromptTokensDetails.CachedTokens,
+	}
 }
 
 type openAiResponse struct {
@@ -40,6 +89,7 @@ type OpenAiClient struct {
 	url        string
 	apiKey     string
 	httpClient HttpDoer
+	azure      *AzureConfig
 }
 
 func NewOpenAiClient(url string, apiKey string) *OpenAiClient {
@@ -55,12 +105,18 @@ func (c *OpenAiClient) SetHttpClient(httpClient HttpDoer) {
 }
 
 func (c *OpenAiClient) Request(model string, messages []assistant.Message) (assistant.Message, assistant.Usage, error) {
-	reqBody, err := json.Marshal(openAiRequest{Model: model, Messages: messages})
+	return c.RequestContext(context.Background(), model, messages, assistant.RequestOptions{})
+}
+
+// RequestContext sends a chat completion request bound to ctx, so the call is
+// aborted when ctx is cancelled or its deadline passes.
+func (c *OpenAiClient) RequestContext(ctx context.Context, model string, messages []assistant.Message, opts assistant.RequestOptions) (assistant.Message, assistant.Usage, error) {
+	reqBody, err := json.Marshal(newOpenAiRequest(model, messages, opts))
 	if err != nil {
 		return assistant.Message{}, assistant.Usage{}, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpReq, err := c.createRequest(context.Background(), reqBody)
+	httpReq, err := c.createRequest(ctx, model, reqBody)
 	if err != nil {
 		return assistant.Message{}, assistant.Usage{}, err
 	}
@@ -72,7 +128,7 @@ func (c *OpenAiClient) Request(model string, messages []assistant.Message) (assi
 	defer httpRes.Body.Close()
 
 	if httpRes.StatusCode != http.StatusOK {
-		return assistant.Message{}, assistant.Usage{}, fmt.Errorf("http request error, status %d", httpRes.StatusCode)
+		return assistant.Message{}, assistant.Usage{}, newAPIError(httpRes)
 	}
 
 	var res openAiResponse
@@ -84,16 +140,55 @@ func (c *OpenAiClient) Request(model string, messages []assistant.Message) (assi
 		return assistant.Message{}, assistant.Usage{}, fmt.Errorf("no choices returned in the response")
 	}
 
-	usage := assistant.Usage{
-		PromptTokens:     res.Usage.PromptTokens,
-		CompletionTokens: res.Usage.CompletionTokens,
-		TotalTokens:      res.Usage.TotalTokens,
+	if res.Choices[0].FinishReason == "content_filter" {
+		return assistant.Message{}, res.Usage.toUsage(), contentFilterError(httpRes)
+	}
+
+	return res.Choices[0].Message, res.Usage.toUsage(), nil
+}
+
+func contentFilterError(res *http.Response) *APIError {
+	return &APIError{
+		StatusCode: res.StatusCode,
+		Code:       "content_filter",
+		Message:    "completion was omitted by the content filter",
+		RequestID:  requestID(res),
 	}
+}
 
-	return res.Choices[0].Message, usage, nil
+func newOpenAiRequest(model string, messages []assistant.Message, opts assistant.RequestOptions) openAiRequest {
+	req := openAiRequest{
+		Model:            model,
+		Messages:         messages,
+		Temperature:      opts.Temperature,
+		TopP:             opts.TopP,
+		MaxTokens:        opts.MaxTokens,
+		Stop:             opts.Stop,
+		Seed:             opts.Seed,
+		PresencePenalty:  opts.PresencePenalty,
+		FrequencyPenalty: opts.FrequencyPenalty,
+		User:             opts.User,
+	}
+	for _, t := range opts.Tools {
+		req.Tools = append(req.Tools, tool{
+			Type:     "function",
+			Function: function{Name: t.Name, Description: t.Description, Parameters: t.Parameters},
+		})
+	}
+	if f := opts.ResponseFormat; f != nil {
+		req.ResponseFormat = &responseFormat{
+			Type:       "json_schema",
+			JsonSchema: &jsonSchema{Name: f.Name, Schema: f.Schema, Strict: f.Strict},
+		}
+	}
+	return req
 }
 
-func (c *OpenAiClient) createRequest(ctx context.Context, body []byte) (*http.Request, error) {
+func (c *OpenAiClient) createRequest(ctx context.Context, model string, body []byte) (*http.Request, error) {
+	if c.azure != nil {
+		return c.createAzureRequest(ctx, model, body)
+	}
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
@@ -1,10 +1,13 @@
 package client_test
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -106,3 +109,176 @@ func TestRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestRequestContext_PropagatesContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockHttpDoer := &MockHttpDoer{}
+	openAiClient := client.NewOpenAiClient("http://example.com", "test-api-key")
+	openAiClient.SetHttpClient(mockHttpDoer)
+
+	mockHttpDoer.On("Do", mock.MatchedBy(func(req *http.Request) bool {
+		return req.Context() == ctx
+	})).Return((*http.Response)(nil), context.Canceled)
+
+	_, _, err := openAiClient.RequestContext(ctx, "gpt-4", []assistant.Message{{Role: "user", Content: "What is 2+2?"}}, assistant.RequestOptions{})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	mockHttpDoer.AssertExpectations(t)
+}
+
+func TestRequestContext_Deadline(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	openAiClient := client.NewOpenAiClient(server.URL, "test-api-key")
+	_, _, err := openAiClient.RequestContext(ctx, "gpt-4", []assistant.Message{{Role: "user", Content: "What is 2+2?"}}, assistant.RequestOptions{})
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestRequestContext_Tools(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Write([]byte(`{
+			"choices": [{"message": {"role": "assistant", "content": null, "tool_calls": [
+				{"id": "call-1", "type": "function", "function": {"name": "get_weather", "arguments": "{\"city\":\"Paris\"}"}}
+			]}}],
+			"usage": {"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15}
+		}`))
+	}))
+	defer server.Close()
+
+	openAiClient := client.NewOpenAiClient(server.URL, "test-api-key")
+	result, _, err := openAiClient.RequestContext(context.Background(), "gpt-4", []assistant.Message{
+		{Role: "user", Content: "Weather in Paris?"},
+		{Role: "tool", Content: "sunny", ToolCallID: "call-0"},
+	}, assistant.RequestOptions{Tools: []assistant.ToolDefinition{{
+		Name:        "get_weather",
+		Description: "Get the current weather in a city",
+		Parameters:  json.RawMessage(`{"type":"object"}`),
+	}}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, assistant.Message{
+		Role: "assistant",
+		ToolCalls: []assistant.ToolCall{
+			{ID: "call-1", Type: "function", Function: assistant.FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+		},
+	}, result)
+	assert.Equal(t, []any{map[string]any{
+		"type": "function",
+		"function": map[string]any{
+			"name":        "get_weather",
+			"description": "Get the current weather in a city",
+			"parameters":  map[string]any{"type": "object"},
+		},
+	}}, body["tools"])
+	assert.Equal(t, "call-0", body["messages"].([]any)[1].(map[string]any)["tool_call_id"])
+}
+
+func TestRequestContext_ResponseFormat(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "{}"}}]}`))
+	}))
+	defer server.Close()
+
+	openAiClient := client.NewOpenAiClient(server.URL, "test-api-key")
+	_, _, err := openAiClient.RequestContext(context.Background(), "gpt-4", nil, assistant.RequestOptions{
+		ResponseFormat: &assistant.ResponseFormat{Name: "City", Schema: json.RawMessage(`{"type":"object"}`), Strict: true},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"type": "json_schema",
+		"json_schema": map[string]any{
+			"name":   "City",
+			"schema": map[string]any{"type": "object"},
+			"strict": true,
+		},
+	}, body["response_format"])
+}
+
+func TestRequestContext_GenerationOptions(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "4"}}]}`))
+	}))
+	defer server.Close()
+
+	temperature, topP, presence, frequency := 0.0, 0.5, 0.1, 0.2
+	maxTokens, seed := 100, 42
+
+	openAiClient := client.NewOpenAiClient(server.URL, "test-api-key")
+	_, _, err := openAiClient.RequestContext(context.Background(), "gpt-4", nil, assistant.RequestOptions{
+		Temperature:      &temperature,
+		TopP:             &topP,
+		MaxTokens:        &maxTokens,
+		Stop:             []string{"\n"},
+		Seed:             &seed,
+		PresencePenalty:  &presence,
+		FrequencyPenalty: &frequency,
+		User:             "user-1",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, body["temperature"])
+	assert.Equal(t, 0.5, body["top_p"])
+	assert.Equal(t, 100.0, body["max_tokens"])
+	assert.Equal(t, []any{"\n"}, body["stop"])
+	assert.Equal(t, 42.0, body["seed"])
+	assert.Equal(t, 0.1, body["presence_penalty"])
+	assert.Equal(t, 0.2, body["frequency_penalty"])
+	assert.Equal(t, "user-1", body["user"])
+}
+
+func TestRequestContext_OmitsUnsetOptions(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "4"}}]}`))
+	}))
+	defer server.Close()
+
+	openAiClient := client.NewOpenAiClient(server.URL, "test-api-key")
+	_, _, err := openAiClient.Request("gpt-4", nil)
+
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"model", "messages"}, keys(body))
+}
+
+func keys(m map[string]any) []string {
+	var ks []string
+	for k := range m {
+		ks = append(ks, k)
+	}
+	return ks
+}
+
+func TestRequestContext_CachedTokens(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{
+			"choices": [{"message": {"role": "assistant", "content": "4"}}],
+			"usage": {"prompt_tokens": 2000, "completion_tokens": 5, "total_tokens": 2005, "prompt_tokens_details": {"cached_tokens": 1024}}
+		}`))
+	}))
+	defer server.Close()
+
+	openAiClient := client.NewOpenAiClient(server.URL, "test-api-key")
+	_, usage, err := openAiClient.Request("gpt-4", nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, assistant.Usage{PromptTokens: 2000, CompletionTokens: 5, TotalTokens: 2005, CachedTokens: 1024}, usage)
+}
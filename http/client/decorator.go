@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mwazovzky/assistant"
+)
+
+// The decorators in this package wrap any assistant.HttpClient. These helpers
+// call the richest interface the wrapped client implements.
+
+func requestContext(c assistant.HttpClient, ctx context.Context, model string, msgs []assistant.Message, opts assistant.RequestOptions) (assistant.Message, assistant.Usage, error) {
+	if cc, ok := c.(assistant.ContextHttpClient); ok {
+		return cc.RequestContext(ctx, model, msgs, opts)
+	}
+	if err := ctx.Err(); err != nil {
+		return assistant.Message{}, assistant.Usage{}, err
+	}
+	if !opts.IsZero() {
+		return assistant.Message{}, assistant.Usage{}, assistant.ErrOptionsUnsupported
+	}
+	return c.Request(model, msgs)
+}
+
+// requestStream streams from c when it supports streaming and otherwise
+// delivers the whole reply as a single delta.
+func requestStream(c assistant.HttpClient, ctx context.Context, model string, msgs []assistant.Message, opts assistant.RequestOptions, onDelta func(string) error) (assistant.Message, assistant.Usage, error) {
+	if sc, ok := c.(assistant.StreamHttpClient); ok {
+		return sc.RequestStream(ctx, model, msgs, opts, onDelta)
+	}
+
+	msg, usage, err := requestContext(c, ctx, model, msgs, opts)
+	if err != nil {
+		return msg, usage, err
+	}
+	if msg.Content != "" {
+		if err := onDelta(msg.Content); err != nil {
+			return assistant.Message{}, assistant.Usage{}, err
+		}
+	}
+	return msg, usage, nil
+}
+
+// requestStateful continues a server-side conversation when c supports it.
+// Otherwise a conversation without previous response is sent whole and no
+// response ID is returned, and a previous response is reported as not found,
+// so Assistant falls back to replaying the full history.
+func requestStateful(c assistant.HttpClient, ctx context.Context, model string, previousResponseID string, msgs []assistant.Message, opts assistant.RequestOptions) (assistant.Message, assistant.Usage, string, error) {
+	if sc, ok := c.(assistant.StatefulHttpClient); ok {
+		return sc.RequestStateful(ctx, model, previousResponseID, msgs, opts)
+	}
+	if previousResponseID != "" {
+		return assistant.Message{}, assistant.Usage{}, "", fmt.Errorf("%w: %s does not keep server-side state", assistant.ErrResponseNotFound, previousResponseID)
+	}
+
+	msg, usage, err := requestContext(c, ctx, model, msgs, opts)
+	return msg, usage, "", err
+}
@@ -0,0 +1,100 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mwazovzky/assistant"
+	"github.com/mwazovzky/assistant/http/client"
+	"github.com/mwazovzky/assistant/storage/memory"
+)
+
+// StatefulStub is a StubClient that keeps server-side state and records the
+// previous response IDs it was sent.
+type StatefulStub struct {
+	StubClient
+	previous []string
+}
+
+func (c *StatefulStub) RequestStateful(ctx context.Context, model string, previousResponseID string, msgs []assistant.Message, opts assistant.RequestOptions) (assistant.Message, assistant.Usage, string, error) {
+	c.previous = append(c.previous, previousResponseID)
+	msg, usage, err := c.RequestContext(ctx, model, msgs, opts)
+	return msg, usage, "resp-next", err
+}
+
+func decorators(next assistant.HttpClient) map[string]assistant.HttpClient {
+	return map[string]assistant.HttpClient{
+		"RateLimiter":    client.NewRateLimiter(next, client.RateLimit{RequestsPerMinute: 100}),
+		"CircuitBreaker": client.NewCircuitBreaker(next, client.BreakerPolicy{Window: time.Minute}),
+		"FallbackClient": client.NewFallbackClient(client.Route{Name: "primary", Client: next}),
+		"WeightedRouter": client.NewWeightedRouter(client.WeightedRoute{Route: client.Route{Name: "primary", Client: next}, Weight: 1}),
+	}
+}
+
+func TestDecorators_ForwardStatefulRequests(t *testing.T) {
+	stub := &StatefulStub{StubClient: StubClient{reply: "hi"}}
+
+	for name, c := range decorators(stub) {
+		stateful, ok := c.(assistant.StatefulHttpClient)
+		require.True(t, ok, name)
+
+		msg, _, id, err := stateful.RequestStateful(context.Background(), "gpt-4o", "resp-1", hello, assistant.RequestOptions{})
+
+		require.NoError(t, err, name)
+		assert.Equal(t, "hi", msg.Content, name)
+		assert.Equal(t, "resp-next", id, name)
+	}
+	assert.Equal(t, []string{"resp-1", "resp-1", "resp-1", "resp-1"}, stub.previous)
+}
+
+func TestDecorators_StatefulRequestsWithoutState(t *testing.T) {
+	stub := &StubClient{reply: "hi"}
+
+	for name, c := range decorators(stub) {
+		stateful := c.(assistant.StatefulHttpClient)
+
+		// a new conversation is sent whole without a response ID
+		msg, _, id, err := stateful.RequestStateful(context.Background(), "gpt-4o", "", hello, assistant.RequestOptions{})
+		require.NoError(t, err, name)
+		assert.Equal(t, "hi", msg.Content, name)
+		assert.Empty(t, id, name)
+
+		// a previous response cannot be continued, so Assistant replays
+		_, _, _, err = stateful.RequestStateful(context.Background(), "gpt-4o", "resp-1", hello, assistant.RequestOptions{})
+		assert.ErrorIs(t, err, assistant.ErrResponseNotFound, name)
+	}
+	assert.Equal(t, 4, stub.Calls())
+}
+
+func TestDecorators_ServerStateWithoutState(t *testing.T) {
+	for name := range decorators(&StubClient{}) {
+		for _, threads := range []assistant.ThreadRepository{memory.NewThreadRepository(), &PlainRepo{memory.NewThreadRepository()}} {
+			stub := &StubClient{reply: "hi"}
+			a := assistant.NewAssistant("gpt-4o", "system", decorators(stub)[name], threads)
+			a.SetServerState(true)
+
+			// the wrapped client keeps no state, so every turn is sent whole
+			for _, question := range []string{"q1", "q2"} {
+				reply, err := a.Ask("thread-1", question)
+				require.NoError(t, err, name)
+				assert.Equal(t, "hi", reply, name)
+			}
+			assert.Equal(t, 2, stub.Calls(), name)
+
+			if repo, ok := threads.(assistant.ThreadMetadataRepository); ok {
+				_, ok, err := repo.GetMetadata("thread-1", assistant.MetadataResponseID)
+				require.NoError(t, err, name)
+				assert.False(t, ok, name)
+			}
+		}
+	}
+}
+
+// PlainRepo hides the metadata support of a repository
+type PlainRepo struct {
+	assistant.ThreadRepository
+}
@@ -0,0 +1,135 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBody limits how much of an error response body is read.
+const maxErrorBody = 64 * 1024
+
+// APIError is returned when the API responds with a non-success status or
+// refuses to answer. Use errors.As to inspect it.
+type APIError struct {
+	StatusCode int
+	Type       string
+	Code       string
+	Param      string
+	Message    string
+	// RequestID is the x-request-id or request-id response header, useful for
+	// support requests.
+	RequestID string
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("http request error, status %d", e.StatusCode)
+	if e.Code != "" {
+		msg += ": " + e.Code
+	} else if e.Type != "" {
+		msg += ": " + e.Type
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+type apiErrorBody struct {
+	Error struct {
+		Message string          `json:"message"`
+		Type    string          `json:"type"`
+		Param   *string         `json:"param"`
+		Code    json.RawMessage `json:"code"`
+	} `json:"error"`
+}
+
+// newAPIError builds an APIError from an unsuccessful response. Bodies that
+// are not in the OpenAI error format are kept as the message.
+func newAPIError(res *http.Response) *APIError {
+	apiErr := &APIError{
+		StatusCode: res.StatusCode,
+		RequestID:  requestID(res),
+	}
+
+	data, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+
+	var body apiErrorBody
+	if err := json.Unmarshal(data, &body); err != nil || body.Error.Message == "" && body.Error.Type == "" {
+		apiErr.Message = strings.TrimSpace(string(data))
+		return apiErr
+	}
+
+	apiErr.Message = body.Error.Message
+	apiErr.Type = body.Error.Type
+	if body.Error.Param != nil {
+		apiErr.Param = *body.Error.Param
+	}
+	apiErr.Code = rawString(body.Error.Code)
+	return apiErr
+}
+
+func requestID(res *http.Response) string {
+	if id := res.Header.Get("x-request-id"); id != "" {
+		return id
+	}
+	return res.Header.Get("request-id")
+}
+
+// rawString renders a JSON value that may be a string, a number or null.
+func rawString(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	return string(raw)
+}
+
+// IsRateLimited reports whether err is a rate limit or quota error.
+func IsRateLimited(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests
+}
+
+// IsQuotaExceeded reports whether err reports an exhausted billing quota,
+// which unlike a rate limit does not clear by waiting.
+func IsQuotaExceeded(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && (apiErr.Code == "insufficient_quota" || apiErr.Type == "insufficient_quota")
+}
+
+// IsContextLengthExceeded reports whether the request did not fit in the
+// model's context window.
+func IsContextLengthExceeded(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Code == "context_length_exceeded"
+}
+
+// IsAuthError reports whether the API key was missing, invalid or lacks
+// permission.
+func IsAuthError(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden)
+}
+
+// IsContentFiltered reports whether the prompt or the completion was refused
+// by the provider's content filter, including Gemini safety blocks.
+func IsContentFiltered(err error) bool {
+	if errors.Is(err, ErrSafetyBlocked) {
+		return true
+	}
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && (apiErr.Code == "content_filter" || apiErr.Code == "content_policy_violation")
+}
+
+// IsServerError reports whether the API failed on its side.
+func IsServerError(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode >= http.StatusInternalServerError
+}
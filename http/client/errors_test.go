@@ -0,0 +1,144 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mwazovzky/assistant"
+	"github.com/mwazovzky/assistant/http/client"
+)
+
+func errorResponse(status int, body string) *http.Response {
+	rec := httptest.NewRecorder()
+	rec.Header().Set("x-request-id", "req-123")
+	rec.WriteHeader(status)
+	rec.Body.WriteString(body)
+	return rec.Result()
+}
+
+func TestRequest_APIError(t *testing.T) {
+	type testCase struct {
+		name          string
+		mockResponse  *http.Response
+		expectedError client.APIError
+		expectedText  string
+		check         func(error) bool
+	}
+
+	tests := []testCase{
+		{
+			name: "Rate Limited",
+			mockResponse: errorResponse(http.StatusTooManyRequests, `{"error": {
+				"message": "Rate limit reached for requests", "type": "requests", "param": null, "code": "rate_limit_exceeded"
+			}}`),
+			expectedError: client.APIError{StatusCode: 429, Type: "requests", Code: "rate_limit_exceeded", Message: "Rate limit reached for requests", RequestID: "req-123"},
+			expectedText:  "http request error, status 429: rate_limit_exceeded: Rate limit reached for requests",
+			check:         client.IsRateLimited,
+		},
+		{
+			name: "Quota Exceeded",
+			mockResponse: errorResponse(http.StatusTooManyRequests, `{"error": {
+				"message": "You exceeded your current quota", "type": "insufficient_quota", "param": null, "code": "insufficient_quota"
+			}}`),
+			expectedError: client.APIError{StatusCode: 429, Type: "insufficient_quota", Code: "insufficient_quota", Message: "You exceeded your current quota", RequestID: "req-123"},
+			expectedText:  "http request error, status 429: insufficient_quota: You exceeded your current quota",
+			check:         client.IsQuotaExceeded,
+		},
+		{
+			name: "Invalid API Key",
+			mockResponse: errorResponse(http.StatusUnauthorized, `{"error": {
+				"message": "Incorrect API key provided", "type": "invalid_request_error", "param": null, "code": "invalid_api_key"
+			}}`),
+			expectedError: client.APIError{StatusCode: 401, Type: "invalid_request_error", Code: "invalid_api_key", Message: "Incorrect API key provided", RequestID: "req-123"},
+			expectedText:  "http request error, status 401: invalid_api_key: Incorrect API key provided",
+			check:         client.IsAuthError,
+		},
+		{
+			name: "Context Length Exceeded",
+			mockResponse: errorResponse(http.StatusBadRequest, `{"error": {
+				"message": "This model's maximum context length is 8192 tokens", "type": "invalid_request_error", "param": "messages", "code": "context_length_exceeded"
+			}}`),
+			expectedError: client.APIError{StatusCode: 400, Type: "invalid_request_error", Code: "context_length_exceeded", Param: "messages", Message: "This model's maximum context length is 8192 tokens", RequestID: "req-123"},
+			expectedText:  "http request error, status 400: context_length_exceeded: This model's maximum context length is 8192 tokens",
+			check:         client.IsContextLengthExceeded,
+		},
+		{
+			name: "Content Policy",
+			mockResponse: errorResponse(http.StatusBadRequest, `{"error": {
+				"message": "Your request was rejected", "type": "invalid_request_error", "code": "content_policy_violation"
+			}}`),
+			expectedError: client.APIError{StatusCode: 400, Type: "invalid_request_error", Code: "content_policy_violation", Message: "Your request was rejected", RequestID: "req-123"},
+			expectedText:  "http request error, status 400: content_policy_violation: Your request was rejected",
+			check:         client.IsContentFiltered,
+		},
+		{
+			name:          "Numeric Code",
+			mockResponse:  errorResponse(http.StatusInternalServerError, `{"error": {"message": "The server had an error", "type": "server_error", "code": 500}}`),
+			expectedError: client.APIError{StatusCode: 500, Type: "server_error", Code: "500", Message: "The server had an error", RequestID: "req-123"},
+			expectedText:  "http request error, status 500: 500: The server had an error",
+			check:         client.IsServerError,
+		},
+		{
+			name:          "Plain Text Body",
+			mockResponse:  errorResponse(http.StatusBadGateway, "Bad Gateway\n"),
+			expectedError: client.APIError{StatusCode: 502, Message: "Bad Gateway", RequestID: "req-123"},
+			expectedText:  "http request error, status 502: Bad Gateway",
+			check:         client.IsServerError,
+		},
+		{
+			name: "Content Filter Finish Reason",
+			mockResponse: errorResponse(http.StatusOK, `{
+				"choices": [{"message": {"role": "assistant", "content": null}, "finish_reason": "content_filter"}]
+			}`),
+			expectedError: client.APIError{StatusCode: 200, Code: "content_filter", Message: "completion was omitted by the content filter", RequestID: "req-123"},
+			expectedText:  "http request error, status 200: content_filter: completion was omitted by the content filter",
+			check:         client.IsContentFiltered,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockHttpDoer := &MockHttpDoer{}
+			openAiClient := client.NewOpenAiClient("http://example.com", "test-api-key")
+			openAiClient.SetHttpClient(mockHttpDoer)
+			mockHttpDoer.On("Do", mock.Anything).Return(tt.mockResponse, nil)
+
+			_, _, err := openAiClient.Request("gpt-4", []assistant.Message{{Role: "user", Content: "What is 2+2?"}})
+
+			var apiErr *client.APIError
+			require.True(t, errors.As(err, &apiErr))
+			assert.Equal(t, tt.expectedError, *apiErr)
+			assert.EqualError(t, err, tt.expectedText)
+			assert.True(t, tt.check(err))
+		})
+	}
+}
+
+func TestErrorHelpers_NonAPIError(t *testing.T) {
+	err := errors.New("mock network error")
+
+	assert.False(t, client.IsRateLimited(err))
+	assert.False(t, client.IsQuotaExceeded(err))
+	assert.False(t, client.IsContextLengthExceeded(err))
+	assert.False(t, client.IsAuthError(err))
+	assert.False(t, client.IsContentFiltered(err))
+	assert.False(t, client.IsServerError(err))
+}
+
+func TestRequestStream_APIError(t *testing.T) {
+	mockHttpDoer := &MockHttpDoer{}
+	openAiClient := client.NewOpenAiClient("http://example.com", "test-api-key")
+	openAiClient.SetHttpClient(mockHttpDoer)
+	mockHttpDoer.On("Do", mock.Anything).Return(errorResponse(http.StatusTooManyRequests, `{"error": {"message": "Slow down", "code": "rate_limit_exceeded"}}`), nil)
+
+	_, _, err := openAiClient.RequestStream(context.Background(), "gpt-4", nil, assistant.RequestOptions{}, func(string) error { return nil })
+
+	assert.True(t, client.IsRateLimited(err))
+}
@@ -0,0 +1,163 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/mwazovzky/assistant"
+)
+
+// Route is a provider a FallbackClient or WeightedRouter can send a request
+// to. Model replaces the requested model when set; the returned Usage then
+// names it in Usage.Model so the reply is priced as the model that served it.
+type Route struct {
+	Name   string
+	Client assistant.HttpClient
+	Model  string
+}
+
+func (r Route) model(requested string) string {
+	if r.Model != "" {
+		return r.Model
+	}
+	return requested
+}
+
+// served marks usage with the model override of the route, unless the client
+// already reported a model.
+func (r Route) served(usage assistant.Usage) assistant.Usage {
+	if usage.Model == "" {
+		usage.Model = r.Model
+	}
+	return usage
+}
+
+// Served describes which route answered a request and why earlier routes
+// were skipped.
+type Served struct {
+	Route    string
+	Model    string
+	Failures []error
+}
+
+// FallbackClient is an assistant.HttpClient that tries its routes in order
+// and returns the first reply. A failed route falls through to the next one
+// when ShouldFallback reports the error as a provider problem; other errors,
+// such as invalid requests, are returned immediately. A stream falls through
+// only until its first delta has been delivered.
+type FallbackClient struct {
+	routes         []Route
+	shouldFallback func(error) bool
+	onServed       func(Served)
+}
+
+func NewFallbackClient(routes ...Route) *FallbackClient {
+	return &FallbackClient{routes: routes, shouldFallback: ShouldFallback}
+}
+
+// SetShouldFallback replaces ShouldFallback.
+func (c *FallbackClient) SetShouldFallback(shouldFallback func(error) bool) {
+	c.shouldFallback = shouldFallback
+}
+
+// SetOnServed registers a callback that is told which route served each
+// successful request.
+func (c *FallbackClient) SetOnServed(onServed func(Served)) {
+	c.onServed = onServed
+}
+
+// ShouldFallback reports whether err is worth retrying with another provider:
+// connection errors, rate limits, authentication failures, unknown models,
+// server errors, context length overflows and tripped client-side guards.
+// Other API errors describe a bad request that every provider would reject.
+// Content filtered by any provider, including Gemini safety blocks, never
+// falls through, so a blocked prompt is not re-sent elsewhere. Neither does an
+// unknown previous response, which Assistant handles by replaying.
+func ShouldFallback(err error) bool {
+	if errors.Is(err, context.Canceled) || IsContentFiltered(err) || errors.Is(err, assistant.ErrResponseNotFound) {
+		return false
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	switch {
+	case apiErr.StatusCode == http.StatusTooManyRequests,
+		apiErr.StatusCode == http.StatusUnauthorized,
+		apiErr.StatusCode == http.StatusForbidden,
+		apiErr.StatusCode == http.StatusNotFound,
+		apiErr.StatusCode == http.StatusRequestTimeout,
+		apiErr.StatusCode >= http.StatusInternalServerError,
+		IsContextLengthExceeded(err):
+		return true
+	}
+	return false
+}
+
+func (c *FallbackClient) Request(model string, msgs []assistant.Message) (assistant.Message, assistant.Usage, error) {
+	return c.RequestContext(context.Background(), model, msgs, assistant.RequestOptions{})
+}
+
+func (c *FallbackClient) RequestContext(ctx context.Context, model string, msgs []assistant.Message, opts assistant.RequestOptions) (assistant.Message, assistant.Usage, error) {
+	return c.try(ctx, model, func(r Route) (assistant.Message, assistant.Usage, error) {
+		return requestContext(r.Client, ctx, r.model(model), msgs, opts)
+	}, nil)
+}
+
+func (c *FallbackClient) RequestStream(ctx context.Context, model string, msgs []assistant.Message, opts assistant.RequestOptions, onDelta func(string) error) (assistant.Message, assistant.Usage, error) {
+	started := false
+	send := func(r Route) (assistant.Message, assistant.Usage, error) {
+		return requestStream(r.Client, ctx, r.model(model), msgs, opts, func(delta string) error {
+			started = true
+			return onDelta(delta)
+		})
+	}
+	return c.try(ctx, model, send, func() bool { return started })
+}
+
+// RequestStateful forwards server-side conversation state. Response IDs are
+// only known to the provider that issued them, so a previous response that a
+// route does not know is returned as assistant.ErrResponseNotFound rather
+// than tried elsewhere, and Assistant replays the full history.
+func (c *FallbackClient) RequestStateful(ctx context.Context, model string, previousResponseID string, msgs []assistant.Message, opts assistant.RequestOptions) (assistant.Message, assistant.Usage, string, error) {
+	var id string
+	msg, usage, err := c.try(ctx, model, func(r Route) (assistant.Message, assistant.Usage, error) {
+		msg, usage, responseID, err := requestStateful(r.Client, ctx, r.model(model), previousResponseID, msgs, opts)
+		id = responseID
+		return msg, usage, err
+	}, nil)
+	return msg, usage, id, err
+}
+
+// try calls send for each route until one succeeds. Once delivered reports
+// true, output has reached the caller and a failure can no longer fall
+// through.
+func (c *FallbackClient) try(ctx context.Context, model string, send func(Route) (assistant.Message, assistant.Usage, error), delivered func() bool) (assistant.Message, assistant.Usage, error) {
+	if len(c.routes) == 0 {
+		return assistant.Message{}, assistant.Usage{}, errors.New("no routes configured")
+	}
+
+	var failures []error
+	for _, r := range c.routes {
+		if err := ctx.Err(); err != nil {
+			return assistant.Message{}, assistant.Usage{}, err
+		}
+
+		msg, usage, err := send(r)
+		if err == nil {
+			if c.onServed != nil {
+				c.onServed(Served{Route: r.Name, Model: r.model(model), Failures: failures})
+			}
+			return msg, r.served(usage), nil
+		}
+
+		failures = append(failures, fmt.Errorf("%s: %w", r.Name, err))
+		if ctx.Err() != nil || !c.shouldFallback(err) || (delivered != nil && delivered()) {
+			return assistant.Message{}, assistant.Usage{}, err
+		}
+	}
+	return assistant.Message{}, assistant.Usage{}, fmt.Errorf("all routes failed: %w", errors.Join(failures...))
+}
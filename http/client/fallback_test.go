@@ -0,0 +1,193 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mwazovzky/assistant"
+	"github.com/mwazovzky/assistant/http/client"
+)
+
+// FlakyStreamClient streams deltas and then fails with err.
+type FlakyStreamClient struct {
+	StubClient
+	deltas []string
+}
+
+func (c *FlakyStreamClient) RequestStream(ctx context.Context, model string, msgs []assistant.Message, opts assistant.RequestOptions, onDelta func(string) error) (assistant.Message, assistant.Usage, error) {
+	for _, d := range c.deltas {
+		if err := onDelta(d); err != nil {
+			return assistant.Message{}, assistant.Usage{}, err
+		}
+	}
+	return c.RequestContext(ctx, model, msgs, opts)
+}
+
+func TestFallbackClient_FallsThrough(t *testing.T) {
+	primary := &StubClient{err: &client.APIError{StatusCode: http.StatusServiceUnavailable}}
+	secondary := &StubClient{reply: "from secondary", usage: assistant.Usage{TotalTokens: 3}}
+	c := client.NewFallbackClient(
+		client.Route{Name: "openai", Client: primary},
+		client.Route{Name: "azure", Client: secondary, Model: "gpt-4o-deployment"},
+	)
+
+	var served client.Served
+	c.SetOnServed(func(s client.Served) { served = s })
+
+	msg, usage, err := c.Request("gpt-4o", hello)
+
+	require.NoError(t, err)
+	assert.Equal(t, "from secondary", msg.Content)
+	assert.Equal(t, assistant.Usage{TotalTokens: 3, Model: "gpt-4o-deployment"}, usage)
+	assert.Equal(t, []string{"gpt-4o"}, primary.models)
+	assert.Equal(t, []string{"gpt-4o-deployment"}, secondary.models)
+	assert.Equal(t, "azure", served.Route)
+	assert.Equal(t, "gpt-4o-deployment", served.Model)
+	require.Len(t, served.Failures, 1)
+	assert.True(t, client.IsServerError(served.Failures[0]))
+}
+
+func TestFallbackClient_StopsOnSafetyBlock(t *testing.T) {
+	primary := &StubClient{err: &client.SafetyError{Prompt: true, Reason: "SAFETY"}}
+	secondary := &StubClient{reply: "unused"}
+	c := client.NewFallbackClient(
+		client.Route{Name: "gemini", Client: primary},
+		client.Route{Name: "openai", Client: secondary},
+	)
+
+	_, _, err := c.Request("gemini-2.0-flash", hello)
+
+	assert.ErrorIs(t, err, client.ErrSafetyBlocked)
+	assert.Equal(t, 0, secondary.Calls())
+}
+
+func TestFallbackClient_StopsOnRequestError(t *testing.T) {
+	primary := &StubClient{err: &client.APIError{StatusCode: http.StatusBadRequest, Code: "invalid_value"}}
+	secondary := &StubClient{reply: "unused"}
+	c := client.NewFallbackClient(
+		client.Route{Name: "primary", Client: primary},
+		client.Route{Name: "secondary", Client: secondary},
+	)
+
+	_, _, err := c.Request("gpt-4o", hello)
+
+	var apiErr *client.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "invalid_value", apiErr.Code)
+	assert.Equal(t, 0, secondary.Calls())
+}
+
+func TestFallbackClient_AllRoutesFail(t *testing.T) {
+	c := client.NewFallbackClient(
+		client.Route{Name: "primary", Client: &StubClient{err: errors.New("connection refused")}},
+		client.Route{Name: "secondary", Client: &StubClient{err: &client.APIError{StatusCode: http.StatusTooManyRequests}}},
+	)
+
+	_, _, err := c.Request("gpt-4o", hello)
+
+	require.Error(t, err)
+	assert.True(t, client.IsRateLimited(err))
+	assert.Contains(t, err.Error(), "all routes failed")
+	assert.Contains(t, err.Error(), "primary: connection refused")
+}
+
+func TestFallbackClient_CustomClassifier(t *testing.T) {
+	primary := &StubClient{err: errors.New("boom")}
+	secondary := &StubClient{reply: "unused"}
+	c := client.NewFallbackClient(
+		client.Route{Name: "primary", Client: primary},
+		client.Route{Name: "secondary", Client: secondary},
+	)
+	c.SetShouldFallback(func(error) bool { return false })
+
+	_, _, err := c.Request("gpt-4o", hello)
+
+	assert.EqualError(t, err, "boom")
+	assert.Equal(t, 0, secondary.Calls())
+}
+
+func TestFallbackClient_CancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	secondary := &StubClient{reply: "unused"}
+	c := client.NewFallbackClient(
+		client.Route{Name: "primary", Client: &StubClient{reply: "unused"}},
+		client.Route{Name: "secondary", Client: secondary},
+	)
+
+	_, _, err := c.RequestContext(ctx, "gpt-4o", hello, assistant.RequestOptions{})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 0, secondary.Calls())
+}
+
+func TestFallbackClient_Stream(t *testing.T) {
+	t.Run("Falls Through Before First Delta", func(t *testing.T) {
+		primary := &FlakyStreamClient{StubClient: StubClient{err: &client.APIError{StatusCode: http.StatusBadGateway}}}
+		secondary := &FlakyStreamClient{StubClient: StubClient{reply: "hello world"}, deltas: []string{"hello", " world"}}
+		c := client.NewFallbackClient(
+			client.Route{Name: "primary", Client: primary},
+			client.Route{Name: "secondary", Client: secondary},
+		)
+
+		var deltas []string
+		msg, _, err := c.RequestStream(context.Background(), "gpt-4o", hello, assistant.RequestOptions{}, func(d string) error {
+			deltas = append(deltas, d)
+			return nil
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, "hello world", msg.Content)
+		assert.Equal(t, []string{"hello", " world"}, deltas)
+	})
+
+	t.Run("Does Not Fall Through After First Delta", func(t *testing.T) {
+		primary := &FlakyStreamClient{StubClient: StubClient{err: &client.APIError{StatusCode: http.StatusBadGateway}}, deltas: []string{"hel"}}
+		secondary := &FlakyStreamClient{StubClient: StubClient{reply: "hello"}, deltas: []string{"hello"}}
+		c := client.NewFallbackClient(
+			client.Route{Name: "primary", Client: primary},
+			client.Route{Name: "secondary", Client: secondary},
+		)
+
+		_, _, err := c.RequestStream(context.Background(), "gpt-4o", hello, assistant.RequestOptions{}, func(string) error { return nil })
+
+		assert.True(t, client.IsServerError(err))
+		assert.Equal(t, 0, secondary.Calls())
+	})
+}
+
+func TestShouldFallback(t *testing.T) {
+	type testCase struct {
+		name     string
+		err      error
+		expected bool
+	}
+
+	tests := []testCase{
+		{name: "Network Error", err: errors.New("dial tcp: connection refused"), expected: true},
+		{name: "Rate Limited", err: &client.APIError{StatusCode: 429}, expected: true},
+		{name: "Unauthorized", err: &client.APIError{StatusCode: 401}, expected: true},
+		{name: "Model Not Found", err: &client.APIError{StatusCode: 404}, expected: true},
+		{name: "Server Error", err: &client.APIError{StatusCode: 503}, expected: true},
+		{name: "Context Length", err: &client.APIError{StatusCode: 400, Code: "context_length_exceeded"}, expected: true},
+		{name: "Client Rate Limit", err: &client.RateLimitError{Model: "gpt-4o"}, expected: true},
+		{name: "Bad Request", err: &client.APIError{StatusCode: 400}, expected: false},
+		{name: "Content Filter", err: &client.APIError{StatusCode: 200, Code: "content_filter"}, expected: false},
+		{name: "Safety Block", err: &client.SafetyError{Prompt: true, Reason: "SAFETY"}, expected: false},
+		{name: "Wrapped Safety Block", err: fmt.Errorf("gemini: %w", &client.SafetyError{Reason: "SAFETY"}), expected: false},
+		{name: "Response Not Found", err: fmt.Errorf("%w: resp-1", assistant.ErrResponseNotFound), expected: false},
+		{name: "Cancelled", err: context.Canceled, expected: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, client.ShouldFallback(tc.err))
+		})
+	}
+}
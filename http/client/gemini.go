@@ -0,0 +1,388 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/mwazovzky/assistant"
+)
+
+const DefaultGeminiURL = "https://generativelanguage.googleapis.com/v1beta"
+
+// ErrSafetyBlocked is matched by errors.Is when Gemini blocks a prompt or a
+// candidate for safety or policy reasons.
+var ErrSafetyBlocked = errors.New("blocked by safety filter")
+
+// SafetyRating is Gemini's assessment of one harm category.
+type SafetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked"`
+}
+
+// SafetyError reports why Gemini refused to answer. Prompt is true when the
+// prompt itself was blocked; Reason is then the block reason, otherwise the
+// finish reason of the candidate. It matches ErrSafetyBlocked.
+type SafetyError struct {
+	Prompt  bool
+	Reason  string
+	Ratings []SafetyRating
+}
+
+func (e *SafetyError) Error() string {
+	subject := "response"
+	if e.Prompt {
+		subject = "prompt"
+	}
+	msg := fmt.Sprintf("%s: %s %s", ErrSafetyBlocked, subject, e.Reason)
+	var categories []string
+	for _, r := range e.Ratings {
+		if r.Blocked {
+			categories = append(categories, r.Category)
+		}
+	}
+	if len(categories) > 0 {
+		msg += " (" + strings.Join(categories, ", ") + ")"
+	}
+	return msg
+}
+
+func (e *SafetyError) Unwrap() error {
+	return ErrSafetyBlocked
+}
+
+// geminiSafetyReasons are the finish reasons that mean the candidate was
+// withheld.
+var geminiSafetyReasons = map[string]bool{
+	"SAFETY":             true,
+	"RECITATION":         true,
+	"BLOCKLIST":          true,
+	"PROHIBITED_CONTENT": true,
+	"SPII":               true,
+	"IMAGE_SAFETY":       true,
+}
+
+type geminiFunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type geminiFunctionResponse struct {
+	ID       string          `json:"id,omitempty"`
+	Name     string          `json:"name"`
+	Response json.RawMessage `json:"response"`
+}
+
+type geminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiFunctionDeclaration struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parametersJsonSchema,omitempty"`
+}
+
+type geminiTool struct {
+	FunctionDeclarations []geminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+type geminiGenerationConfig struct {
+	Temperature        *float64        `json:"temperature,omitempty"`
+	TopP               *float64        `json:"topP,omitempty"`
+	MaxOutputTokens    *int            `json:"maxOutputTokens,omitempty"`
+	StopSequences      []string        `json:"stopSequences,omitempty"`
+	Seed               *int            `json:"seed,omitempty"`
+	PresencePenalty    *float64        `json:"presencePenalty,omitempty"`
+	FrequencyPenalty   *float64        `json:"frequencyPenalty,omitempty"`
+	ResponseMimeType   string          `json:"responseMimeType,omitempty"`
+	ResponseJsonSchema json.RawMessage `json:"responseJsonSchema,omitempty"`
+}
+
+type geminiRequest struct {
+	SystemInstruction *geminiContent          `json:"systemInstruction,omitempty"`
+	Contents          []geminiContent         `json:"contents"`
+	Tools             []geminiTool            `json:"tools,omitempty"`
+	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type geminiCandidate struct {
+	Content       geminiContent  `json:"content"`
+	FinishReason  string         `json:"finishReason"`
+	SafetyRatings []SafetyRating `json:"safetyRatings"`
+}
+
+type geminiPromptFeedback struct {
+	BlockReason   string         `json:"blockReason"`
+	SafetyRatings []SafetyRating `json:"safetyRatings"`
+}
+
+type geminiUsage struct {
+	PromptTokenCount        int `json:"promptTokenCount"`
+	CandidatesTokenCount    int `json:"candidatesTokenCount"`
+	ThoughtsTokenCount      int `json:"thoughtsTokenCount"`
+	TotalTokenCount         int `json:"totalTokenCount"`
+	CachedContentTokenCount int `json:"cachedContentTokenCount"`
+}
+
+// toUsage maps usageMetadata to assistant.Usage. Thinking tokens are billed
+// as output and counted as completion tokens.
+func (u geminiUsage) toUsage() assistant.Usage {
+	return assistant.Usage{
+		PromptTokens:     u.PromptTokenCount,
+		CompletionTokens: u.CandidatesTokenCount + u.ThoughtsTokenCount,
+		TotalTokens:      u.TotalTokenCount,
+		CachedTokens:     u.CachedContentTokenCount,
+	}
+}
+
+type geminiResponse struct {
+	Candidates     []geminiCandidate     `json:"candidates"`
+	PromptFeedback *geminiPromptFeedback `json:"promptFeedback"`
+	UsageMetadata  geminiUsage           `json:"usageMetadata"`
+}
+
+// GeminiClient implements assistant.HttpClient over the Gemini generateContent
+// API. System messages become the systemInstruction, assistant messages the
+// "model" role, and tool calls and results functionCall and functionResponse
+// parts. Blocked prompts and candidates are returned as *SafetyError.
+type GeminiClient struct {
+	url        string
+	apiKey     string
+	httpClient HttpDoer
+}
+
+// NewGeminiClient creates a client for the API at url, such as
+// DefaultGeminiURL; the model is appended per request.
+func NewGeminiClient(url string, apiKey string) *GeminiClient {
+	return &GeminiClient{
+		url:        strings.TrimSuffix(url, "/"),
+		apiKey:     apiKey,
+		httpClient: &http.Client{},
+	}
+}
+
+func (c *GeminiClient) SetHttpClient(httpClient HttpDoer) {
+	c.httpClient = httpClient
+}
+
+// SetRetryPolicy wraps the current HttpDoer of the client in a RetryDoer.
+func (c *GeminiClient) SetRetryPolicy(policy RetryPolicy) {
+	c.httpClient = NewRetryDoer(c.httpClient, policy)
+}
+
+func (c *GeminiClient) Request(model string, messages []assistant.Message) (assistant.Message, assistant.Usage, error) {
+	return c.RequestContext(context.Background(), model, messages, assistant.RequestOptions{})
+}
+
+func (c *GeminiClient) RequestContext(ctx context.Context, model string, messages []assistant.Message, opts assistant.RequestOptions) (assistant.Message, assistant.Usage, error) {
+	reqBody, err := json.Marshal(newGeminiRequest(messages, opts))
+	if err != nil {
+		return assistant.Message{}, assistant.Usage{}, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/models/%s:generateContent", c.url, url.PathEscape(model))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(reqBody))
+	if err != nil {
+		return assistant.Message{}, assistant.Usage{}, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-goog-api-key", c.apiKey)
+
+	httpRes, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return assistant.Message{}, assistant.Usage{}, fmt.Errorf("http request failed: %w", err)
+	}
+	defer httpRes.Body.Close()
+
+	if httpRes.StatusCode != http.StatusOK {
+		return assistant.Message{}, assistant.Usage{}, geminiError(httpRes)
+	}
+
+	var res geminiResponse
+	if err := json.NewDecoder(httpRes.Body).Decode(&res); err != nil {
+		return assistant.Message{}, assistant.Usage{}, fmt.Errorf("failed to decode response: %w", err)
+	}
+	usage := res.UsageMetadata.toUsage()
+
+	if f := res.PromptFeedback; f != nil && f.BlockReason != "" {
+		return assistant.Message{}, usage, &SafetyError{Prompt: true, Reason: f.BlockReason, Ratings: f.SafetyRatings}
+	}
+	if len(res.Candidates) == 0 {
+		return assistant.Message{}, usage, fmt.Errorf("no candidates returned in the response")
+	}
+
+	candidate := res.Candidates[0]
+	if geminiSafetyReasons[candidate.FinishReason] {
+		return assistant.Message{}, usage, &SafetyError{Reason: candidate.FinishReason, Ratings: candidate.SafetyRatings}
+	}
+
+	return candidate.message(), usage, nil
+}
+
+func (c geminiCandidate) message() assistant.Message {
+	msg := assistant.Message{Role: assistant.RoleAssistant}
+	var content strings.Builder
+	for i, part := range c.Content.Parts {
+		if call := part.FunctionCall; call != nil {
+			args := string(call.Args)
+			if args == "" {
+				args = "{}"
+			}
+			// Older models do not send call IDs; the name and position
+			// identify the call within the turn.
+			id := call.ID
+			if id == "" {
+				id = fmt.Sprintf("%s-%d", call.Name, i)
+			}
+			msg.ToolCalls = append(msg.ToolCalls, assistant.ToolCall{
+				ID:       id,
+				Type:     "function",
+				Function: assistant.FunctionCall{Name: call.Name, Arguments: args},
+			})
+			continue
+		}
+		content.WriteString(part.Text)
+	}
+	msg.Content = content.String()
+	return msg
+}
+
+type geminiErrorBody struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+// geminiError parses a Google API error. The status, such as
+// RESOURCE_EXHAUSTED, becomes the type, and token limit errors get the
+// OpenAI code so IsContextLengthExceeded works for every provider.
+func geminiError(res *http.Response) *APIError {
+	apiErr := &APIError{StatusCode: res.StatusCode, RequestID: requestID(res)}
+
+	data, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+
+	var body geminiErrorBody
+	if err := json.Unmarshal(data, &body); err != nil || body.Error.Message == "" {
+		apiErr.Message = strings.TrimSpace(string(data))
+		return apiErr
+	}
+
+	apiErr.Type = body.Error.Status
+	apiErr.Message = body.Error.Message
+	if res.StatusCode == http.StatusBadRequest && strings.Contains(body.Error.Message, "exceeds the maximum number of tokens") {
+		apiErr.Code = "context_length_exceeded"
+	}
+	return apiErr
+}
+
+func newGeminiRequest(messages []assistant.Message, opts assistant.RequestOptions) geminiRequest {
+	var req geminiRequest
+
+	config := geminiGenerationConfig{
+		Temperature:      opts.Temperature,
+		TopP:             opts.TopP,
+		MaxOutputTokens:  opts.MaxTokens,
+		StopSequences:    opts.Stop,
+		Seed:             opts.Seed,
+		PresencePenalty:  opts.PresencePenalty,
+		FrequencyPenalty: opts.FrequencyPenalty,
+	}
+	if f := opts.ResponseFormat; f != nil {
+		config.ResponseMimeType = "application/json"
+		config.ResponseJsonSchema = f.Schema
+	}
+	if !reflect.ValueOf(config).IsZero() {
+		req.GenerationConfig = &config
+	}
+
+	if len(opts.Tools) > 0 {
+		var declarations []geminiFunctionDeclaration
+		for _, t := range opts.Tools {
+			declarations = append(declarations, geminiFunctionDeclaration{Name: t.Name, Description: t.Description, Parameters: t.Parameters})
+		}
+		req.Tools = []geminiTool{{FunctionDeclarations: declarations}}
+	}
+
+	// Function responses must name the function; it is looked up from the
+	// call the result answers.
+	names := map[string]string{}
+	var system []geminiPart
+	for _, msg := range messages {
+		if msg.Role == assistant.RoleSystem {
+			if msg.Content != "" {
+				system = append(system, geminiPart{Text: msg.Content})
+			}
+			continue
+		}
+		for _, call := range msg.ToolCalls {
+			names[call.ID] = call.Function.Name
+		}
+
+		role, parts := geminiParts(msg, names)
+		if n := len(req.Contents); n > 0 && req.Contents[n-1].Role == role {
+			req.Contents[n-1].Parts = append(req.Contents[n-1].Parts, parts...)
+			continue
+		}
+		req.Contents = append(req.Contents, geminiContent{Role: role, Parts: parts})
+	}
+	if len(system) > 0 {
+		req.SystemInstruction = &geminiContent{Parts: system}
+	}
+	return req
+}
+
+// geminiParts converts msg to the role and parts of a Gemini content.
+func geminiParts(msg assistant.Message, names map[string]string) (string, []geminiPart) {
+	switch msg.Role {
+	case assistant.RoleTool:
+		return "user", []geminiPart{{FunctionResponse: &geminiFunctionResponse{
+			ID:       msg.ToolCallID,
+			Name:     names[msg.ToolCallID],
+			Response: geminiFunctionResult(msg.Content),
+		}}}
+	case assistant.RoleAssistant:
+		var parts []geminiPart
+		if msg.Content != "" {
+			parts = append(parts, geminiPart{Text: msg.Content})
+		}
+		for _, call := range msg.ToolCalls {
+			args := json.RawMessage(call.Function.Arguments)
+			if !json.Valid(args) {
+				args = json.RawMessage(`{}`)
+			}
+			parts = append(parts, geminiPart{FunctionCall: &geminiFunctionCall{ID: call.ID, Name: call.Function.Name, Args: args}})
+		}
+		return "model", parts
+	}
+	return "user", []geminiPart{{Text: msg.Content}}
+}
+
+// geminiFunctionResult wraps a tool result in the object Gemini expects,
+// passing JSON objects through unchanged.
+func geminiFunctionResult(content string) json.RawMessage {
+	var object map[string]json.RawMessage
+	if json.Unmarshal([]byte(content), &object) == nil && object != nil {
+		return json.RawMessage(content)
+	}
+	data, _ := json.Marshal(map[string]string{"content": content})
+	return data
+}
@@ -0,0 +1,233 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mwazovzky/assistant"
+	"github.com/mwazovzky/assistant/http/client"
+)
+
+type geminiCall struct {
+	path   string
+	header http.Header
+	body   map[string]any
+}
+
+func geminiServer(t *testing.T, status int, response string) (*httptest.Server, *geminiCall) {
+	t.Helper()
+	call := &geminiCall{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call.path = r.URL.Path
+		call.header = r.Header.Clone()
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&call.body))
+		w.WriteHeader(status)
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server, call
+}
+
+func TestGeminiClient_Request(t *testing.T) {
+	server, call := geminiServer(t, http.StatusOK, `{
+		"candidates": [{"content": {"role": "model", "parts": [{"text": "2+2"}, {"text": "=4"}]}, "finishReason": "STOP"}],
+		"usageMetadata": {"promptTokenCount": 10, "candidatesTokenCount": 5, "thoughtsTokenCount": 3, "totalTokenCount": 18, "cachedContentTokenCount": 4}
+	}`)
+
+	c := client.NewGeminiClient(server.URL+"/v1beta", "test-api-key")
+	msg, usage, err := c.Request("gemini-2.5-flash", []assistant.Message{
+		{Role: assistant.RoleSystem, Content: "You are a calculator"},
+		{Role: assistant.RoleUser, Content: "What is 2+2?"},
+		{Role: assistant.RoleAssistant, Content: "4"},
+		{Role: assistant.RoleUser, Content: "Sure?"},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, assistant.Message{Role: assistant.RoleAssistant, Content: "2+2=4"}, msg)
+	assert.Equal(t, assistant.Usage{PromptTokens: 10, CompletionTokens: 8, TotalTokens: 18, CachedTokens: 4}, usage)
+
+	assert.Equal(t, "/v1beta/models/gemini-2.5-flash:generateContent", call.path)
+	assert.Equal(t, "test-api-key", call.header.Get("x-goog-api-key"))
+	assert.Equal(t, map[string]any{"parts": []any{map[string]any{"text": "You are a calculator"}}}, call.body["systemInstruction"])
+	assert.Equal(t, []any{
+		map[string]any{"role": "user", "parts": []any{map[string]any{"text": "What is 2+2?"}}},
+		map[string]any{"role": "model", "parts": []any{map[string]any{"text": "4"}}},
+		map[string]any{"role": "user", "parts": []any{map[string]any{"text": "Sure?"}}},
+	}, call.body["contents"])
+	assert.NotContains(t, call.body, "generationConfig")
+}
+
+func TestGeminiClient_Options(t *testing.T) {
+	server, call := geminiServer(t, http.StatusOK, `{"candidates": [{"content": {"parts": [{"text": "{}"}]}, "finishReason": "STOP"}]}`)
+
+	temperature, maxTokens := 0.5, 256
+	c := client.NewGeminiClient(server.URL, "test-api-key")
+	_, _, err := c.RequestContext(context.Background(), "gemini-2.5-flash", []assistant.Message{{Role: assistant.RoleUser, Content: "hi"}}, assistant.RequestOptions{
+		Temperature:    &temperature,
+		MaxTokens:      &maxTokens,
+		Stop:           []string{"END"},
+		ResponseFormat: &assistant.ResponseFormat{Name: "City", Schema: json.RawMessage(`{"type":"object"}`), Strict: true},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"temperature":        0.5,
+		"maxOutputTokens":    float64(256),
+		"stopSequences":      []any{"END"},
+		"responseMimeType":   "application/json",
+		"responseJsonSchema": map[string]any{"type": "object"},
+	}, call.body["generationConfig"])
+}
+
+func TestGeminiClient_Tools(t *testing.T) {
+	server, call := geminiServer(t, http.StatusOK, `{
+		"candidates": [{"content": {"role": "model", "parts": [
+			{"functionCall": {"name": "get_weather", "args": {"city": "Rome"}}}
+		]}, "finishReason": "STOP"}]
+	}`)
+
+	c := client.NewGeminiClient(server.URL, "test-api-key")
+	msg, _, err := c.RequestContext(context.Background(), "gemini-2.5-flash", []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Weather in Paris?"},
+		{Role: assistant.RoleAssistant, ToolCalls: []assistant.ToolCall{
+			{ID: "call-1", Type: "function", Function: assistant.FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+		}},
+		{Role: assistant.RoleTool, ToolCallID: "call-1", Content: "sunny"},
+	}, assistant.RequestOptions{Tools: []assistant.ToolDefinition{{
+		Name:        "get_weather",
+		Description: "Get the current weather in a city",
+		Parameters:  json.RawMessage(`{"type":"object"}`),
+	}}})
+
+	require.NoError(t, err)
+	assert.Equal(t, assistant.Message{
+		Role: assistant.RoleAssistant,
+		ToolCalls: []assistant.ToolCall{
+			{ID: "get_weather-0", Type: "function", Function: assistant.FunctionCall{Name: "get_weather", Arguments: `{"city": "Rome"}`}},
+		},
+	}, msg)
+
+	assert.Equal(t, []any{map[string]any{"functionDeclarations": []any{map[string]any{
+		"name":                 "get_weather",
+		"description":          "Get the current weather in a city",
+		"parametersJsonSchema": map[string]any{"type": "object"},
+	}}}}, call.body["tools"])
+	assert.Equal(t, []any{
+		map[string]any{"role": "user", "parts": []any{map[string]any{"text": "Weather in Paris?"}}},
+		map[string]any{"role": "model", "parts": []any{
+			map[string]any{"functionCall": map[string]any{"id": "call-1", "name": "get_weather", "args": map[string]any{"city": "Paris"}}},
+		}},
+		map[string]any{"role": "user", "parts": []any{
+			map[string]any{"functionResponse": map[string]any{"id": "call-1", "name": "get_weather", "response": map[string]any{"content": "sunny"}}},
+		}},
+	}, call.body["contents"])
+}
+
+func TestGeminiClient_SafetyBlocks(t *testing.T) {
+	type testCase struct {
+		name     string
+		response string
+		expected client.SafetyError
+		text     string
+	}
+
+	tests := []testCase{
+		{
+			name: "Prompt Blocked",
+			response: `{"promptFeedback": {"blockReason": "SAFETY", "safetyRatings": [
+				{"category": "HARM_CATEGORY_DANGEROUS_CONTENT", "probability": "HIGH", "blocked": true}
+			]}}`,
+			expected: client.SafetyError{Prompt: true, Reason: "SAFETY", Ratings: []client.SafetyRating{
+				{Category: "HARM_CATEGORY_DANGEROUS_CONTENT", Probability: "HIGH", Blocked: true},
+			}},
+			text: "blocked by safety filter: prompt SAFETY (HARM_CATEGORY_DANGEROUS_CONTENT)",
+		},
+		{
+			name: "Candidate Blocked",
+			response: `{"candidates": [{"finishReason": "SAFETY", "safetyRatings": [
+				{"category": "HARM_CATEGORY_HARASSMENT", "probability": "MEDIUM", "blocked": true},
+				{"category": "HARM_CATEGORY_HATE_SPEECH", "probability": "NEGLIGIBLE"}
+			]}]}`,
+			expected: client.SafetyError{Reason: "SAFETY", Ratings: []client.SafetyRating{
+				{Category: "HARM_CATEGORY_HARASSMENT", Probability: "MEDIUM", Blocked: true},
+				{Category: "HARM_CATEGORY_HATE_SPEECH", Probability: "NEGLIGIBLE"},
+			}},
+			text: "blocked by safety filter: response SAFETY (HARM_CATEGORY_HARASSMENT)",
+		},
+		{
+			name:     "Recitation",
+			response: `{"candidates": [{"finishReason": "RECITATION"}]}`,
+			expected: client.SafetyError{Reason: "RECITATION"},
+			text:     "blocked by safety filter: response RECITATION",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server, _ := geminiServer(t, http.StatusOK, tc.response)
+
+			c := client.NewGeminiClient(server.URL, "test-api-key")
+			_, _, err := c.Request("gemini-2.5-flash", []assistant.Message{{Role: assistant.RoleUser, Content: "hi"}})
+
+			var safetyErr *client.SafetyError
+			require.ErrorAs(t, err, &safetyErr)
+			assert.Equal(t, tc.expected, *safetyErr)
+			assert.EqualError(t, err, tc.text)
+			assert.ErrorIs(t, err, client.ErrSafetyBlocked)
+			assert.True(t, client.IsContentFiltered(err))
+		})
+	}
+}
+
+func TestGeminiClient_Errors(t *testing.T) {
+	type testCase struct {
+		name     string
+		status   int
+		response string
+		check    func(error) bool
+		text     string
+	}
+
+	tests := []testCase{
+		{
+			name:     "Rate Limited",
+			status:   http.StatusTooManyRequests,
+			response: `{"error": {"code": 429, "message": "Resource has been exhausted", "status": "RESOURCE_EXHAUSTED"}}`,
+			check:    client.IsRateLimited,
+			text:     "http request error, status 429: RESOURCE_EXHAUSTED: Resource has been exhausted",
+		},
+		{
+			name:     "Token Limit",
+			status:   http.StatusBadRequest,
+			response: `{"error": {"code": 400, "message": "The input token count exceeds the maximum number of tokens allowed", "status": "INVALID_ARGUMENT"}}`,
+			check:    client.IsContextLengthExceeded,
+			text:     "http request error, status 400: context_length_exceeded: The input token count exceeds the maximum number of tokens allowed",
+		},
+		{
+			name:     "Plain Text",
+			status:   http.StatusServiceUnavailable,
+			response: "upstream unavailable",
+			check:    client.IsServerError,
+			text:     "http request error, status 503: upstream unavailable",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server, _ := geminiServer(t, tc.status, tc.response)
+
+			c := client.NewGeminiClient(server.URL, "test-api-key")
+			_, _, err := c.Request("gemini-2.5-flash", []assistant.Message{{Role: assistant.RoleUser, Content: "hi"}})
+
+			require.Error(t, err)
+			assert.True(t, tc.check(err))
+			assert.EqualError(t, err, tc.text)
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/mwazovzky/assistant"
+)
+
+const DefaultOllamaURL = "http://localhost:11434"
+
+type ollamaFunctionCall struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type ollamaToolCall struct {
+	Function ollamaFunctionCall `json:"function"`
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+type ollamaOptions struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	NumPredict       *int     `json:"num_predict,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+}
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Tools    []tool          `json:"tools,omitempty"`
+	Format   json.RawMessage `json:"format,omitempty"`
+	Options  *ollamaOptions  `json:"options,omitempty"`
+	Stream   bool            `json:"stream"`
+}
+
+type ollamaResponse struct {
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+func (r ollamaResponse) usage() assistant.Usage {
+	return assistant.Usage{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		TotalTokens:      r.PromptEvalCount + r.EvalCount,
+	}
+}
+
+// OllamaClient implements assistant.HttpClient and streaming over the Ollama
+// /api/chat endpoint, for running local models without an API key. Ollama
+// does not assign tool call IDs; calls get IDs from their name and position.
+type OllamaClient struct {
+	url        string
+	httpClient HttpDoer
+}
+
+// NewOllamaClient creates a client for the Ollama server at url, such as
+// DefaultOllamaURL.
+func NewOllamaClient(url string) *OllamaClient {
+	return &OllamaClient{
+		url:        strings.TrimSuffix(url, "/"),
+		httpClient: &http.Client{},
+	}
+}
+
+func (c *OllamaClient) SetHttpClient(httpClient HttpDoer) {
+	c.httpClient = httpClient
+}
+
+func (c *OllamaClient) Request(model string, messages []assistant.Message) (assistant.Message, assistant.Usage, error) {
+	return c.RequestContext(context.Background(), model, messages, assistant.RequestOptions{})
+}
+
+func (c *OllamaClient) RequestContext(ctx context.Context, model string, messages []assistant.Message, opts assistant.RequestOptions) (assistant.Message, assistant.Usage, error) {
+	httpRes, err := c.send(ctx, newOllamaRequest(model, messages, opts, false))
+	if err != nil {
+		return assistant.Message{}, assistant.Usage{}, err
+	}
+	defer httpRes.Body.Close()
+
+	var res ollamaResponse
+	if err := json.NewDecoder(httpRes.Body).Decode(&res); err != nil {
+		return assistant.Message{}, assistant.Usage{}, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if res.Error != "" {
+		return assistant.Message{}, assistant.Usage{}, &APIError{StatusCode: httpRes.StatusCode, Message: res.Error}
+	}
+
+	msg := assistant.Message{Role: assistant.RoleAssistant, Content: res.Message.Content}
+	msg.ToolCalls = ollamaToolCalls(nil, res.Message.ToolCalls)
+	return msg, res.usage(), nil
+}
+
+// RequestStream streams the reply as newline-delimited JSON and calls onDelta
+// for every content fragment. Usage is taken from the final object.
+func (c *OllamaClient) RequestStream(ctx context.Context, model string, messages []assistant.Message, opts assistant.RequestOptions, onDelta func(delta string) error) (assistant.Message, assistant.Usage, error) {
+	httpRes, err := c.send(ctx, newOllamaRequest(model, messages, opts, true))
+	if err != nil {
+		return assistant.Message{}, assistant.Usage{}, err
+	}
+	defer httpRes.Body.Close()
+
+	msg := assistant.Message{Role: assistant.RoleAssistant}
+	var content strings.Builder
+
+	scanner := bufio.NewScanner(httpRes.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var chunk ollamaResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return assistant.Message{}, assistant.Usage{}, fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		if chunk.Error != "" {
+			return assistant.Message{}, assistant.Usage{}, &APIError{StatusCode: httpRes.StatusCode, Message: chunk.Error}
+		}
+
+		msg.ToolCalls = ollamaToolCalls(msg.ToolCalls, chunk.Message.ToolCalls)
+		if chunk.Message.Content != "" {
+			content.WriteString(chunk.Message.Content)
+			if err := onDelta(chunk.Message.Content); err != nil {
+				return assistant.Message{}, assistant.Usage{}, err
+			}
+		}
+
+		if chunk.Done {
+			msg.Content = content.String()
+			return msg, chunk.usage(), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return assistant.Message{}, assistant.Usage{}, fmt.Errorf("failed to read stream: %w", err)
+	}
+	return assistant.Message{}, assistant.Usage{}, fmt.Errorf("stream ended before completion")
+}
+
+func (c *OllamaClient) send(ctx context.Context, req ollamaRequest) (*http.Response, error) {
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+"/api/chat", bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	httpRes, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("http request failed: %w", err)
+	}
+	if httpRes.StatusCode != http.StatusOK {
+		defer httpRes.Body.Close()
+		return nil, ollamaError(httpRes)
+	}
+	return httpRes, nil
+}
+
+// ollamaError parses an Ollama error, which is a bare {"error": "..."}.
+func ollamaError(res *http.Response) *APIError {
+	apiErr := &APIError{StatusCode: res.StatusCode}
+
+	data, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil || body.Error == "" {
+		apiErr.Message = strings.TrimSpace(string(data))
+	} else {
+		apiErr.Message = body.Error
+	}
+	return apiErr
+}
+
+// ollamaToolCalls appends calls to the tool calls received so far, giving
+// each an ID from its name and position.
+func ollamaToolCalls(calls []assistant.ToolCall, received []ollamaToolCall) []assistant.ToolCall {
+	for _, call := range received {
+		args := string(call.Function.Arguments)
+		if args == "" || args == "null" {
+			args = "{}"
+		}
+		calls = append(calls, assistant.ToolCall{
+			ID:       fmt.Sprintf("%s-%d", call.Function.Name, len(calls)),
+			Type:     "function",
+			Function: assistant.FunctionCall{Name: call.Function.Name, Arguments: args},
+		})
+	}
+	return calls
+}
+
+func newOllamaRequest(model string, messages []assistant.Message, opts assistant.RequestOptions, stream bool) ollamaRequest {
+	req := ollamaRequest{Model: model, Stream: stream}
+
+	options := ollamaOptions{
+		Temperature:      opts.Temperature,
+		TopP:             opts.TopP,
+		NumPredict:       opts.MaxTokens,
+		Stop:             opts.Stop,
+		Seed:             opts.Seed,
+		PresencePenalty:  opts.PresencePenalty,
+		FrequencyPenalty: opts.FrequencyPenalty,
+	}
+	if !reflect.ValueOf(options).IsZero() {
+		req.Options = &options
+	}
+	if f := opts.ResponseFormat; f != nil {
+		req.Format = f.Schema
+	}
+	for _, t := range opts.Tools {
+		req.Tools = append(req.Tools, tool{
+			Type:     "function",
+			Function: function{Name: t.Name, Description: t.Description, Parameters: t.Parameters},
+		})
+	}
+
+	names := map[string]string{}
+	for _, msg := range messages {
+		m := ollamaMessage{Role: msg.Role, Content: msg.Content}
+		for _, call := range msg.ToolCalls {
+			names[call.ID] = call.Function.Name
+			args := json.RawMessage(call.Function.Arguments)
+			if !json.Valid(args) {
+				args = json.RawMessage(`{}`)
+			}
+			m.ToolCalls = append(m.ToolCalls, ollamaToolCall{Function: ollamaFunctionCall{Name: call.Function.Name, Arguments: args}})
+		}
+		if msg.Role == assistant.RoleTool {
+			m.ToolName = names[msg.ToolCallID]
+		}
+		req.Messages = append(req.Messages, m)
+	}
+	return req
+}
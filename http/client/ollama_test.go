@@ -0,0 +1,239 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mwazovzky/assistant"
+	"github.com/mwazovzky/assistant/http/client"
+)
+
+func ollamaServer(t *testing.T, status int, lines ...string) (*httptest.Server, *map[string]any) {
+	t.Helper()
+	body := &map[string]any{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/chat", r.URL.Path)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(body))
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(status)
+		for _, line := range lines {
+			w.Write([]byte(line + "\n"))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, body
+}
+
+func TestOllamaClient_Request(t *testing.T) {
+	server, body := ollamaServer(t, http.StatusOK, `{
+		"model": "llama3.2", "message": {"role": "assistant", "content": "2+2=4"},
+		"done": true, "done_reason": "stop", "prompt_eval_count": 26, "eval_count": 7
+	}`)
+
+	c := client.NewOllamaClient(server.URL)
+	msg, usage, err := c.Request("llama3.2", []assistant.Message{
+		{Role: assistant.RoleSystem, Content: "You are a calculator"},
+		{Role: assistant.RoleUser, Content: "What is 2+2?"},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, assistant.Message{Role: assistant.RoleAssistant, Content: "2+2=4"}, msg)
+	assert.Equal(t, assistant.Usage{PromptTokens: 26, CompletionTokens: 7, TotalTokens: 33}, usage)
+	assert.Equal(t, map[string]any{
+		"model":  "llama3.2",
+		"stream": false,
+		"messages": []any{
+			map[string]any{"role": "system", "content": "You are a calculator"},
+			map[string]any{"role": "user", "content": "What is 2+2?"},
+		},
+	}, *body)
+}
+
+func TestOllamaClient_Options(t *testing.T) {
+	server, body := ollamaServer(t, http.StatusOK, `{"message": {"role": "assistant", "content": "{}"}, "done": true}`)
+
+	temperature, maxTokens, seed := 0.1, 64, 7
+	c := client.NewOllamaClient(server.URL)
+	_, _, err := c.RequestContext(context.Background(), "llama3.2", []assistant.Message{{Role: assistant.RoleUser, Content: "hi"}}, assistant.RequestOptions{
+		Temperature:    &temperature,
+		MaxTokens:      &maxTokens,
+		Seed:           &seed,
+		Stop:           []string{"END"},
+		ResponseFormat: &assistant.ResponseFormat{Name: "City", Schema: json.RawMessage(`{"type":"object"}`), Strict: true},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"temperature": 0.1,
+		"num_predict": float64(64),
+		"seed":        float64(7),
+		"stop":        []any{"END"},
+	}, (*body)["options"])
+	assert.Equal(t, map[string]any{"type": "object"}, (*body)["format"])
+}
+
+func TestOllamaClient_Tools(t *testing.T) {
+	server, body := ollamaServer(t, http.StatusOK, `{
+		"message": {"role": "assistant", "content": "", "tool_calls": [
+			{"function": {"name": "get_weather", "arguments": {"city": "Rome"}}}
+		]},
+		"done": true
+	}`)
+
+	c := client.NewOllamaClient(server.URL)
+	msg, _, err := c.RequestContext(context.Background(), "llama3.2", []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Weather in Paris?"},
+		{Role: assistant.RoleAssistant, ToolCalls: []assistant.ToolCall{
+			{ID: "get_weather-0", Type: "function", Function: assistant.FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+		}},
+		{Role: assistant.RoleTool, ToolCallID: "get_weather-0", Content: "sunny"},
+	}, assistant.RequestOptions{Tools: []assistant.ToolDefinition{{
+		Name:        "get_weather",
+		Description: "Get the current weather in a city",
+		Parameters:  json.RawMessage(`{"type":"object"}`),
+	}}})
+
+	require.NoError(t, err)
+	assert.Equal(t, assistant.Message{
+		Role: assistant.RoleAssistant,
+		ToolCalls: []assistant.ToolCall{
+			{ID: "get_weather-0", Type: "function", Function: assistant.FunctionCall{Name: "get_weather", Arguments: `{"city": "Rome"}`}},
+		},
+	}, msg)
+
+	assert.Equal(t, []any{
+		map[string]any{"role": "user", "content": "Weather in Paris?"},
+		map[string]any{"role": "assistant", "content": "", "tool_calls": []any{
+			map[string]any{"function": map[string]any{"name": "get_weather", "arguments": map[string]any{"city": "Paris"}}},
+		}},
+		map[string]any{"role": "tool", "content": "sunny", "tool_name": "get_weather"},
+	}, (*body)["messages"])
+	assert.Len(t, (*body)["tools"], 1)
+}
+
+func TestOllamaClient_RequestStream(t *testing.T) {
+	server, body := ollamaServer(t, http.StatusOK,
+		`{"message": {"role": "assistant", "content": "2+2"}, "done": false}`,
+		``,
+		`{"message": {"role": "assistant", "content": "=4"}, "done": false}`,
+		`{"message": {"role": "assistant", "content": ""}, "done": true, "done_reason": "stop", "prompt_eval_count": 26, "eval_count": 7}`,
+	)
+
+	c := client.NewOllamaClient(server.URL)
+	var deltas []string
+	msg, usage, err := c.RequestStream(context.Background(), "llama3.2", []assistant.Message{{Role: assistant.RoleUser, Content: "What is 2+2?"}}, assistant.RequestOptions{}, func(d string) error {
+		deltas = append(deltas, d)
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, true, (*body)["stream"])
+	assert.Equal(t, []string{"2+2", "=4"}, deltas)
+	assert.Equal(t, assistant.Message{Role: assistant.RoleAssistant, Content: "2+2=4"}, msg)
+	assert.Equal(t, assistant.Usage{PromptTokens: 26, CompletionTokens: 7, TotalTokens: 33}, usage)
+}
+
+func TestOllamaClient_RequestStream_Errors(t *testing.T) {
+	type testCase struct {
+		name          string
+		lines         []string
+		onDelta       func(string) error
+		expectedError string
+	}
+
+	tests := []testCase{
+		{
+			name:          "Error Line",
+			lines:         []string{`{"message": {"content": "2"}, "done": false}`, `{"error": "model runner has unexpectedly stopped"}`},
+			expectedError: "http request error, status 200: model runner has unexpectedly stopped",
+		},
+		{
+			name:          "Truncated",
+			lines:         []string{`{"message": {"content": "2"}, "done": false}`},
+			expectedError: "stream ended before completion",
+		},
+		{
+			name:          "Invalid JSON",
+			lines:         []string{`not json`},
+			expectedError: "failed to decode stream chunk",
+		},
+		{
+			name:          "Aborted By Caller",
+			lines:         []string{`{"message": {"content": "2"}, "done": false}`},
+			onDelta:       func(string) error { return errors.New("stop") },
+			expectedError: "stop",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server, _ := ollamaServer(t, http.StatusOK, tc.lines...)
+			onDelta := tc.onDelta
+			if onDelta == nil {
+				onDelta = func(string) error { return nil }
+			}
+
+			c := client.NewOllamaClient(server.URL)
+			_, _, err := c.RequestStream(context.Background(), "llama3.2", []assistant.Message{{Role: assistant.RoleUser, Content: "hi"}}, assistant.RequestOptions{}, onDelta)
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expectedError)
+		})
+	}
+}
+
+func TestOllamaClient_Errors(t *testing.T) {
+	server, _ := ollamaServer(t, http.StatusNotFound, `{"error": "model \"llama9\" not found, try pulling it first"}`)
+
+	c := client.NewOllamaClient(server.URL)
+	_, _, err := c.Request("llama9", []assistant.Message{{Role: assistant.RoleUser, Content: "hi"}})
+
+	var apiErr *client.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.EqualError(t, err, `http request error, status 404: model "llama9" not found, try pulling it first`)
+}
+
+func TestOllamaClient_Assistant(t *testing.T) {
+	server, _ := ollamaServer(t, http.StatusOK, `{"message": {"role": "assistant", "content": "pong"}, "done": true, "prompt_eval_count": 3, "eval_count": 1}`)
+
+	a := assistant.NewAssistant("llama3.2", "You are terse", client.NewOllamaClient(server.URL), newMemoryThreads())
+	reply, err := a.Ask("thread-1", "ping")
+
+	require.NoError(t, err)
+	assert.Equal(t, "pong", reply)
+	assert.Equal(t, 4, a.GetUsage().TotalTokens)
+}
+
+type memoryThreads map[string][]assistant.Message
+
+func newMemoryThreads() memoryThreads {
+	return memoryThreads{}
+}
+
+func (m memoryThreads) ThreadExists(tid string) (bool, error) {
+	_, ok := m[tid]
+	return ok, nil
+}
+
+func (m memoryThreads) CreateThread(tid string) error {
+	m[tid] = []assistant.Message{}
+	return nil
+}
+
+func (m memoryThreads) AppendMessage(tid string, msg assistant.Message) error {
+	m[tid] = append(m[tid], msg)
+	return nil
+}
+
+func (m memoryThreads) GetMessages(tid string) ([]assistant.Message, error) {
+	return m[tid], nil
+}
@@ -0,0 +1,286 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/mwazovzky/assistant"
+	"github.com/mwazovzky/assistant/internal/modeltable"
+	"github.com/mwazovzky/assistant/tokenizer"
+)
+
+// ErrRateLimited is matched by errors.Is when a fail-fast RateLimiter refuses
+// a request.
+var ErrRateLimited = errors.New("client rate limit exceeded")
+
+// RateLimit is a per-minute budget. A zero field is unlimited.
+type RateLimit struct {
+	RequestsPerMinute int
+	TokensPerMinute   int
+}
+
+// RateLimitError reports which model was limited and how long until the
+// request would fit. It matches ErrRateLimited.
+type RateLimitError struct {
+	Model      string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s: model %s, retry after %s", ErrRateLimited, e.Model, e.RetryAfter.Round(time.Millisecond))
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// TokenEstimator estimates the tokens a request will consume before it is
+// sent.
+type TokenEstimator func(model string, msgs []assistant.Message) int
+
+// RateLimiter is an assistant.HttpClient decorator that keeps requests within
+// requests-per-minute and tokens-per-minute budgets. Each model has its own
+// token buckets that refill continuously. Tokens are reserved from an
+// estimate before the request and corrected with the returned Usage
+// afterwards.
+//
+// By default a request waits until it fits the budget or its context is done;
+// with SetFailFast it returns a *RateLimitError instead.
+type RateLimiter struct {
+	next      assistant.HttpClient
+	limit     RateLimit
+	limits    map[string]RateLimit
+	failFast  bool
+	estimator TokenEstimator
+	buckets   map[string]*bucket
+	mu        sync.Mutex
+}
+
+// NewRateLimiter wraps next with limit applied to every model without a
+// limit of its own.
+func NewRateLimiter(next assistant.HttpClient, limit RateLimit) *RateLimiter {
+	return &RateLimiter{
+		next:      next,
+		limit:     limit,
+		limits:    map[string]RateLimit{},
+		estimator: EstimateRequestTokens,
+		buckets:   map[string]*bucket{},
+	}
+}
+
+// SetModelLimit sets the limit of model. Like price tables, a model without
+// an exact entry uses the entry with the longest matching prefix. Setting a
+// limit resets the buckets.
+func (l *RateLimiter) SetModelLimit(model string, limit RateLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.limits[model] = limit
+	l.buckets = map[string]*bucket{}
+}
+
+// SetFailFast makes requests that do not fit the budget fail immediately
+// instead of waiting.
+func (l *RateLimiter) SetFailFast(failFast bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.failFast = failFast
+}
+
+// SetEstimator replaces EstimateRequestTokens.
+func (l *RateLimiter) SetEstimator(estimator TokenEstimator) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.estimator = estimator
+}
+
+// EstimateRequestTokens counts the prompt tokens of msgs with the tokenizer
+// of model and falls back to assistant.EstimateTokens for models without an
+// encoding.
+func EstimateRequestTokens(model string, msgs []assistant.Message) int {
+	if n, err := tokenizer.CountTokens(model, msgs); err == nil {
+		return n
+	}
+	n := 0
+	for _, msg := range msgs {
+		n += assistant.EstimateTokens(msg)
+	}
+	return n
+}
+
+func (l *RateLimiter) Request(model string, msgs []assistant.Message) (assistant.Message, assistant.Usage, error) {
+	return l.RequestContext(context.Background(), model, msgs, assistant.RequestOptions{})
+}
+
+func (l *RateLimiter) RequestContext(ctx context.Context, model string, msgs []assistant.Message, opts assistant.RequestOptions) (assistant.Message, assistant.Usage, error) {
+	return l.do(ctx, model, msgs, opts, func() (assistant.Message, assistant.Usage, error) {
+		return requestContext(l.next, ctx, model, msgs, opts)
+	})
+}
+
+func (l *RateLimiter) RequestStream(ctx context.Context, model string, msgs []assistant.Message, opts assistant.RequestOptions, onDelta func(string) error) (assistant.Message, assistant.Usage, error) {
+	return l.do(ctx, model, msgs, opts, func() (assistant.Message, assistant.Usage, error) {
+		return requestStream(l.next, ctx, model, msgs, opts, onDelta)
+	})
+}
+
+// RequestStateful forwards server-side conversation state to the wrapped
+// client. Only the messages sent are estimated; the returned Usage corrects
+// the reservation as usual.
+func (l *RateLimiter) RequestStateful(ctx context.Context, model string, previousResponseID string, msgs []assistant.Message, opts assistant.RequestOptions) (assistant.Message, assistant.Usage, string, error) {
+	var id string
+	msg, usage, err := l.do(ctx, model, msgs, opts, func() (assistant.Message, assistant.Usage, error) {
+		msg, usage, responseID, err := requestStateful(l.next, ctx, model, previousResponseID, msgs, opts)
+		id = responseID
+		return msg, usage, err
+	})
+	return msg, usage, id, err
+}
+
+func (l *RateLimiter) do(ctx context.Context, model string, msgs []assistant.Message, opts assistant.RequestOptions, send func() (assistant.Message, assistant.Usage, error)) (assistant.Message, assistant.Usage, error) {
+	l.mu.Lock()
+	b := l.bucket(model)
+	failFast, estimator := l.failFast, l.estimator
+	l.mu.Unlock()
+
+	// The completion counts towards the limit too, up to max_tokens.
+	estimate := estimator(model, msgs)
+	if opts.MaxTokens != nil {
+		estimate += *opts.MaxTokens
+	}
+
+	var reserved float64
+	for {
+		var wait time.Duration
+		reserved, wait = b.take(time.Now(), estimate)
+		if wait == 0 {
+			break
+		}
+		if failFast {
+			return assistant.Message{}, assistant.Usage{}, &RateLimitError{Model: model, RetryAfter: wait}
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return assistant.Message{}, assistant.Usage{}, err
+		}
+	}
+
+	msg, usage, err := send()
+	if err != nil {
+		// A failed request still counts as a request but its tokens are
+		// returned.
+		b.adjust(-reserved)
+		return msg, usage, err
+	}
+	if usage.TotalTokens > 0 {
+		b.adjust(float64(usage.TotalTokens) - reserved)
+	}
+	return msg, usage, nil
+}
+
+// bucket returns the bucket of model. The caller holds l.mu.
+func (l *RateLimiter) bucket(model string) *bucket {
+	if b, ok := l.buckets[model]; ok {
+		return b
+	}
+	b := newBucket(l.lookup(model), time.Now())
+	l.buckets[model] = b
+	return b
+}
+
+func (l *RateLimiter) lookup(model string) RateLimit {
+	if limit, ok := modeltable.Lookup(l.limits, model); ok {
+		return limit
+	}
+	return l.limit
+}
+
+// bucket holds the remaining requests and tokens of one model. Both refill
+// linearly to their per-minute limit.
+type bucket struct {
+	limit    RateLimit
+	requests float64
+	tokens   float64
+	updated  time.Time
+	mu       sync.Mutex
+}
+
+func newBucket(limit RateLimit, now time.Time) *bucket {
+	return &bucket{
+		limit:    limit,
+		requests: float64(limit.RequestsPerMinute),
+		tokens:   float64(limit.TokensPerMinute),
+		updated:  now,
+	}
+}
+
+// take reserves one request and tokens and returns the tokens reserved, or
+// returns how long to wait until they are available. A request larger than
+// the token limit only waits for, and reserves, a full bucket.
+func (b *bucket) take(now time.Time, tokens int) (float64, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+
+	need := float64(tokens)
+	if limit := float64(b.limit.TokensPerMinute); need > limit {
+		need = limit
+	}
+
+	wait := max(
+		waitFor(b.requests, 1, b.limit.RequestsPerMinute),
+		waitFor(b.tokens, need, b.limit.TokensPerMinute),
+	)
+	if wait > 0 {
+		return 0, wait
+	}
+
+	if b.limit.RequestsPerMinute > 0 {
+		b.requests--
+	}
+	if b.limit.TokensPerMinute <= 0 {
+		return 0, 0
+	}
+	b.tokens -= need
+	return need, 0
+}
+
+// adjust removes tokens from the bucket, or returns them when negative. The
+// bucket may go into debt when a request used more than was reserved.
+func (b *bucket) adjust(tokens float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.limit.TokensPerMinute > 0 {
+		b.tokens = min(b.tokens-tokens, float64(b.limit.TokensPerMinute))
+	}
+}
+
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated).Minutes()
+	b.updated = now
+	if elapsed <= 0 {
+		return
+	}
+	if limit := float64(b.limit.RequestsPerMinute); limit > 0 {
+		b.requests = min(b.requests+elapsed*limit, limit)
+	}
+	if limit := float64(b.limit.TokensPerMinute); limit > 0 {
+		b.tokens = min(b.tokens+elapsed*limit, limit)
+	}
+}
+
+// waitFor returns how long until available reaches need at perMinute.
+func waitFor(available, need float64, perMinute int) time.Duration {
+	if perMinute <= 0 || available >= need {
+		return 0
+	}
+	minutes := (need - available) / float64(perMinute)
+	return time.Duration(math.Ceil(minutes * float64(time.Minute)))
+}
@@ -0,0 +1,302 @@
+package client_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mwazovzky/assistant"
+	"github.com/mwazovzky/assistant/http/client"
+	"github.com/mwazovzky/assistant/tokenizer"
+)
+
+// StubClient is an assistant.HttpClient that answers every request with the
+// same reply and records the models it was called with.
+type StubClient struct {
+	mu     sync.Mutex
+	reply  string
+	usage  assistant.Usage
+	err    error
+	models []string
+}
+
+func (c *StubClient) Request(model string, msgs []assistant.Message) (assistant.Message, assistant.Usage, error) {
+	return c.RequestContext(context.Background(), model, msgs, assistant.RequestOptions{})
+}
+
+func (c *StubClient) RequestContext(ctx context.Context, model string, msgs []assistant.Message, opts assistant.RequestOptions) (assistant.Message, assistant.Usage, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.models = append(c.models, model)
+	if c.err != nil {
+		return assistant.Message{}, assistant.Usage{}, c.err
+	}
+	return assistant.Message{Role: assistant.RoleAssistant, Content: c.reply}, c.usage, nil
+}
+
+func (c *StubClient) Calls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.models)
+}
+
+func fixedEstimate(n int) client.TokenEstimator {
+	return func(string, []assistant.Message) int { return n }
+}
+
+var hello = []assistant.Message{{Role: assistant.RoleUser, Content: "hello"}}
+
+func TestRateLimiter_RequestsPerMinute(t *testing.T) {
+	stub := &StubClient{reply: "hi"}
+	limiter := client.NewRateLimiter(stub, client.RateLimit{RequestsPerMinute: 2})
+	limiter.SetFailFast(true)
+
+	for range 2 {
+		msg, _, err := limiter.Request("gpt-4o-mini", hello)
+		require.NoError(t, err)
+		assert.Equal(t, "hi", msg.Content)
+	}
+
+	_, _, err := limiter.Request("gpt-4o-mini", hello)
+
+	var limitErr *client.RateLimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.ErrorIs(t, err, client.ErrRateLimited)
+	assert.Equal(t, "gpt-4o-mini", limitErr.Model)
+	assert.InDelta(t, 30*time.Second, limitErr.RetryAfter, float64(time.Second))
+	assert.Equal(t, 2, stub.Calls())
+}
+
+func TestRateLimiter_TokensPerMinute(t *testing.T) {
+	stub := &StubClient{reply: "hi"}
+	limiter := client.NewRateLimiter(stub, client.RateLimit{TokensPerMinute: 1000})
+	limiter.SetFailFast(true)
+	limiter.SetEstimator(fixedEstimate(400))
+
+	_, _, err := limiter.Request("gpt-4o-mini", hello)
+	require.NoError(t, err)
+	_, _, err = limiter.Request("gpt-4o-mini", hello)
+	require.NoError(t, err)
+	_, _, err = limiter.Request("gpt-4o-mini", hello)
+
+	assert.ErrorIs(t, err, client.ErrRateLimited)
+	assert.Equal(t, 2, stub.Calls())
+}
+
+func TestRateLimiter_MaxTokensCountsTowardsEstimate(t *testing.T) {
+	stub := &StubClient{reply: "hi"}
+	limiter := client.NewRateLimiter(stub, client.RateLimit{TokensPerMinute: 1000})
+	limiter.SetFailFast(true)
+	limiter.SetEstimator(fixedEstimate(100))
+	maxTokens := 500
+	opts := assistant.RequestOptions{MaxTokens: &maxTokens}
+
+	_, _, err := limiter.RequestContext(context.Background(), "gpt-4o-mini", hello, opts)
+	require.NoError(t, err)
+	_, _, err = limiter.RequestContext(context.Background(), "gpt-4o-mini", hello, opts)
+
+	assert.ErrorIs(t, err, client.ErrRateLimited)
+}
+
+func TestRateLimiter_ReconcilesWithUsage(t *testing.T) {
+	stub := &StubClient{reply: "hi", usage: assistant.Usage{TotalTokens: 1000}}
+	limiter := client.NewRateLimiter(stub, client.RateLimit{TokensPerMinute: 1000})
+	limiter.SetFailFast(true)
+	limiter.SetEstimator(fixedEstimate(10))
+
+	_, _, err := limiter.Request("gpt-4o-mini", hello)
+	require.NoError(t, err)
+	_, _, err = limiter.Request("gpt-4o-mini", hello)
+
+	assert.ErrorIs(t, err, client.ErrRateLimited)
+	assert.Equal(t, 1, stub.Calls())
+}
+
+func TestRateLimiter_RefundsFailedRequests(t *testing.T) {
+	stub := &StubClient{err: errors.New("boom")}
+	limiter := client.NewRateLimiter(stub, client.RateLimit{TokensPerMinute: 1000})
+	limiter.SetFailFast(true)
+	limiter.SetEstimator(fixedEstimate(600))
+
+	_, _, err := limiter.Request("gpt-4o-mini", hello)
+	assert.EqualError(t, err, "boom")
+	_, _, err = limiter.Request("gpt-4o-mini", hello)
+	assert.EqualError(t, err, "boom")
+
+	assert.Equal(t, 2, stub.Calls())
+}
+
+func TestRateLimiter_PerModelBuckets(t *testing.T) {
+	stub := &StubClient{reply: "hi"}
+	limiter := client.NewRateLimiter(stub, client.RateLimit{RequestsPerMinute: 1})
+	limiter.SetModelLimit("gpt-4o", client.RateLimit{RequestsPerMinute: 2})
+	limiter.SetFailFast(true)
+
+	// gpt-4o-2024-08-06 shares the gpt-4o limit but has its own bucket.
+	for _, model := range []string{"gpt-4o", "gpt-4o", "gpt-4o-2024-08-06", "gpt-4o-2024-08-06", "o3-mini"} {
+		_, _, err := limiter.Request(model, hello)
+		require.NoError(t, err, model)
+	}
+
+	_, _, err := limiter.Request("gpt-4o", hello)
+	assert.ErrorIs(t, err, client.ErrRateLimited)
+	_, _, err = limiter.Request("o3-mini", hello)
+	assert.ErrorIs(t, err, client.ErrRateLimited)
+}
+
+func TestRateLimiter_Blocks(t *testing.T) {
+	stub := &StubClient{reply: "hi"}
+	limiter := client.NewRateLimiter(stub, client.RateLimit{TokensPerMinute: 6000})
+	limiter.SetEstimator(fixedEstimate(6000))
+
+	_, _, err := limiter.Request("gpt-4o-mini", hello)
+	require.NoError(t, err)
+
+	// 6000 tokens per minute refill 100 tokens per second.
+	limiter.SetEstimator(fixedEstimate(10))
+	start := time.Now()
+	_, _, err = limiter.Request("gpt-4o-mini", hello)
+
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+	assert.Equal(t, 2, stub.Calls())
+}
+
+func TestRateLimiter_OverLimitRequest(t *testing.T) {
+	stub := &StubClient{reply: "hi", usage: assistant.Usage{TotalTokens: 5000}}
+	limiter := client.NewRateLimiter(stub, client.RateLimit{TokensPerMinute: 1000})
+	limiter.SetFailFast(true)
+	limiter.SetEstimator(fixedEstimate(5000))
+
+	// Only the full bucket is reserved, so the 5000 tokens used leave a debt
+	// of 4000 that takes four minutes to repay.
+	_, _, err := limiter.Request("gpt-4o-mini", hello)
+	require.NoError(t, err)
+
+	limiter.SetEstimator(fixedEstimate(100))
+	_, _, err = limiter.Request("gpt-4o-mini", hello)
+
+	var rateErr *client.RateLimitError
+	require.ErrorAs(t, err, &rateErr)
+	assert.Greater(t, rateErr.RetryAfter, 4*time.Minute)
+}
+
+func TestRateLimiter_OverLimitRequestFails(t *testing.T) {
+	stub := &StubClient{err: errors.New("boom")}
+	limiter := client.NewRateLimiter(stub, client.RateLimit{TokensPerMinute: 1000})
+	limiter.SetFailFast(true)
+	limiter.SetEstimator(fixedEstimate(5000))
+
+	_, _, err := limiter.Request("gpt-4o-mini", hello)
+	require.EqualError(t, err, "boom")
+
+	// The refund restores exactly the reserved full bucket, no more.
+	stub.err = nil
+	limiter.SetEstimator(fixedEstimate(1000))
+	_, _, err = limiter.Request("gpt-4o-mini", hello)
+	require.NoError(t, err)
+
+	limiter.SetEstimator(fixedEstimate(10))
+	_, _, err = limiter.Request("gpt-4o-mini", hello)
+	assert.ErrorIs(t, err, client.ErrRateLimited)
+}
+
+func TestRateLimiter_BlockingRespectsContext(t *testing.T) {
+	stub := &StubClient{reply: "hi"}
+	limiter := client.NewRateLimiter(stub, client.RateLimit{RequestsPerMinute: 1})
+
+	_, _, err := limiter.Request("gpt-4o-mini", hello)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, _, err = limiter.RequestContext(ctx, "gpt-4o-mini", hello, assistant.RequestOptions{})
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, stub.Calls())
+}
+
+// PlainClient implements only assistant.HttpClient.
+type PlainClient struct {
+	calls int
+}
+
+func (c *PlainClient) Request(model string, msgs []assistant.Message) (assistant.Message, assistant.Usage, error) {
+	c.calls++
+	return assistant.Message{Role: assistant.RoleAssistant, Content: "hi"}, assistant.Usage{}, nil
+}
+
+func TestRateLimiter_PlainClient(t *testing.T) {
+	plain := &PlainClient{}
+	limiter := client.NewRateLimiter(plain, client.RateLimit{RequestsPerMinute: 10})
+
+	msg, _, err := limiter.Request("gpt-4o-mini", hello)
+	require.NoError(t, err)
+	assert.Equal(t, "hi", msg.Content)
+
+	// options the client cannot carry are an error, not silently dropped
+	temperature := 0.5
+	_, _, err = limiter.RequestContext(context.Background(), "gpt-4o-mini", hello, assistant.RequestOptions{Temperature: &temperature})
+	assert.ErrorIs(t, err, assistant.ErrOptionsUnsupported)
+	assert.Equal(t, 1, plain.calls)
+}
+
+func TestRateLimiter_StreamFallsBackToSingleDelta(t *testing.T) {
+	stub := &StubClient{reply: "hi there"}
+	limiter := client.NewRateLimiter(stub, client.RateLimit{RequestsPerMinute: 10})
+
+	var deltas []string
+	msg, _, err := limiter.RequestStream(context.Background(), "gpt-4o-mini", hello, assistant.RequestOptions{}, func(d string) error {
+		deltas = append(deltas, d)
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "hi there", msg.Content)
+	assert.Equal(t, []string{"hi there"}, deltas)
+}
+
+func TestEstimateRequestTokens(t *testing.T) {
+	msgs := []assistant.Message{
+		{Role: assistant.RoleSystem, Content: "You are helpful"},
+		{Role: assistant.RoleUser, Content: "hello"},
+	}
+
+	assert.Positive(t, client.EstimateRequestTokens("unknown-model", msgs))
+}
+
+// byteEncoding is an encoding with one token per byte, registered in place of
+// o200k_base so the tokenizer path runs without the embedded rank files.
+func byteEncoding(t *testing.T) *tokenizer.Encoding {
+	t.Helper()
+	var ranks strings.Builder
+	for b := range 256 {
+		fmt.Fprintf(&ranks, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(b)}), b)
+	}
+	parsed, err := tokenizer.ParseRanks(strings.NewReader(ranks.String()))
+	require.NoError(t, err)
+	enc, err := tokenizer.NewEncoding(tokenizer.O200kBase, tokenizer.O200kPattern, parsed)
+	require.NoError(t, err)
+	return enc
+}
+
+func TestEstimateRequestTokens_Tokenizer(t *testing.T) {
+	tokenizer.Register(byteEncoding(t))
+	msgs := []assistant.Message{{Role: assistant.RoleUser, Content: "hello world"}}
+
+	expected, err := tokenizer.CountTokens("gpt-4o", msgs)
+	require.NoError(t, err)
+
+	assert.Equal(t, expected, client.EstimateRequestTokens("gpt-4o", msgs))
+	// user: 3 + 4 (u,s,e,r) + 11 (hello world), reply priming: 3
+	assert.Equal(t, 21, expected)
+}
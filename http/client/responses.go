@@ -0,0 +1,273 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mwazovzky/assistant"
+)
+
+const DefaultResponsesURL = "https://api.openai.com/v1/responses"
+
+// Input items have one type per kind so their required fields are sent even
+// when empty.
+type responsesMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type responsesFunctionCall struct {
+	Type      string `json:"type"`
+	CallID    string `json:"call_id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+type responsesFunctionCallOutput struct {
+	Type   string `json:"type"`
+	CallID string `json:"call_id"`
+	Output string `json:"output"`
+}
+
+type responsesTool struct {
+	Type        string          `json:"type"`
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+type responsesFormat struct {
+	Type   string          `json:"type"`
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+	Strict bool            `json:"strict"`
+}
+
+type responsesText struct {
+	Format responsesFormat `json:"format"`
+}
+
+type responsesRequest struct {
+	Model              string          `json:"model"`
+	Input              []any           `json:"input"`
+	PreviousResponseID string          `json:"previous_response_id,omitempty"`
+	Store              *bool           `json:"store,omitempty"`
+	Tools              []responsesTool `json:"tools,omitempty"`
+	Text               *responsesText  `json:"text,omitempty"`
+	Temperature        *float64        `json:"temperature,omitempty"`
+	TopP               *float64        `json:"top_p,omitempty"`
+	MaxOutputTokens    *int            `json:"max_output_tokens,omitempty"`
+	User               string          `json:"user,omitempty"`
+}
+
+type responsesOutputContent struct {
+	Type    string `json:"type"`
+	Text    string `json:"text"`
+	Refusal string `json:"refusal"`
+}
+
+type responsesOutput struct {
+	Type      string                   `json:"type"`
+	Role      string                   `json:"role"`
+	Content   []responsesOutputContent `json:"content"`
+	CallID    string                   `json:"call_id"`
+	Name      string                   `json:"name"`
+	Arguments string                   `json:"arguments"`
+}
+
+type responsesUsage struct {
+	InputTokens        int `json:"input_tokens"`
+	OutputTokens       int `json:"output_tokens"`
+	TotalTokens        int `json:"total_tokens"`
+	InputTokensDetails struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"input_tokens_details"`
+}
+
+func (u responsesUsage) toUsage() assistant.Usage {
+	return assistant.Usage{
+		PromptTokens:     u.InputTokens,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      u.TotalTokens,
+		CachedTokens:     u.InputTokensDetails.CachedTokens,
+	}
+}
+
+type responsesResponse struct {
+	ID                string            `json:"id"`
+	Status            string            `json:"status"`
+	Output            []responsesOutput `json:"output"`
+	Usage             responsesUsage    `json:"usage"`
+	IncompleteDetails *struct {
+		Reason string `json:"reason"`
+	} `json:"incomplete_details"`
+}
+
+// ResponsesClient implements assistant.HttpClient and
+// assistant.StatefulHttpClient over the OpenAI Responses API. With
+// RequestStateful responses are stored on the server and a conversation is
+// continued from the previous response ID, so only new messages are sent.
+//
+// Stop sequences, seeds and penalties have no equivalent in the Responses API
+// and are not sent.
+type ResponsesClient struct {
+	url        string
+	apiKey     string
+	httpClient HttpDoer
+}
+
+func NewResponsesClient(url string, apiKey string) *ResponsesClient {
+	return &ResponsesClient{
+		url:        url,
+		apiKey:     apiKey,
+		httpClient: &http.Client{},
+	}
+}
+
+func (c *ResponsesClient) SetHttpClient(httpClient HttpDoer) {
+	c.httpClient = httpClient
+}
+
+// SetRetryPolicy wraps the current HttpDoer of the client in a RetryDoer.
+func (c *ResponsesClient) SetRetryPolicy(policy RetryPolicy) {
+	c.httpClient = NewRetryDoer(c.httpClient, policy)
+}
+
+func (c *ResponsesClient) Request(model string, messages []assistant.Message) (assistant.Message, assistant.Usage, error) {
+	return c.RequestContext(context.Background(), model, messages, assistant.RequestOptions{})
+}
+
+// RequestContext sends the whole conversation without storing the response.
+func (c *ResponsesClient) RequestContext(ctx context.Context, model string, messages []assistant.Message, opts assistant.RequestOptions) (assistant.Message, assistant.Usage, error) {
+	store := false
+	req := newResponsesRequest(model, messages, opts)
+	req.Store = &store
+
+	msg, usage, _, err := c.send(ctx, req)
+	return msg, usage, err
+}
+
+// RequestStateful stores the response on the server and continues from
+// previousResponseID when it is set. A previous response the server no longer
+// knows is reported with an error matching both assistant.ErrResponseNotFound
+// and *APIError.
+func (c *ResponsesClient) RequestStateful(ctx context.Context, model string, previousResponseID string, messages []assistant.Message, opts assistant.RequestOptions) (assistant.Message, assistant.Usage, string, error) {
+	store := true
+	req := newResponsesRequest(model, messages, opts)
+	req.Store = &store
+	req.PreviousResponseID = previousResponseID
+
+	return c.send(ctx, req)
+}
+
+func (c *ResponsesClient) send(ctx context.Context, req responsesRequest) (assistant.Message, assistant.Usage, string, error) {
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		return assistant.Message{}, assistant.Usage{}, "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(reqBody))
+	if err != nil {
+		return assistant.Message{}, assistant.Usage{}, "", fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
+
+	httpRes, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return assistant.Message{}, assistant.Usage{}, "", fmt.Errorf("http request failed: %w", err)
+	}
+	defer httpRes.Body.Close()
+
+	if httpRes.StatusCode != http.StatusOK {
+		apiErr := newAPIError(httpRes)
+		if apiErr.Code == "previous_response_not_found" {
+			return assistant.Message{}, assistant.Usage{}, "", fmt.Errorf("%w: %w", assistant.ErrResponseNotFound, apiErr)
+		}
+		return assistant.Message{}, assistant.Usage{}, "", apiErr
+	}
+
+	var res responsesResponse
+	if err := json.NewDecoder(httpRes.Body).Decode(&res); err != nil {
+		return assistant.Message{}, assistant.Usage{}, "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	usage := res.Usage.toUsage()
+
+	if d := res.IncompleteDetails; d != nil && d.Reason == "content_filter" {
+		return assistant.Message{}, usage, "", contentFilterError(httpRes)
+	}
+
+	msg, refusal := res.message()
+	if refusal != "" {
+		apiErr := contentFilterError(httpRes)
+		apiErr.Message = refusal
+		return assistant.Message{}, usage, "", apiErr
+	}
+	return msg, usage, res.ID, nil
+}
+
+// message assembles the output items into a message. Reasoning and other
+// item types are skipped. A refusal is returned separately.
+func (r responsesResponse) message() (assistant.Message, string) {
+	msg := assistant.Message{Role: assistant.RoleAssistant}
+	var content, refusal strings.Builder
+	for _, item := range r.Output {
+		switch item.Type {
+		case "message":
+			for _, part := range item.Content {
+				switch part.Type {
+				case "output_text":
+					content.WriteString(part.Text)
+				case "refusal":
+					refusal.WriteString(part.Refusal)
+				}
+			}
+		case "function_call":
+			msg.ToolCalls = append(msg.ToolCalls, assistant.ToolCall{
+				ID:       item.CallID,
+				Type:     "function",
+				Function: assistant.FunctionCall{Name: item.Name, Arguments: item.Arguments},
+			})
+		}
+	}
+	msg.Content = content.String()
+	return msg, refusal.String()
+}
+
+func newResponsesRequest(model string, messages []assistant.Message, opts assistant.RequestOptions) responsesRequest {
+	req := responsesRequest{
+		Model:           model,
+		Input:           []any{},
+		Temperature:     opts.Temperature,
+		TopP:            opts.TopP,
+		MaxOutputTokens: opts.MaxTokens,
+		User:            opts.User,
+	}
+	for _, t := range opts.Tools {
+		req.Tools = append(req.Tools, responsesTool{Type: "function", Name: t.Name, Description: t.Description, Parameters: t.Parameters})
+	}
+	if f := opts.ResponseFormat; f != nil {
+		req.Text = &responsesText{Format: responsesFormat{Type: "json_schema", Name: f.Name, Schema: f.Schema, Strict: f.Strict}}
+	}
+
+	for _, msg := range messages {
+		switch {
+		case msg.Role == assistant.RoleTool:
+			req.Input = append(req.Input, responsesFunctionCallOutput{Type: "function_call_output", CallID: msg.ToolCallID, Output: msg.Content})
+		case len(msg.ToolCalls) > 0:
+			if msg.Content != "" {
+				req.Input = append(req.Input, responsesMessage{Role: msg.Role, Content: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				req.Input = append(req.Input, responsesFunctionCall{Type: "function_call", CallID: call.ID, Name: call.Function.Name, Arguments: call.Function.Arguments})
+			}
+		default:
+			req.Input = append(req.Input, responsesMessage{Role: msg.Role, Content: msg.Content})
+		}
+	}
+	return req
+}
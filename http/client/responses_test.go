@@ -0,0 +1,246 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mwazovzky/assistant"
+	"github.com/mwazovzky/assistant/http/client"
+)
+
+// responsesServer answers with the given responses in turn and records the
+// request bodies.
+func responsesServer(t *testing.T, responses ...string) (*httptest.Server, func() []map[string]any) {
+	t.Helper()
+	var mu sync.Mutex
+	var bodies []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		assert.Equal(t, "Bearer test-api-key", r.Header.Get("Authorization"))
+		var body map[string]any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		bodies = append(bodies, body)
+
+		response := responses[min(len(bodies), len(responses))-1]
+		var status struct {
+			Status int `json:"status"`
+		}
+		json.Unmarshal([]byte(response), &status)
+		if status.Status != 0 {
+			w.WriteHeader(status.Status)
+		}
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []map[string]any {
+		mu.Lock()
+		defer mu.Unlock()
+		return bodies
+	}
+}
+
+const textResponse = `{
+	"id": "resp_1", "status": "completed",
+	"output": [
+		{"type": "reasoning", "summary": []},
+		{"type": "message", "role": "assistant", "content": [{"type": "output_text", "text": "2+2=4"}]}
+	],
+	"usage": {"input_tokens": 10, "output_tokens": 5, "total_tokens": 15, "input_tokens_details": {"cached_tokens": 2}}
+}`
+
+func TestResponsesClient_Request(t *testing.T) {
+	server, bodies := responsesServer(t, textResponse)
+
+	maxTokens := 100
+	c := client.NewResponsesClient(server.URL, "test-api-key")
+	msg, usage, err := c.RequestContext(context.Background(), "gpt-4o", []assistant.Message{
+		{Role: assistant.RoleSystem, Content: "You are a calculator"},
+		{Role: assistant.RoleUser, Content: "What is 2+2?"},
+	}, assistant.RequestOptions{MaxTokens: &maxTokens})
+
+	require.NoError(t, err)
+	assert.Equal(t, assistant.Message{Role: assistant.RoleAssistant, Content: "2+2=4"}, msg)
+	assert.Equal(t, assistant.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15, CachedTokens: 2}, usage)
+	assert.Equal(t, map[string]any{
+		"model":             "gpt-4o",
+		"store":             false,
+		"max_output_tokens": float64(100),
+		"input": []any{
+			map[string]any{"role": "system", "content": "You are a calculator"},
+			map[string]any{"role": "user", "content": "What is 2+2?"},
+		},
+	}, bodies()[0])
+}
+
+func TestResponsesClient_RequestStateful(t *testing.T) {
+	server, bodies := responsesServer(t, textResponse)
+
+	c := client.NewResponsesClient(server.URL, "test-api-key")
+	_, _, id, err := c.RequestStateful(context.Background(), "gpt-4o", "resp_0", []assistant.Message{
+		{Role: assistant.RoleUser, Content: "What is 2+2?"},
+	}, assistant.RequestOptions{})
+
+	require.NoError(t, err)
+	assert.Equal(t, "resp_1", id)
+	assert.Equal(t, "resp_0", bodies()[0]["previous_response_id"])
+	assert.Equal(t, true, bodies()[0]["store"])
+}
+
+func TestResponsesClient_Tools(t *testing.T) {
+	server, bodies := responsesServer(t, `{
+		"id": "resp_2",
+		"output": [{"type": "function_call", "call_id": "call_2", "name": "get_weather", "arguments": "{\"city\":\"Rome\"}"}]
+	}`)
+
+	c := client.NewResponsesClient(server.URL, "test-api-key")
+	msg, _, err := c.RequestContext(context.Background(), "gpt-4o", []assistant.Message{
+		{Role: assistant.RoleUser, Content: "Weather?"},
+		{Role: assistant.RoleAssistant, ToolCalls: []assistant.ToolCall{
+			{ID: "call_1", Type: "function", Function: assistant.FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+		}},
+		{Role: assistant.RoleTool, ToolCallID: "call_1", Content: "sunny"},
+	}, assistant.RequestOptions{
+		Tools:          []assistant.ToolDefinition{{Name: "get_weather", Parameters: json.RawMessage(`{"type":"object"}`)}},
+		ResponseFormat: &assistant.ResponseFormat{Name: "Weather", Schema: json.RawMessage(`{"type":"object"}`), Strict: true},
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []assistant.ToolCall{
+		{ID: "call_2", Type: "function", Function: assistant.FunctionCall{Name: "get_weather", Arguments: `{"city":"Rome"}`}},
+	}, msg.ToolCalls)
+
+	body := bodies()[0]
+	assert.Equal(t, []any{
+		map[string]any{"role": "user", "content": "Weather?"},
+		map[string]any{"type": "function_call", "call_id": "call_1", "name": "get_weather", "arguments": `{"city":"Paris"}`},
+		map[string]any{"type": "function_call_output", "call_id": "call_1", "output": "sunny"},
+	}, body["input"])
+	assert.Equal(t, []any{map[string]any{"type": "function", "name": "get_weather", "parameters": map[string]any{"type": "object"}}}, body["tools"])
+	assert.Equal(t, map[string]any{"format": map[string]any{
+		"type": "json_schema", "name": "Weather", "schema": map[string]any{"type": "object"}, "strict": true,
+	}}, body["text"])
+}
+
+func TestResponsesClient_EmptyItems(t *testing.T) {
+	server, bodies := responsesServer(t, textResponse)
+
+	c := client.NewResponsesClient(server.URL, "test-api-key")
+	_, _, err := c.RequestContext(context.Background(), "gpt-4o", []assistant.Message{
+		{Role: assistant.RoleUser, Content: ""},
+		{Role: assistant.RoleAssistant, ToolCalls: []assistant.ToolCall{
+			{ID: "call_1", Type: "function", Function: assistant.FunctionCall{Name: "reset"}},
+		}},
+		{Role: assistant.RoleTool, ToolCallID: "call_1", Content: ""},
+	}, assistant.RequestOptions{})
+
+	// required fields are sent even when empty
+	require.NoError(t, err)
+	assert.Equal(t, []any{
+		map[string]any{"role": "user", "content": ""},
+		map[string]any{"type": "function_call", "call_id": "call_1", "name": "reset", "arguments": ""},
+		map[string]any{"type": "function_call_output", "call_id": "call_1", "output": ""},
+	}, bodies()[0]["input"])
+}
+
+func TestResponsesClient_Errors(t *testing.T) {
+	type testCase struct {
+		name     string
+		response string
+		check    func(error) bool
+	}
+
+	tests := []testCase{
+		{
+			name:     "Previous Response Not Found",
+			response: `{"status": 404, "error": {"message": "Previous response with id 'resp_0' not found.", "type": "invalid_request_error", "param": "previous_response_id", "code": "previous_response_not_found"}}`,
+			check: func(err error) bool {
+				var apiErr *client.APIError
+				return assert.ErrorIs(t, err, assistant.ErrResponseNotFound) && assert.ErrorAs(t, err, &apiErr)
+			},
+		},
+		{
+			name:     "Rate Limited",
+			response: `{"status": 429, "error": {"message": "Rate limit reached", "code": "rate_limit_exceeded"}}`,
+			check:    client.IsRateLimited,
+		},
+		{
+			name:     "Refusal",
+			response: `{"id": "resp_1", "output": [{"type": "message", "role": "assistant", "content": [{"type": "refusal", "refusal": "I can't help with that."}]}]}`,
+			check:    client.IsContentFiltered,
+		},
+		{
+			name:     "Incomplete",
+			response: `{"id": "resp_1", "status": "incomplete", "incomplete_details": {"reason": "content_filter"}, "output": []}`,
+			check:    client.IsContentFiltered,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server, _ := responsesServer(t, tc.response)
+
+			c := client.NewResponsesClient(server.URL, "test-api-key")
+			_, _, _, err := c.RequestStateful(context.Background(), "gpt-4o", "resp_0", []assistant.Message{{Role: assistant.RoleUser, Content: "hi"}}, assistant.RequestOptions{})
+
+			require.Error(t, err)
+			assert.True(t, tc.check(err))
+		})
+	}
+}
+
+func TestResponsesClient_AssistantServerState(t *testing.T) {
+	server, bodies := responsesServer(t,
+		`{"id": "resp_1", "output": [{"type": "message", "role": "assistant", "content": [{"type": "output_text", "text": "4"}]}]}`,
+		`{"status": 404, "error": {"message": "expired", "code": "previous_response_not_found"}}`,
+		`{"id": "resp_3", "output": [{"type": "message", "role": "assistant", "content": [{"type": "output_text", "text": "6"}]}]}`,
+		`{"id": "resp_4", "output": [{"type": "message", "role": "assistant", "content": [{"type": "output_text", "text": "8"}]}]}`,
+	)
+
+	a := assistant.NewAssistant("gpt-4o", "You are a calculator", client.NewResponsesClient(server.URL, "test-api-key"), newMetadataThreads())
+	a.SetServerState(true)
+
+	for _, q := range []string{"2+2?", "3+3?", "4+4?"} {
+		_, err := a.Ask("thread-1", q)
+		require.NoError(t, err)
+	}
+
+	sent := bodies()
+	require.Len(t, sent, 4)
+	assert.NotContains(t, sent[0], "previous_response_id")
+	assert.Len(t, sent[0]["input"], 2)
+	// The second turn tries the stored response, then replays everything.
+	assert.Equal(t, "resp_1", sent[1]["previous_response_id"])
+	assert.Equal(t, []any{map[string]any{"role": "user", "content": "3+3?"}}, sent[1]["input"])
+	assert.NotContains(t, sent[2], "previous_response_id")
+	assert.Len(t, sent[2]["input"], 4)
+	assert.Equal(t, "resp_3", sent[3]["previous_response_id"])
+	assert.Equal(t, []any{map[string]any{"role": "user", "content": "4+4?"}}, sent[3]["input"])
+}
+
+type metadataThreads struct {
+	memoryThreads
+	metadata map[string]string
+}
+
+func newMetadataThreads() *metadataThreads {
+	return &metadataThreads{memoryThreads: newMemoryThreads(), metadata: map[string]string{}}
+}
+
+func (m *metadataThreads) GetMetadata(tid string, key string) (string, bool, error) {
+	value, ok := m.metadata[tid+"/"+key]
+	return value, ok, nil
+}
+
+func (m *metadataThreads) SetMetadata(tid string, key string, value string) error {
+	m.metadata[tid+"/"+key] = value
+	return nil
+}
@@ -0,0 +1,196 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy configures RetryDoer. Zero fields take the defaults below.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry. It doubles with every
+	// further attempt.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff and any delay requested by the server.
+	MaxDelay time.Duration
+}
+
+const (
+	DefaultMaxAttempts = 3
+	DefaultBaseDelay   = 500 * time.Millisecond
+	DefaultMaxDelay    = 30 * time.Second
+)
+
+// RetryDoer is an HttpDoer that retries transient failures: 429, 500, 502,
+// 503 and 504 responses and connection errors. Other responses, including
+// 400s, are returned immediately. Delays follow jittered exponential backoff
+// unless the server asks for a specific delay with Retry-After, retry-after-ms
+// or x-ratelimit-reset-* headers. Waiting stops when the request context is
+// done.
+type RetryDoer struct {
+	doer   HttpDoer
+	policy RetryPolicy
+}
+
+func NewRetryDoer(doer HttpDoer, policy RetryPolicy) *RetryDoer {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = DefaultMaxAttempts
+	}
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = DefaultBaseDelay
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = DefaultMaxDelay
+	}
+	return &RetryDoer{doer: doer, policy: policy}
+}
+
+// SetRetryPolicy wraps the current HttpDoer of the client in a RetryDoer.
+func (c *OpenAiClient) SetRetryPolicy(policy RetryPolicy) {
+	c.httpClient = NewRetryDoer(c.httpClient, policy)
+}
+
+func (d *RetryDoer) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	for attempt := 1; ; attempt++ {
+		r, err := rewind(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := d.doer.Do(r)
+		if attempt >= d.policy.MaxAttempts || !retryable(ctx, res, err) {
+			return res, err
+		}
+
+		delay := d.backoff(attempt)
+		if res != nil {
+			if wait, ok := serverDelay(res.Header); ok {
+				delay = min(wait, d.policy.MaxDelay)
+			}
+			io.Copy(io.Discard, io.LimitReader(res.Body, maxErrorBody))
+			res.Body.Close()
+		}
+
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// rewind returns req for the given attempt with a fresh body.
+func rewind(req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 1 || req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+	if req.GetBody == nil {
+		return nil, fmt.Errorf("request body cannot be replayed for retry")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay request body: %w", err)
+	}
+	r := req.Clone(req.Context())
+	r.Body = body
+	return r, nil
+}
+
+func retryable(ctx context.Context, res *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return retryableError(err)
+	}
+
+	switch res.StatusCode {
+	case http.StatusTooManyRequests:
+		return !quotaExhausted(res)
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// quotaExhausted reports whether a 429 response is an exhausted quota, which
+// waiting does not fix. The body is restored for the caller.
+func quotaExhausted(res *http.Response) bool {
+	data, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+	res.Body.Close()
+	res.Body = io.NopCloser(bytes.NewReader(data))
+	return bytes.Contains(data, []byte("insufficient_quota"))
+}
+
+func retryableError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// backoff returns a random delay between half and all of the exponential
+// delay for attempt.
+func (d *RetryDoer) backoff(attempt int) time.Duration {
+	delay := d.policy.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > d.policy.MaxDelay {
+		delay = d.policy.MaxDelay
+	}
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// serverDelay reads the delay requested by the server. retry-after-ms and
+// Retry-After take precedence over the rate limit reset headers; of those the
+// longest is used.
+func serverDelay(h http.Header) (time.Duration, bool) {
+	if v := h.Get("retry-after-ms"); v != "" {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil && ms >= 0 {
+			return time.Duration(ms * float64(time.Millisecond)), true
+		}
+	}
+	if v := h.Get("Retry-After"); v != "" {
+		if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+			return time.Duration(s) * time.Second, true
+		}
+		if t, err := http.ParseTime(v); err == nil {
+			return max(time.Until(t), 0), true
+		}
+	}
+
+	var delay time.Duration
+	found := false
+	for _, name := range []string{"x-ratelimit-reset-requests", "x-ratelimit-reset-tokens"} {
+		if d, err := time.ParseDuration(strings.TrimSpace(h.Get(name))); err == nil {
+			delay, found = max(delay, d), true
+		}
+	}
+	return delay, found
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,237 @@
+package client_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mwazovzky/assistant"
+	"github.com/mwazovzky/assistant/http/client"
+)
+
+type step struct {
+	status int
+	header http.Header
+	body   string
+	err    error
+}
+
+// ScriptedDoer replays a fixed sequence of responses and records the bodies
+// of the requests it receives.
+type ScriptedDoer struct {
+	mu     sync.Mutex
+	steps  []step
+	bodies []string
+}
+
+func (d *ScriptedDoer) Do(req *http.Request) (*http.Response, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	body, _ := io.ReadAll(req.Body)
+	d.bodies = append(d.bodies, string(body))
+
+	s := d.steps[0]
+	if len(d.steps) > 1 {
+		d.steps = d.steps[1:]
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	rec := httptest.NewRecorder()
+	for k, v := range s.header {
+		rec.Header()[k] = v
+	}
+	rec.WriteHeader(s.status)
+	rec.Body.WriteString(s.body)
+	return rec.Result(), nil
+}
+
+func (d *ScriptedDoer) Attempts() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.bodies)
+}
+
+const okBody = `{
+	"choices": [{"message": {"role": "assistant", "content": "ok"}}],
+	"usage": {"prompt_tokens": 1, "completion_tokens": 1, "total_tokens": 2}
+}`
+
+var fastRetry = client.RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+func newRetryingClient(doer client.HttpDoer, policy client.RetryPolicy) *client.OpenAiClient {
+	c := client.NewOpenAiClient("http://example.com", "test-api-key")
+	c.SetHttpClient(doer)
+	c.SetRetryPolicy(policy)
+	return c
+}
+
+func TestRetry_TransientFailures(t *testing.T) {
+	statuses := []int{
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	}
+
+	for _, status := range statuses {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			doer := &ScriptedDoer{steps: []step{
+				{status: status, body: `{"error": {"message": "try again"}}`},
+				{status: status, body: `{"error": {"message": "try again"}}`},
+				{status: http.StatusOK, body: okBody},
+			}}
+			c := newRetryingClient(doer, fastRetry)
+
+			msg, _, err := c.Request("gpt-4o-mini", []assistant.Message{{Role: "user", Content: "hi"}})
+
+			require.NoError(t, err)
+			assert.Equal(t, "ok", msg.Content)
+			assert.Equal(t, 3, doer.Attempts())
+		})
+	}
+}
+
+func TestRetry_ReplaysBody(t *testing.T) {
+	doer := &ScriptedDoer{steps: []step{
+		{status: http.StatusServiceUnavailable},
+		{status: http.StatusOK, body: okBody},
+	}}
+	c := newRetryingClient(doer, fastRetry)
+
+	_, _, err := c.Request("gpt-4o-mini", []assistant.Message{{Role: "user", Content: "hi"}})
+
+	require.NoError(t, err)
+	require.Len(t, doer.bodies, 2)
+	assert.NotEmpty(t, doer.bodies[0])
+	assert.Equal(t, doer.bodies[0], doer.bodies[1])
+}
+
+func TestRetry_ConnectionReset(t *testing.T) {
+	doer := &ScriptedDoer{steps: []step{
+		{err: syscall.ECONNRESET},
+		{status: http.StatusOK, body: okBody},
+	}}
+	c := newRetryingClient(doer, fastRetry)
+
+	msg, _, err := c.Request("gpt-4o-mini", []assistant.Message{{Role: "user", Content: "hi"}})
+
+	require.NoError(t, err)
+	assert.Equal(t, "ok", msg.Content)
+	assert.Equal(t, 2, doer.Attempts())
+}
+
+func TestRetry_GivesUpAfterMaxAttempts(t *testing.T) {
+	doer := &ScriptedDoer{steps: []step{
+		{status: http.StatusServiceUnavailable, body: `{"error": {"message": "overloaded", "type": "server_error"}}`},
+	}}
+	c := newRetryingClient(doer, fastRetry)
+
+	_, _, err := c.Request("gpt-4o-mini", []assistant.Message{{Role: "user", Content: "hi"}})
+
+	require.Error(t, err)
+	assert.True(t, client.IsServerError(err))
+	assert.Contains(t, err.Error(), "overloaded")
+	assert.Equal(t, fastRetry.MaxAttempts, doer.Attempts())
+}
+
+func TestRetry_DoesNotRetry(t *testing.T) {
+	type testCase struct {
+		name  string
+		step  step
+		check func(error) bool
+	}
+
+	tests := []testCase{
+		{
+			name:  "Bad Request",
+			step:  step{status: http.StatusBadRequest, body: `{"error": {"message": "bad", "code": "context_length_exceeded"}}`},
+			check: client.IsContextLengthExceeded,
+		},
+		{
+			name:  "Unauthorized",
+			step:  step{status: http.StatusUnauthorized, body: `{"error": {"message": "bad key", "code": "invalid_api_key"}}`},
+			check: client.IsAuthError,
+		},
+		{
+			name:  "Quota Exceeded",
+			step:  step{status: http.StatusTooManyRequests, body: `{"error": {"message": "quota", "type": "insufficient_quota", "code": "insufficient_quota"}}`},
+			check: client.IsQuotaExceeded,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			doer := &ScriptedDoer{steps: []step{tc.step, {status: http.StatusOK, body: okBody}}}
+			c := newRetryingClient(doer, fastRetry)
+
+			_, _, err := c.Request("gpt-4o-mini", []assistant.Message{{Role: "user", Content: "hi"}})
+
+			require.Error(t, err)
+			assert.True(t, tc.check(err))
+			assert.Equal(t, 1, doer.Attempts())
+		})
+	}
+}
+
+func TestRetry_HonoursServerDelay(t *testing.T) {
+	type testCase struct {
+		name   string
+		header http.Header
+	}
+
+	tests := []testCase{
+		{name: "Retry-After", header: http.Header{"Retry-After": {"1"}}},
+		{name: "retry-after-ms", header: http.Header{"Retry-After-Ms": {"1000"}}},
+		{name: "x-ratelimit-reset-requests", header: http.Header{"X-Ratelimit-Reset-Requests": {"1s"}}},
+		{name: "x-ratelimit-reset-tokens", header: http.Header{"X-Ratelimit-Reset-Tokens": {"100ms"}, "X-Ratelimit-Reset-Requests": {"1s"}}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			doer := &ScriptedDoer{steps: []step{
+				{status: http.StatusTooManyRequests, header: tc.header},
+				{status: http.StatusOK, body: okBody},
+			}}
+			policy := client.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: 50 * time.Millisecond}
+			c := newRetryingClient(doer, policy)
+
+			start := time.Now()
+			_, _, err := c.Request("gpt-4o-mini", []assistant.Message{{Role: "user", Content: "hi"}})
+			elapsed := time.Since(start)
+
+			require.NoError(t, err)
+			// The requested delay is capped at MaxDelay.
+			assert.GreaterOrEqual(t, elapsed, 50*time.Millisecond)
+			assert.Less(t, elapsed, time.Second)
+		})
+	}
+}
+
+func TestRetry_ContextCancelledDuringBackoff(t *testing.T) {
+	doer := &ScriptedDoer{steps: []step{
+		{status: http.StatusServiceUnavailable, header: http.Header{"Retry-After": {"30"}}},
+	}}
+	c := newRetryingClient(doer, client.RetryPolicy{MaxAttempts: 3, MaxDelay: time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, _, err := c.RequestContext(ctx, "gpt-4o-mini", []assistant.Message{{Role: "user", Content: "hi"}}, assistant.RequestOptions{})
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, 1, doer.Attempts())
+}
@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"math/rand/v2"
+
+	"github.com/mwazovzky/assistant"
+)
+
+// WeightedRoute is a Route that receives a share of the traffic proportional
+// to its Weight.
+type WeightedRoute struct {
+	Route
+	Weight int
+}
+
+// WeightedRouter is an assistant.HttpClient that splits traffic between
+// routes, for example to A/B test models. Requests with RequestOptions.User
+// set always go to the same route for the same user; others are routed at
+// random. Routes with a weight of zero or less receive no traffic.
+type WeightedRouter struct {
+	routes   []WeightedRoute
+	total    int
+	onServed func(Served)
+}
+
+func NewWeightedRouter(routes ...WeightedRoute) *WeightedRouter {
+	r := &WeightedRouter{}
+	for _, route := range routes {
+		if route.Weight > 0 {
+			r.routes = append(r.routes, route)
+			r.total += route.Weight
+		}
+	}
+	return r
+}
+
+// SetOnServed registers a callback that is told which route served each
+// successful request.
+func (r *WeightedRouter) SetOnServed(onServed func(Served)) {
+	r.onServed = onServed
+}
+
+func (r *WeightedRouter) Request(model string, msgs []assistant.Message) (assistant.Message, assistant.Usage, error) {
+	return r.RequestContext(context.Background(), model, msgs, assistant.RequestOptions{})
+}
+
+func (r *WeightedRouter) RequestContext(ctx context.Context, model string, msgs []assistant.Message, opts assistant.RequestOptions) (assistant.Message, assistant.Usage, error) {
+	route, err := r.pick(opts.User)
+	if err != nil {
+		return assistant.Message{}, assistant.Usage{}, err
+	}
+
+	msg, usage, err := requestContext(route.Client, ctx, route.model(model), msgs, opts)
+	return r.served(route, model, msg, usage, err)
+}
+
+func (r *WeightedRouter) RequestStream(ctx context.Context, model string, msgs []assistant.Message, opts assistant.RequestOptions, onDelta func(string) error) (assistant.Message, assistant.Usage, error) {
+	route, err := r.pick(opts.User)
+	if err != nil {
+		return assistant.Message{}, assistant.Usage{}, err
+	}
+
+	msg, usage, err := requestStream(route.Client, ctx, route.model(model), msgs, opts, onDelta)
+	return r.served(route, model, msg, usage, err)
+}
+
+// RequestStateful forwards server-side conversation state to the picked
+// route. A response ID issued by another route is reported as
+// assistant.ErrResponseNotFound and Assistant replays the full history; use
+// RequestOptions.User to keep a conversation on one route.
+func (r *WeightedRouter) RequestStateful(ctx context.Context, model string, previousResponseID string, msgs []assistant.Message, opts assistant.RequestOptions) (assistant.Message, assistant.Usage, string, error) {
+	route, err := r.pick(opts.User)
+	if err != nil {
+		return assistant.Message{}, assistant.Usage{}, "", err
+	}
+
+	msg, usage, id, err := requestStateful(route.Client, ctx, route.model(model), previousResponseID, msgs, opts)
+	msg, usage, err = r.served(route, model, msg, usage, err)
+	return msg, usage, id, err
+}
+
+func (r *WeightedRouter) served(route Route, model string, msg assistant.Message, usage assistant.Usage, err error) (assistant.Message, assistant.Usage, error) {
+	if err != nil {
+		return msg, usage, err
+	}
+	if r.onServed != nil {
+		r.onServed(Served{Route: route.Name, Model: route.model(model)})
+	}
+	return msg, route.served(usage), nil
+}
+
+func (r *WeightedRouter) pick(user string) (Route, error) {
+	if r.total == 0 {
+		return Route{}, errors.New("no routes configured")
+	}
+
+	var n int
+	if user != "" {
+		h := fnv.New64a()
+		h.Write([]byte(user))
+		n = int(h.Sum64() % uint64(r.total))
+	} else {
+		n = rand.N(r.total)
+	}
+
+	for _, route := range r.routes {
+		if n < route.Weight {
+			return route.Route, nil
+		}
+		n -= route.Weight
+	}
+	return r.routes[len(r.routes)-1].Route, nil
+}
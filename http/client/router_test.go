@@ -0,0 +1,97 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mwazovzky/assistant"
+	"github.com/mwazovzky/assistant/http/client"
+)
+
+func TestWeightedRouter_SplitsTraffic(t *testing.T) {
+	a := &StubClient{reply: "a"}
+	b := &StubClient{reply: "b"}
+	r := client.NewWeightedRouter(
+		client.WeightedRoute{Route: client.Route{Name: "a", Client: a}, Weight: 3},
+		client.WeightedRoute{Route: client.Route{Name: "b", Client: b, Model: "gpt-4o-mini"}, Weight: 1},
+	)
+
+	for range 1000 {
+		msg, usage, err := r.Request("gpt-4o", hello)
+		require.NoError(t, err)
+		if msg.Content == "b" {
+			assert.Equal(t, "gpt-4o-mini", usage.Model)
+		} else {
+			assert.Empty(t, usage.Model)
+		}
+	}
+
+	assert.InDelta(t, 750, a.Calls(), 100)
+	assert.InDelta(t, 250, b.Calls(), 100)
+	assert.Equal(t, "gpt-4o", a.models[0])
+	assert.Equal(t, "gpt-4o-mini", b.models[0])
+}
+
+func TestWeightedRouter_StickyUser(t *testing.T) {
+	a := &StubClient{reply: "a"}
+	b := &StubClient{reply: "b"}
+	r := client.NewWeightedRouter(
+		client.WeightedRoute{Route: client.Route{Name: "a", Client: a}, Weight: 1},
+		client.WeightedRoute{Route: client.Route{Name: "b", Client: b}, Weight: 1},
+	)
+
+	var routes []string
+	r.SetOnServed(func(s client.Served) { routes = append(routes, s.Route) })
+
+	for range 20 {
+		_, _, err := r.RequestContext(context.Background(), "gpt-4o", hello, assistant.RequestOptions{User: "user-42"})
+		require.NoError(t, err)
+	}
+
+	require.Len(t, routes, 20)
+	for _, route := range routes {
+		assert.Equal(t, routes[0], route)
+	}
+}
+
+func TestWeightedRouter_ZeroWeight(t *testing.T) {
+	a := &StubClient{reply: "a"}
+	b := &StubClient{reply: "b"}
+	r := client.NewWeightedRouter(
+		client.WeightedRoute{Route: client.Route{Name: "a", Client: a}, Weight: 1},
+		client.WeightedRoute{Route: client.Route{Name: "b", Client: b}, Weight: 0},
+	)
+
+	for range 50 {
+		msg, _, err := r.Request("gpt-4o", hello)
+		require.NoError(t, err)
+		assert.Equal(t, "a", msg.Content)
+	}
+	assert.Equal(t, 0, b.Calls())
+}
+
+func TestWeightedRouter_NoRoutes(t *testing.T) {
+	r := client.NewWeightedRouter()
+
+	_, _, err := r.Request("gpt-4o", hello)
+
+	assert.EqualError(t, err, "no routes configured")
+}
+
+func TestWeightedRouter_Stream(t *testing.T) {
+	stream := &FlakyStreamClient{StubClient: StubClient{reply: "hi"}, deltas: []string{"h", "i"}}
+	r := client.NewWeightedRouter(client.WeightedRoute{Route: client.Route{Name: "a", Client: stream}, Weight: 1})
+
+	var deltas []string
+	msg, _, err := r.RequestStream(context.Background(), "gpt-4o", hello, assistant.RequestOptions{}, func(d string) error {
+		deltas = append(deltas, d)
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "hi", msg.Content)
+	assert.Equal(t, []string{"h", "i"}, deltas)
+}
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mwazovzky/assistant"
+)
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type toolCallDelta struct {
+	Index    int                    `json:"index"`
+	ID       string                 `json:"id"`
+	Type     string                 `json:"type"`
+	Function assistant.FunctionCall `json:"function"`
+}
+
+type delta struct {
+	Role      string          `json:"role"`
+	Content   string          `json:"content"`
+	ToolCalls []toolCallDelta `json:"tool_calls"`
+}
+
+type streamChoice struct {
+	Index        int    `json:"index"`
+	Delta        delta  `json:"delta"`
+	FinishReason string `json:"finish_reason"`
+}
+
+type openAiChunk struct {
+	Choices []streamChoice `json:"choices"`
+	Usage   *usage         `json:"usage"`
+}
+
+// RequestStream sends a streaming chat completion request and calls onDelta
+// for every content fragment received over Server-Sent Events. It returns the
+// assembled message, including any tool calls, and the usage reported in the
+// final chunk.
+func (c *OpenAiClient) RequestStream(ctx context.Context, model string, messages []assistant.Message, opts assistant.RequestOptions, onDelta func(delta string) error) (assistant.Message, assistant.Usage, error) {
+	req := newOpenAiRequest(model, messages, opts)
+	req.Stream = true
+	req.StreamOptions = &streamOptions{IncludeUsage: true}
+
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		return assistant.Message{}, assistant.Usage{}, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := c.createRequest(ctx, model, reqBody)
+	if err != nil {
+		return assistant.Message{}, assistant.Usage{}, err
+	}
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	httpRes, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return assistant.Message{}, assistant.Usage{}, fmt.Errorf("http request failed: %w", err)
+	}
+	defer httpRes.Body.Close()
+
+	if httpRes.StatusCode != http.StatusOK {
+		return assistant.Message{}, assistant.Usage{}, newAPIError(httpRes)
+	}
+
+	msg := assistant.Message{Role: assistant.RoleAssistant}
+	var content strings.Builder
+	var res assistant.Usage
+	done := false
+
+	scanner := bufio.NewScanner(httpRes.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		data := bytes.TrimSpace(bytes.TrimPrefix(line, []byte("data:")))
+		if string(data) == "[DONE]" {
+			done = true
+			break
+		}
+
+		var chunk openAiChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return assistant.Message{}, assistant.Usage{}, fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+
+		if chunk.Usage != nil {
+			res = chunk.Usage.toUsage()
+		}
+
+		for _, ch := range chunk.Choices {
+			if ch.Index != 0 {
+				continue
+			}
+			if ch.FinishReason == "content_filter" {
+				return assistant.Message{}, assistant.Usage{}, contentFilterError(httpRes)
+			}
+			if ch.Delta.Role != "" {
+				msg.Role = ch.Delta.Role
+			}
+			msg.ToolCalls = mergeToolCalls(msg.ToolCalls, ch.Delta.ToolCalls)
+			if ch.Delta.Content == "" {
+				continue
+			}
+			content.WriteString(ch.Delta.Content)
+			if err := onDelta(ch.Delta.Content); err != nil {
+				return assistant.Message{}, assistant.Usage{}, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return assistant.Message{}, assistant.Usage{}, fmt.Errorf("failed to read stream: %w", err)
+	}
+	if !done {
+		return assistant.Message{}, assistant.Usage{}, fmt.Errorf("stream ended before completion")
+	}
+
+	msg.Content = content.String()
+	return msg, res, nil
+}
+
+// mergeToolCalls folds streamed tool call fragments into calls. Fragments are
+// matched by index; the function arguments arrive in pieces and are joined.
+func mergeToolCalls(calls []assistant.ToolCall, deltas []toolCallDelta) []assistant.ToolCall {
+	for _, d := range deltas {
+		for len(calls) <= d.Index {
+			calls = append(calls, assistant.ToolCall{})
+		}
+		call := &calls[d.Index]
+		if d.ID != "" {
+			call.ID = d.ID
+		}
+		if d.Type != "" {
+			call.Type = d.Type
+		}
+		call.Function.Name += d.Function.Name
+		call.Function.Arguments += d.Function.Arguments
+	}
+	return calls
+}
@@ -0,0 +1,161 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/mwazovzky/assistant"
+	"github.com/mwazovzky/assistant/http/client"
+)
+
+func sseResponse(body string) *http.Response {
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Content-Type", "text/event-stream")
+	rec.WriteHeader(http.StatusOK)
+	rec.Body.WriteString(body)
+	return rec.Result()
+}
+
+func TestRequestStream(t *testing.T) {
+	type testCase struct {
+		name           string
+		mockResponse   *http.Response
+		mockError      error
+		expectedDeltas []string
+		expectedError  string
+		expectedResult assistant.Message
+		expectedUsage  assistant.Usage
+	}
+
+	tests := []testCase{
+		{
+			name: "Success",
+			mockResponse: sseResponse("" +
+				"data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"\"}}]}\n\n" +
+				"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"2+2\"}}]}\n\n" +
+				": keep-alive\n\n" +
+				"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"=4\"}}]}\n\n" +
+				"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":10,\"completion_tokens\":5,\"total_tokens\":15}}\n\n" +
+				"data: [DONE]\n\n"),
+			expectedDeltas: []string{"2+2", "=4"},
+			expectedResult: assistant.Message{Role: "assistant", Content: "2+2=4"},
+			expectedUsage:  assistant.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+		},
+		{
+			name:          "HTTP Error",
+			mockError:     errors.New("mock network error"),
+			expectedError: "mock network error",
+		},
+		{
+			name: "Status Error",
+			mockResponse: func() *http.Response {
+				rec := httptest.NewRecorder()
+				rec.WriteHeader(http.StatusInternalServerError)
+				return rec.Result()
+			}(),
+			expectedError: "status 500",
+		},
+		{
+			name:           "Invalid Chunk",
+			mockResponse:   sseResponse("data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"2+2\"}}]}\n\ndata: invalid-json\n\n"),
+			expectedDeltas: []string{"2+2"},
+			expectedError:  "failed to decode stream chunk",
+		},
+		{
+			name:           "Truncated Stream",
+			mockResponse:   sseResponse("data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"2+2\"}}]}\n\n"),
+			expectedDeltas: []string{"2+2"},
+			expectedError:  "stream ended before completion",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockHttpDoer := &MockHttpDoer{}
+			openAiClient := client.NewOpenAiClient("http://example.com", "test-api-key")
+			openAiClient.SetHttpClient(mockHttpDoer)
+
+			mockHttpDoer.On("Do", mock.Anything).Return(tt.mockResponse, tt.mockError)
+
+			var deltas []string
+			result, usage, err := openAiClient.RequestStream(context.Background(), "gpt-4", []assistant.Message{
+				{Role: "user", Content: "What is 2+2?"},
+			}, assistant.RequestOptions{}, func(delta string) error {
+				deltas = append(deltas, delta)
+				return nil
+			})
+
+			assert.Equal(t, tt.expectedDeltas, deltas)
+			if tt.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedResult, result)
+				assert.Equal(t, tt.expectedUsage, usage)
+			}
+
+			mockHttpDoer.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRequestStream_RequestBody(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "text/event-stream", r.Header.Get("Accept"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	openAiClient := client.NewOpenAiClient(server.URL, "test-api-key")
+	_, _, err := openAiClient.RequestStream(context.Background(), "gpt-4", nil, assistant.RequestOptions{}, func(string) error { return nil })
+
+	assert.NoError(t, err)
+	assert.Equal(t, true, body["stream"])
+	assert.Equal(t, map[string]any{"include_usage": true}, body["stream_options"])
+}
+
+func TestRequestStream_CallbackError(t *testing.T) {
+	mockHttpDoer := &MockHttpDoer{}
+	openAiClient := client.NewOpenAiClient("http://example.com", "test-api-key")
+	openAiClient.SetHttpClient(mockHttpDoer)
+	mockHttpDoer.On("Do", mock.Anything).Return(sseResponse(
+		"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"2+2\"}}]}\n\ndata: [DONE]\n\n"), nil)
+
+	stop := errors.New("stop")
+	_, _, err := openAiClient.RequestStream(context.Background(), "gpt-4", nil, assistant.RequestOptions{}, func(string) error { return stop })
+
+	assert.ErrorIs(t, err, stop)
+}
+
+func TestRequestStream_ToolCalls(t *testing.T) {
+	mockHttpDoer := &MockHttpDoer{}
+	openAiClient := client.NewOpenAiClient("http://example.com", "test-api-key")
+	openAiClient.SetHttpClient(mockHttpDoer)
+	mockHttpDoer.On("Do", mock.Anything).Return(sseResponse(""+
+		"data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"tool_calls\":[{\"index\":0,\"id\":\"call-1\",\"type\":\"function\",\"function\":{\"name\":\"get_weather\",\"arguments\":\"\"}}]}}]}\n\n"+
+		"data: {\"choices\":[{\"index\":0,\"delta\":{\"tool_calls\":[{\"index\":0,\"function\":{\"arguments\":\"{\\\"city\\\":\"}}]}}]}\n\n"+
+		"data: {\"choices\":[{\"index\":0,\"delta\":{\"tool_calls\":[{\"index\":0,\"function\":{\"arguments\":\"\\\"Paris\\\"}\"}}]}}]}\n\n"+
+		"data: [DONE]\n\n"), nil)
+
+	var deltas []string
+	result, _, err := openAiClient.RequestStream(context.Background(), "gpt-4", nil, assistant.RequestOptions{}, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Empty(t, deltas)
+	assert.Equal(t, []assistant.ToolCall{
+		{ID: "call-1", Type: "function", Function: assistant.FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+	}, result.ToolCalls)
+}
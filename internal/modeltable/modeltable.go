@@ -0,0 +1,26 @@
+// Package modeltable looks up per-model settings, such as prices and rate
+// limits, by model name.
+package modeltable
+
+import "strings"
+
+// Lookup returns the entry of table for model: the exact entry, or else the
+// entry whose key is the longest prefix of model, so that "gpt-4o" covers
+// "gpt-4o-2024-08-06".
+func Lookup[V any](table map[string]V, model string) (V, bool) {
+	if v, ok := table[model]; ok {
+		return v, true
+	}
+
+	best, found := "", false
+	for name := range table {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best, found = name, true
+		}
+	}
+	if !found {
+		var zero V
+		return zero, false
+	}
+	return table[best], true
+}
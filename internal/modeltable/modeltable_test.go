@@ -0,0 +1,28 @@
+package modeltable
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookup(t *testing.T) {
+	table := map[string]int{"gpt-4": 1, "gpt-4o": 2, "gpt-4o-mini": 3}
+
+	tests := []struct {
+		model    string
+		expected int
+		found    bool
+	}{
+		{"gpt-4o", 2, true},
+		{"gpt-4o-2024-08-06", 2, true},
+		{"gpt-4o-mini-2024-07-18", 3, true},
+		{"gpt-4-turbo", 1, true},
+		{"o3-mini", 0, false},
+	}
+	for _, tt := range tests {
+		value, found := Lookup(table, tt.model)
+		assert.Equal(t, tt.expected, value, tt.model)
+		assert.Equal(t, tt.found, found, tt.model)
+	}
+}
@@ -0,0 +1,73 @@
+package assistant
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyedMutex_SerialisesKey(t *testing.T) {
+	k := newKeyedMutex()
+	var mu sync.Mutex
+	active, maxActive := 0, 0
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock, err := k.Lock(context.Background(), "thread-1")
+			require.NoError(t, err)
+			defer unlock()
+
+			mu.Lock()
+			active++
+			maxActive = max(maxActive, active)
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			active--
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, maxActive)
+	assert.Empty(t, k.locks)
+}
+
+func TestKeyedMutex_IndependentKeys(t *testing.T) {
+	k := newKeyedMutex()
+
+	unlock, err := k.Lock(context.Background(), "thread-1")
+	require.NoError(t, err)
+	defer unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	unlock2, err := k.Lock(ctx, "thread-2")
+
+	assert.NoError(t, err)
+	unlock2()
+}
+
+func TestKeyedMutex_ContextCancelled(t *testing.T) {
+	k := newKeyedMutex()
+
+	unlock, err := k.Lock(context.Background(), "thread-1")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = k.Lock(ctx, "thread-1")
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	unlock()
+	assert.Empty(t, k.locks)
+}
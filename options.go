@@ -0,0 +1,76 @@
+package assistant
+
+import "errors"
+
+// ErrOptionsUnsupported is returned when request options are set but the
+// client only implements HttpClient and cannot send them.
+var ErrOptionsUnsupported = errors.New("client does not support request options")
+
+// RequestOptions carries per-request settings passed to the client along
+// with the messages. Nil pointers and empty values are left out of the
+// request so the provider defaults apply.
+type RequestOptions struct {
+	Tools            []ToolDefinition
+	ResponseFormat   *ResponseFormat
+	Temperature      *float64
+	TopP             *float64
+	MaxTokens        *int
+	Stop             []string
+	Seed             *int
+	PresencePenalty  *float64
+	FrequencyPenalty *float64
+	User             string
+}
+
+// IsZero reports whether no option is set.
+func (o RequestOptions) IsZero() bool {
+	return len(o.Tools) == 0 && o.ResponseFormat == nil && o.Temperature == nil && o.TopP == nil &&
+		o.MaxTokens == nil && len(o.Stop) == 0 && o.Seed == nil && o.PresencePenalty == nil &&
+		o.FrequencyPenalty == nil && o.User == ""
+}
+
+// RequestOption modifies RequestOptions. Options passed to NewAssistant become
+// defaults; options passed to Ask and its variants override them for a
+// single call.
+type RequestOption func(*RequestOptions)
+
+func WithTemperature(t float64) RequestOption {
+	return func(o *RequestOptions) { o.Temperature = &t }
+}
+
+func WithTopP(p float64) RequestOption {
+	return func(o *RequestOptions) { o.TopP = &p }
+}
+
+func WithMaxTokens(n int) RequestOption {
+	return func(o *RequestOptions) { o.MaxTokens = &n }
+}
+
+func WithStop(stop ...string) RequestOption {
+	return func(o *RequestOptions) { o.Stop = stop }
+}
+
+func WithSeed(seed int) RequestOption {
+	return func(o *RequestOptions) { o.Seed = &seed }
+}
+
+func WithPresencePenalty(p float64) RequestOption {
+	return func(o *RequestOptions) { o.PresencePenalty = &p }
+}
+
+func WithFrequencyPenalty(p float64) RequestOption {
+	return func(o *RequestOptions) { o.FrequencyPenalty = &p }
+}
+
+// WithUser sets the end-user identifier forwarded to the provider for abuse
+// monitoring.
+func WithUser(user string) RequestOption {
+	return func(o *RequestOptions) { o.User = user }
+}
+
+func applyOptions(options RequestOptions, opts []RequestOption) RequestOptions {
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
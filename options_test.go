@@ -0,0 +1,76 @@
+package assistant
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestAsk_RequestOptions(t *testing.T) {
+	tid := "thread-1"
+	response := Message{Role: RoleAssistant, Content: "Mock response"}
+	expectedOptions := RequestOptions{
+		Temperature: ptr(0.9),
+		TopP:        ptr(0.5),
+		MaxTokens:   ptr(100),
+		Stop:        []string{"\n"},
+		Seed:        ptr(42),
+		User:        "user-1",
+	}
+
+	client := &MockContextHttpClient{}
+	threads := &MockThreadRepo{}
+	threads.On("ThreadExists", tid).Return(true, nil)
+	threads.On("AppendMessage", tid, mock.Anything).Return(nil)
+	threads.On("GetMessages", tid).Return([]Message{}, nil)
+	client.On("RequestContext", mock.Anything, "gpt-4", []Message{}, expectedOptions).Return(response, Usage{}, nil)
+
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads,
+		WithTemperature(0.2),
+		WithMaxTokens(100),
+		WithSeed(42),
+		WithUser("user-1"),
+	)
+	_, err := assistant.Ask(tid, "What is 2+2?", WithTemperature(0.9), WithTopP(0.5), WithStop("\n"))
+
+	assert.NoError(t, err)
+	client.AssertExpectations(t)
+}
+
+func TestAsk_RequestOptions_DefaultsUnchanged(t *testing.T) {
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", &MockHttpClient{}, &MockThreadRepo{}, WithTemperature(0.2))
+
+	options := assistant.requestOptions([]RequestOption{WithTemperature(0.9), WithPresencePenalty(1), WithFrequencyPenalty(-1)})
+
+	assert.Equal(t, 0.9, *options.Temperature)
+	assert.Equal(t, 1.0, *options.PresencePenalty)
+	assert.Equal(t, -1.0, *options.FrequencyPenalty)
+	assert.Equal(t, RequestOptions{Temperature: ptr(0.2)}, assistant.requestOptions(nil))
+}
+
+func TestAsk_RequestOptions_Unsupported(t *testing.T) {
+	client := &MockHttpClient{}
+	threads := &MockThreadRepo{}
+	threads.On("ThreadExists", "thread-1").Return(true, nil)
+	threads.On("AppendMessage", "thread-1", mock.Anything).Return(nil)
+	threads.On("GetMessages", "thread-1").Return([]Message{}, nil)
+
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads)
+	_, err := assistant.Ask("thread-1", "What is 2+2?", WithTemperature(0.9))
+
+	assert.ErrorIs(t, err, ErrOptionsUnsupported)
+	client.AssertNotCalled(t, "Request", mock.Anything, mock.Anything)
+}
+
+func TestRequestOptions_IsZero(t *testing.T) {
+	assert.True(t, RequestOptions{}.IsZero())
+	assert.False(t, RequestOptions{Temperature: ptr(0.0)}.IsZero())
+	assert.False(t, RequestOptions{Tools: []ToolDefinition{{Name: "noop"}}}.IsZero())
+	assert.False(t, RequestOptions{ResponseFormat: &ResponseFormat{Name: "city"}}.IsZero())
+	assert.False(t, RequestOptions{User: "user-1"}.IsZero())
+}
@@ -0,0 +1,144 @@
+package assistant
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mwazovzky/assistant/internal/modeltable"
+)
+
+// ErrBudgetExceeded is matched by errors.Is when a request is refused because
+// a spending limit has been reached.
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// Price is the price of a model in USD per one million tokens.
+type Price struct {
+	Input       float64 `json:"input" yaml:"input"`
+	Output      float64 `json:"output" yaml:"output"`
+	CachedInput float64 `json:"cached_input" yaml:"cached_input"`
+}
+
+// PriceTable maps model names to prices. A model without an exact entry uses
+// the entry with the longest matching prefix, so "gpt-4o" also prices
+// "gpt-4o-2024-08-06".
+type PriceTable map[string]Price
+
+// LoadPriceTableJSON reads a price table from JSON:
+//
+//	{"gpt-4o": {"input": 2.5, "output": 10, "cached_input": 1.25}}
+func LoadPriceTableJSON(r io.Reader) (PriceTable, error) {
+	var table PriceTable
+	if err := json.NewDecoder(r).Decode(&table); err != nil {
+		return nil, fmt.Errorf("failed to decode price table: %w", err)
+	}
+	return table, nil
+}
+
+// LoadPriceTableYAML reads a price table from YAML:
+//
+//	gpt-4o:
+//	  input: 2.5
+//	  output: 10
+//	  cached_input: 1.25
+func LoadPriceTableYAML(r io.Reader) (PriceTable, error) {
+	var table PriceTable
+	if err := yaml.NewDecoder(r).Decode(&table); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to decode price table: %w", err)
+	}
+	return table, nil
+}
+
+// Lookup returns the price of model. A model without an exact entry uses the
+// entry with the longest matching prefix, so that "gpt-4o" covers
+// "gpt-4o-2024-08-06".
+func (t PriceTable) Lookup(model string) (Price, bool) {
+	return modeltable.Lookup(t, model)
+}
+
+// Cost returns the estimated cost of usage with model in USD. Cached prompt
+// tokens are charged at the cached input price when it is set.
+func (t PriceTable) Cost(model string, usage Usage) float64 {
+	p, ok := t.Lookup(model)
+	if !ok {
+		return 0
+	}
+
+	cached := p.CachedInput
+	if cached == 0 {
+		cached = p.Input
+	}
+
+	uncached := usage.PromptTokens - usage.CachedTokens
+	return (float64(uncached)*p.Input + float64(usage.CachedTokens)*cached + float64(usage.CompletionTokens)*p.Output) / 1e6
+}
+
+// SetPriceTable sets the prices used to estimate the cost of recorded usage.
+func (t *UsageTracker) SetPriceTable(prices PriceTable) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prices = prices
+}
+
+// Cost returns the estimated cost of usage with model, or zero when the
+// model has no price.
+func (t *UsageTracker) Cost(model string, usage Usage) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.prices.Cost(model, usage)
+}
+
+// Budget limits spending in USD. A zero limit is not enforced.
+type Budget struct {
+	// PerThread limits the cost accumulated by a single thread.
+	PerThread float64
+	// Total limits the cost accumulated by the assistant's usage tracker.
+	Total float64
+}
+
+// BudgetError reports which limit was reached. It matches ErrBudgetExceeded.
+type BudgetError struct {
+	Scope string
+	Limit float64
+	Spent float64
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("%s: %s limit %.4f, spent %.4f", ErrBudgetExceeded, e.Scope, e.Limit, e.Spent)
+}
+
+func (e *BudgetError) Unwrap() error {
+	return ErrBudgetExceeded
+}
+
+// SetBudget sets spending limits checked before every request. Costs are
+// only known when the usage tracker has a price table.
+func (a *Assistant) SetBudget(budget Budget) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.budget = budget
+}
+
+func (a *Assistant) checkBudget(tid string) error {
+	a.mu.RLock()
+	budget, tracker := a.budget, a.tracker
+	a.mu.RUnlock()
+
+	if limit := budget.PerThread; limit > 0 {
+		if spent := tracker.Thread(tid).Cost; spent >= limit {
+			return &BudgetError{Scope: "thread", Limit: limit, Spent: spent}
+		}
+	}
+	if limit := budget.Total; limit > 0 {
+		if spent := tracker.Total().Cost; spent >= limit {
+			return &BudgetError{Scope: "total", Limit: limit, Spent: spent}
+		}
+	}
+	return nil
+}
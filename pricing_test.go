@@ -0,0 +1,154 @@
+package assistant
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var testPrices = PriceTable{
+	"gpt-4o":      {Input: 2.5, Output: 10, CachedInput: 1.25},
+	"gpt-4o-mini": {Input: 0.15, Output: 0.6},
+}
+
+func TestLoadPriceTable(t *testing.T) {
+	expected := PriceTable{"gpt-4o": {Input: 2.5, Output: 10, CachedInput: 1.25}}
+
+	table, err := LoadPriceTableJSON(strings.NewReader(`{"gpt-4o": {"input": 2.5, "output": 10, "cached_input": 1.25}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, table)
+
+	table, err = LoadPriceTableYAML(strings.NewReader("gpt-4o:\n  input: 2.5\n  output: 10\n  cached_input: 1.25\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, table)
+
+	_, err = LoadPriceTableJSON(strings.NewReader("invalid-json"))
+	assert.ErrorContains(t, err, "failed to decode price table")
+
+	_, err = LoadPriceTableYAML(strings.NewReader("gpt-4o: [1, 2"))
+	assert.ErrorContains(t, err, "failed to decode price table")
+}
+
+func TestPriceTable_Lookup(t *testing.T) {
+	tests := []struct {
+		model    string
+		expected Price
+		found    bool
+	}{
+		{"gpt-4o", testPrices["gpt-4o"], true},
+		{"gpt-4o-2024-08-06", testPrices["gpt-4o"], true},
+		{"gpt-4o-mini-2024-07-18", testPrices["gpt-4o-mini"], true},
+		{"gpt-4", Price{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			price, found := testPrices.Lookup(tt.model)
+			assert.Equal(t, tt.found, found)
+			assert.Equal(t, tt.expected, price)
+		})
+	}
+}
+
+func TestPriceTable_Cost(t *testing.T) {
+	usage := Usage{PromptTokens: 1_000_000, CompletionTokens: 100_000, CachedTokens: 400_000}
+
+	// 600k * 2.5 + 400k * 1.25 + 100k * 10
+	assert.InDelta(t, 3.0, testPrices.Cost("gpt-4o", usage), 1e-9)
+	// cached input falls back to the input price
+	assert.InDelta(t, 0.21, testPrices.Cost("gpt-4o-mini", usage), 1e-9)
+	assert.Equal(t, 0.0, testPrices.Cost("unknown", usage))
+}
+
+func newPricedAssistant(t *testing.T, response Message, usage Usage) *Assistant {
+	t.Helper()
+	tracker, err := NewUsageTracker(nil)
+	require.NoError(t, err)
+	tracker.SetPriceTable(testPrices)
+
+	client := &MockHttpClient{}
+	threads := &MockThreadRepo{}
+	threads.On("ThreadExists", mock.Anything).Return(true, nil)
+	threads.On("AppendMessage", mock.Anything, mock.Anything).Return(nil)
+	threads.On("GetMessages", mock.Anything).Return([]Message{}, nil)
+	client.On("Request", "gpt-4o", mock.Anything).Return(response, usage, nil)
+
+	assistant := NewAssistant("gpt-4o", "You are a helpful assistant.", client, threads)
+	assistant.SetUsageTracker(tracker)
+	return assistant
+}
+
+func TestAsk_Cost(t *testing.T) {
+	assistant := newPricedAssistant(t, Message{Role: RoleAssistant}, Usage{PromptTokens: 1000, CompletionTokens: 100, TotalTokens: 1100})
+
+	_, err := assistant.Ask("thread-1", "Question")
+	require.NoError(t, err)
+	_, err = assistant.Ask("thread-1", "Question")
+	require.NoError(t, err)
+
+	assert.InDelta(t, 0.0035, assistant.GetUsage().Cost, 1e-9)
+	assert.InDelta(t, 0.007, assistant.GetThreadUsage("thread-1").Cost, 1e-9)
+	assert.InDelta(t, 0.007, assistant.GetUsageSnapshot().Models["gpt-4o"].Cost, 1e-9)
+}
+
+func TestAsk_Cost_ServedModel(t *testing.T) {
+	// a fallback route served the request with gpt-4o-mini
+	assistant := newPricedAssistant(t, Message{Role: RoleAssistant}, Usage{PromptTokens: 1000, CompletionTokens: 100, TotalTokens: 1100, Model: "gpt-4o-mini"})
+
+	_, err := assistant.Ask("thread-1", "Question")
+	require.NoError(t, err)
+
+	snapshot := assistant.GetUsageSnapshot()
+	assert.InDelta(t, 0.00021, assistant.GetUsage().Cost, 1e-9)
+	assert.Equal(t, "gpt-4o-mini", assistant.GetUsage().Model)
+	assert.InDelta(t, 0.00021, snapshot.Models["gpt-4o-mini"].Cost, 1e-9)
+	assert.NotContains(t, snapshot.Models, "gpt-4o")
+}
+
+func TestAsk_BudgetExceeded(t *testing.T) {
+	tests := []struct {
+		name   string
+		budget Budget
+		scope  string
+	}{
+		{"Per Thread", Budget{PerThread: 0.005}, "thread"},
+		{"Total", Budget{Total: 0.005}, "total"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assistant := newPricedAssistant(t, Message{Role: RoleAssistant}, Usage{PromptTokens: 1000, CompletionTokens: 100, TotalTokens: 1100})
+			assistant.SetBudget(tt.budget)
+
+			_, err := assistant.Ask("thread-1", "Question")
+			require.NoError(t, err)
+			_, err = assistant.Ask("thread-1", "Question")
+			require.NoError(t, err)
+			_, err = assistant.Ask("thread-1", "Question")
+
+			assert.ErrorIs(t, err, ErrBudgetExceeded)
+			var budgetErr *BudgetError
+			require.True(t, errors.As(err, &budgetErr))
+			assert.Equal(t, tt.scope, budgetErr.Scope)
+			assert.InDelta(t, 0.007, budgetErr.Spent, 1e-9)
+			assistant.client.(*MockHttpClient).AssertNumberOfCalls(t, "Request", 2)
+		})
+	}
+}
+
+func TestAsk_BudgetPerThreadIsolated(t *testing.T) {
+	assistant := newPricedAssistant(t, Message{Role: RoleAssistant}, Usage{PromptTokens: 1000, CompletionTokens: 100, TotalTokens: 1100})
+	assistant.SetBudget(Budget{PerThread: 0.003})
+
+	_, err := assistant.Ask("thread-1", "Question")
+	require.NoError(t, err)
+	_, err = assistant.Ask("thread-1", "Question")
+	assert.ErrorIs(t, err, ErrBudgetExceeded)
+
+	_, err = assistant.Ask("thread-2", "Question")
+	assert.NoError(t, err)
+}
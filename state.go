@@ -0,0 +1,138 @@
+package assistant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// MetadataResponseID is the thread metadata key under which the server-side
+// conversation state is kept.
+const MetadataResponseID = "response_id"
+
+// ErrResponseNotFound is matched by errors.Is when a StatefulHttpClient no
+// longer knows the previous response, for example because it expired.
+var ErrResponseNotFound = errors.New("previous response not found")
+
+// ErrResponseStateNotStored is matched by errors.Is when a reply was received
+// and stored in the thread but its response ID could not be saved. Ask and
+// AskInto return the reply together with the error. The thread stays usable:
+// the next request continues from the last saved response and resends the
+// messages added since.
+var ErrResponseStateNotStored = errors.New("response state not stored")
+
+// responseState links a thread to the last server-side response. Through is
+// the number of stored messages the response already covers as input.
+type responseState struct {
+	ID      string `json:"id"`
+	Through int    `json:"through"`
+}
+
+// SetServerState makes Ask and AskInto keep the conversation on the server
+// when the client implements StatefulHttpClient. The ID of the last response
+// is stored in thread metadata and only the messages added since that
+// response are sent. When the server no longer knows the response the full
+// history is replayed, trimmed and summarized as usual. With other clients,
+// with repositories that do not implement ThreadMetadataRepository, and for
+// AskStream, requests always carry the full history. A reply whose response
+// ID cannot be saved is still stored and returned, with an error matching
+// ErrResponseStateNotStored.
+func (a *Assistant) SetServerState(enabled bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.serverState = enabled
+}
+
+// sender returns the function that sends requests for thread tid.
+func (a *Assistant) sender(tid string) sendFunc {
+	a.mu.RLock()
+	enabled := a.serverState
+	a.mu.RUnlock()
+
+	client, ok := a.client.(StatefulHttpClient)
+	if !enabled || !ok {
+		return a.request
+	}
+
+	return func(ctx context.Context, msgs []Message, opts RequestOptions) (Message, Usage, error) {
+		return a.requestStateful(ctx, client, tid, msgs, opts)
+	}
+}
+
+// requestStateful continues the server-side conversation of thread tid with
+// the stored messages it has not seen yet, or replays msgs when there is no
+// usable previous response.
+func (a *Assistant) requestStateful(ctx context.Context, client StatefulHttpClient, tid string, msgs []Message, opts RequestOptions) (Message, Usage, error) {
+	repo, ok := a.threads.(ThreadMetadataRepository)
+	if !ok {
+		return a.request(ctx, msgs, opts)
+	}
+
+	state, err := loadResponseState(ctx, repo, tid)
+	if err != nil {
+		return Message{}, Usage{}, err
+	}
+
+	stored, err := a.getMessages(ctx, tid)
+	if err != nil {
+		return Message{}, Usage{}, err
+	}
+
+	if state.ID != "" && state.Through <= len(stored) {
+		msg, usage, id, err := client.RequestStateful(ctx, a.model, state.ID, pending(stored[state.Through:]), opts)
+		if err == nil {
+			return msg, usage, storeResponseState(ctx, repo, tid, responseState{ID: id, Through: len(stored)})
+		}
+		if !errors.Is(err, ErrResponseNotFound) {
+			return Message{}, Usage{}, err
+		}
+	}
+
+	msg, usage, id, err := client.RequestStateful(ctx, a.model, "", msgs, opts)
+	if err != nil {
+		return Message{}, Usage{}, err
+	}
+	return msg, usage, storeResponseState(ctx, repo, tid, responseState{ID: id, Through: len(stored)})
+}
+
+// pending drops the leading assistant reply, which the server already holds
+// as the output of the previous response.
+func pending(msgs []Message) []Message {
+	if len(msgs) > 0 && msgs[0].Role == RoleAssistant {
+		return msgs[1:]
+	}
+	return msgs
+}
+
+func loadResponseState(ctx context.Context, repo ThreadMetadataRepository, tid string) (responseState, error) {
+	value, ok, err := getMetadata(ctx, repo, tid, MetadataResponseID)
+	if err != nil || !ok {
+		return responseState{}, err
+	}
+
+	var s responseState
+	if err := json.Unmarshal([]byte(value), &s); err != nil {
+		return responseState{}, fmt.Errorf("failed to decode response state: %w", err)
+	}
+	return s, nil
+}
+
+// storeResponseState saves s. Failures match ErrResponseStateNotStored, which
+// converse treats as a stored reply rather than a failed request. Nothing is
+// saved when the client returned no response ID, such as a decorator over a
+// client that keeps no server-side state.
+func storeResponseState(ctx context.Context, repo ThreadMetadataRepository, tid string, s responseState) error {
+	if s.ID == "" {
+		return nil
+	}
+	value, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrResponseStateNotStored, err)
+	}
+	if err := setMetadata(ctx, repo, tid, MetadataResponseID, string(value)); err != nil {
+		return fmt.Errorf("%w: %w", ErrResponseStateNotStored, err)
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package assistant
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockStatefulHttpClient struct {
+	MockContextHttpClient
+}
+
+func (m *MockStatefulHttpClient) RequestStateful(ctx context.Context, model string, previousResponseID string, msgs []Message, opts RequestOptions) (Message, Usage, string, error) {
+	args := m.Called(ctx, model, previousResponseID, msgs, opts)
+	return args.Get(0).(Message), args.Get(1).(Usage), args.String(2), args.Error(3)
+}
+
+// metadataThreadRepo is a syncThreadRepo with metadata
+type metadataThreadRepo struct {
+	*syncThreadRepo
+	metadata map[string]string
+}
+
+func newMetadataThreadRepo() *metadataThreadRepo {
+	return &metadataThreadRepo{syncThreadRepo: newSyncThreadRepo(), metadata: map[string]string{}}
+}
+
+func (r *metadataThreadRepo) GetMetadata(tid string, key string) (string, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	value, ok := r.metadata[tid+"/"+key]
+	return value, ok, nil
+}
+
+func (r *metadataThreadRepo) SetMetadata(tid string, key string, value string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metadata[tid+"/"+key] = value
+	return nil
+}
+
+func TestAsk_ServerState(t *testing.T) {
+	tid := "thread-1"
+	sys := Message{Role: RoleSystem, Content: "You are a helpful assistant."}
+	q1 := Message{Role: RoleUser, Content: "What is 2+2?"}
+	a1 := Message{Role: RoleAssistant, Content: "4"}
+	q2 := Message{Role: RoleUser, Content: "And 3+3?"}
+	a2 := Message{Role: RoleAssistant, Content: "6"}
+
+	client := &MockStatefulHttpClient{}
+	client.On("RequestStateful", mock.Anything, "gpt-4", "", []Message{sys, q1}, RequestOptions{}).Return(a1, Usage{TotalTokens: 10}, "resp-1", nil).Once()
+	client.On("RequestStateful", mock.Anything, "gpt-4", "resp-1", []Message{q2}, RequestOptions{}).Return(a2, Usage{TotalTokens: 3}, "resp-2", nil).Once()
+	threads := newMetadataThreadRepo()
+
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads)
+	assistant.SetServerState(true)
+
+	reply, err := assistant.Ask(tid, "What is 2+2?")
+	require.NoError(t, err)
+	assert.Equal(t, "4", reply)
+
+	reply, err = assistant.Ask(tid, "And 3+3?")
+	require.NoError(t, err)
+	assert.Equal(t, "6", reply)
+
+	messages, _ := assistant.GetMessages(tid)
+	assert.Equal(t, []Message{sys, q1, a1, q2, a2}, messages)
+	value, _, _ := threads.GetMetadata(tid, MetadataResponseID)
+	assert.JSONEq(t, `{"id":"resp-2","through":4}`, value)
+	assert.Equal(t, Usage{TotalTokens: 13}, assistant.GetThreadUsage(tid))
+	client.AssertExpectations(t)
+}
+
+func TestAsk_ServerState_ToolCalls(t *testing.T) {
+	tid := "thread-1"
+	sys := Message{Role: RoleSystem, Content: "system"}
+	q := Message{Role: RoleUser, Content: "Weather?"}
+	call := Message{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "call-1", Type: "function", Function: FunctionCall{Name: "weather", Arguments: "{}"}}}}
+	result := Message{Role: RoleTool, Content: "sunny", ToolCallID: "call-1"}
+	answer := Message{Role: RoleAssistant, Content: "It is sunny."}
+
+	client := &MockStatefulHttpClient{}
+	client.On("RequestStateful", mock.Anything, "gpt-4", "", []Message{sys, q}, mock.Anything).Return(call, Usage{}, "resp-1", nil).Once()
+	client.On("RequestStateful", mock.Anything, "gpt-4", "resp-1", []Message{result}, mock.Anything).Return(answer, Usage{}, "resp-2", nil).Once()
+	threads := newMetadataThreadRepo()
+
+	assistant := NewAssistant("gpt-4", "system", client, threads)
+	assistant.SetServerState(true)
+	assistant.RegisterTool(Tool{
+		ToolDefinition: ToolDefinition{Name: "weather"},
+		Func:           func(ctx context.Context, arguments string) (string, error) { return "sunny", nil },
+	})
+
+	reply, err := assistant.Ask(tid, "Weather?")
+
+	require.NoError(t, err)
+	assert.Equal(t, "It is sunny.", reply)
+	client.AssertExpectations(t)
+}
+
+func TestAsk_ServerState_ExpiredResponse(t *testing.T) {
+	tid := "thread-1"
+	sys := Message{Role: RoleSystem, Content: "system"}
+	q1 := Message{Role: RoleUser, Content: "q1"}
+	a1 := Message{Role: RoleAssistant, Content: "a1"}
+	q2 := Message{Role: RoleUser, Content: "q2"}
+	a2 := Message{Role: RoleAssistant, Content: "a2"}
+
+	threads := newMetadataThreadRepo()
+	threads.CreateThread(tid)
+	for _, msg := range []Message{sys, q1, a1} {
+		threads.AppendMessage(tid, msg)
+	}
+	threads.SetMetadata(tid, MetadataResponseID, `{"id":"resp-old","through":2}`)
+
+	client := &MockStatefulHttpClient{}
+	client.On("RequestStateful", mock.Anything, "gpt-4", "resp-old", []Message{q2}, RequestOptions{}).Return(Message{}, Usage{}, "", fmt.Errorf("%w: expired", ErrResponseNotFound)).Once()
+	client.On("RequestStateful", mock.Anything, "gpt-4", "", []Message{sys, q1, a1, q2}, RequestOptions{}).Return(a2, Usage{}, "resp-new", nil).Once()
+
+	assistant := NewAssistant("gpt-4", "system", client, threads)
+	assistant.SetServerState(true)
+	reply, err := assistant.Ask(tid, "q2")
+
+	require.NoError(t, err)
+	assert.Equal(t, "a2", reply)
+	value, _, _ := threads.GetMetadata(tid, MetadataResponseID)
+	assert.JSONEq(t, `{"id":"resp-new","through":4}`, value)
+	client.AssertExpectations(t)
+}
+
+func TestAsk_ServerState_Errors(t *testing.T) {
+	tid := "thread-1"
+	errBoom := fmt.Errorf("boom")
+
+	client := &MockStatefulHttpClient{}
+	client.On("RequestStateful", mock.Anything, "gpt-4", "", mock.Anything, RequestOptions{}).Return(Message{}, Usage{}, "", errBoom).Once()
+	threads := newMetadataThreadRepo()
+
+	assistant := NewAssistant("gpt-4", "system", client, threads)
+	assistant.SetServerState(true)
+	_, err := assistant.Ask(tid, "q1")
+
+	assert.ErrorIs(t, err, errBoom)
+	_, ok, _ := threads.GetMetadata(tid, MetadataResponseID)
+	assert.False(t, ok)
+}
+
+// failingMetadataRepo fails to store metadata
+type failingMetadataRepo struct {
+	*metadataThreadRepo
+	err error
+}
+
+func (r *failingMetadataRepo) SetMetadata(tid string, key string, value string) error {
+	return r.err
+}
+
+func TestAsk_ServerState_StoreFails(t *testing.T) {
+	tid := "thread-1"
+	sys := Message{Role: RoleSystem, Content: "system"}
+	q1 := Message{Role: RoleUser, Content: "q1"}
+	a1 := Message{Role: RoleAssistant, Content: "a1"}
+	q2 := Message{Role: RoleUser, Content: "q2"}
+	a2 := Message{Role: RoleAssistant, Content: "a2"}
+	errBoom := fmt.Errorf("boom")
+
+	client := &MockStatefulHttpClient{}
+	client.On("RequestStateful", mock.Anything, "gpt-4", "", []Message{sys, q1}, RequestOptions{}).Return(a1, Usage{TotalTokens: 10}, "resp-1", nil).Once()
+	client.On("RequestStateful", mock.Anything, "gpt-4", "", []Message{sys, q1, a1, q2}, RequestOptions{}).Return(a2, Usage{TotalTokens: 10}, "resp-2", nil).Once()
+	threads := &failingMetadataRepo{metadataThreadRepo: newMetadataThreadRepo(), err: errBoom}
+
+	assistant := NewAssistant("gpt-4", "system", client, threads)
+	assistant.SetServerState(true)
+	reply, err := assistant.Ask(tid, "q1")
+
+	// the billed reply is kept and returned along with the error
+	assert.ErrorIs(t, err, ErrResponseStateNotStored)
+	assert.ErrorIs(t, err, errBoom)
+	assert.Equal(t, "a1", reply)
+	assert.Equal(t, 10, assistant.GetThreadUsage(tid).TotalTokens)
+
+	// without saved state the next turn replays the full history
+	reply, err = assistant.Ask(tid, "q2")
+	assert.ErrorIs(t, err, ErrResponseStateNotStored)
+	assert.Equal(t, "a2", reply)
+
+	messages, _ := assistant.GetMessages(tid)
+	assert.Equal(t, []Message{sys, q1, a1, q2, a2}, messages)
+	client.AssertExpectations(t)
+}
+
+// contextMetadataRepo is a metadataThreadRepo that records the context values
+// its context metadata methods receive
+type contextMetadataRepo struct {
+	*metadataThreadRepo
+	seen []any
+}
+
+func (r *contextMetadataRepo) GetMetadataContext(ctx context.Context, tid string, key string) (string, bool, error) {
+	r.seen = append(r.seen, ctx.Value(ctxKey{}))
+	return r.metadataThreadRepo.GetMetadata(tid, key)
+}
+
+func (r *contextMetadataRepo) SetMetadataContext(ctx context.Context, tid string, key string, value string) error {
+	r.seen = append(r.seen, ctx.Value(ctxKey{}))
+	return r.metadataThreadRepo.SetMetadata(tid, key, value)
+}
+
+func TestAsk_ServerState_ContextMetadata(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ctxKey{}, "request")
+
+	client := &MockStatefulHttpClient{}
+	client.On("RequestStateful", ctx, "gpt-4", "", mock.Anything, RequestOptions{}).Return(Message{Role: RoleAssistant, Content: "a1"}, Usage{}, "resp-1", nil).Once()
+	threads := &contextMetadataRepo{metadataThreadRepo: newMetadataThreadRepo()}
+
+	assistant := NewAssistant("gpt-4", "system", client, threads)
+	assistant.SetServerState(true)
+	_, err := assistant.AskContext(ctx, "thread-1", "q1")
+	require.NoError(t, err)
+
+	// the state is loaded and stored through the context variants
+	assert.Equal(t, []any{"request", "request"}, threads.seen)
+	client.AssertExpectations(t)
+}
+
+func TestAsk_ServerState_MetadataUnsupported(t *testing.T) {
+	response := Message{Role: RoleAssistant, Content: "a1"}
+
+	// without metadata there is nowhere to keep the response ID, so the full
+	// history is sent
+	client := &MockStatefulHttpClient{}
+	client.On("RequestContext", mock.Anything, "gpt-4", []Message{{Role: RoleSystem, Content: "system"}, {Role: RoleUser, Content: "q1"}}, RequestOptions{}).Return(response, Usage{}, nil)
+
+	assistant := NewAssistant("gpt-4", "system", client, newSyncThreadRepo())
+	assistant.SetServerState(true)
+	reply, err := assistant.Ask("thread-1", "q1")
+
+	require.NoError(t, err)
+	assert.Equal(t, "a1", reply)
+	client.AssertNotCalled(t, "RequestStateful", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	client.AssertExpectations(t)
+}
+
+func TestAsk_ServerState_NoResponseID(t *testing.T) {
+	tid := "thread-1"
+	sys := Message{Role: RoleSystem, Content: "system"}
+	q1 := Message{Role: RoleUser, Content: "q1"}
+	a1 := Message{Role: RoleAssistant, Content: "a1"}
+	q2 := Message{Role: RoleUser, Content: "q2"}
+	a2 := Message{Role: RoleAssistant, Content: "a2"}
+
+	// a client that kept no state returns no response ID
+	client := &MockStatefulHttpClient{}
+	client.On("RequestStateful", mock.Anything, "gpt-4", "", []Message{sys, q1}, RequestOptions{}).Return(a1, Usage{}, "", nil).Once()
+	client.On("RequestStateful", mock.Anything, "gpt-4", "", []Message{sys, q1, a1, q2}, RequestOptions{}).Return(a2, Usage{}, "", nil).Once()
+	threads := newMetadataThreadRepo()
+
+	assistant := NewAssistant("gpt-4", "system", client, threads)
+	assistant.SetServerState(true)
+	_, err := assistant.Ask(tid, "q1")
+	require.NoError(t, err)
+	_, err = assistant.Ask(tid, "q2")
+	require.NoError(t, err)
+
+	_, ok, err := threads.GetMetadata(tid, MetadataResponseID)
+	require.NoError(t, err)
+	assert.False(t, ok, "an empty response ID must not be stored")
+	client.AssertExpectations(t)
+}
+
+func TestAsk_ServerState_Disabled(t *testing.T) {
+	response := Message{Role: RoleAssistant, Content: "4"}
+
+	client := &MockStatefulHttpClient{}
+	client.On("RequestContext", mock.Anything, "gpt-4", mock.Anything, RequestOptions{}).Return(response, Usage{}, nil)
+
+	assistant := NewAssistant("gpt-4", "system", client, newMetadataThreadRepo())
+	reply, err := assistant.Ask("thread-1", "What is 2+2?")
+
+	require.NoError(t, err)
+	assert.Equal(t, "4", reply)
+	client.AssertNotCalled(t, "RequestStateful", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,319 @@
+// Package file provides a ThreadRepository that keeps each thread in an
+// append-only JSONL file.
+//
+// A thread is stored as <dir>/<name>.jsonl with one JSON encoded message per
+// line, and its metadata as <dir>/<name>.meta.json. Appends are fsynced before
+// they return and file locks serialise writers, so several processes may
+// share a directory. A final line torn by a crash is ignored when reading and
+// truncated by the next append.
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mwazovzky/assistant"
+)
+
+const (
+	messagesExt = ".jsonl"
+	metadataExt = ".meta.json"
+
+	// maxName bounds the encoded thread ID so file names stay well below the
+	// usual 255 byte limit. Longer names are shortened and made unique with a
+	// hash of the thread ID.
+	maxName    = 200
+	namePrefix = 128
+)
+
+// ThreadRepository implements assistant.ThreadRepository and
+// assistant.ThreadMetadataRepository on top of a directory. It is safe for
+// concurrent use by multiple goroutines and, where file locking is available,
+// by multiple processes.
+type ThreadRepository struct {
+	dir string
+}
+
+// NewThreadRepository returns a repository storing threads in dir. The
+// directory is created if it does not exist.
+func NewThreadRepository(dir string) (*ThreadRepository, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	return &ThreadRepository{dir: dir}, nil
+}
+
+func (r *ThreadRepository) ThreadExists(tid string) (bool, error) {
+	_, err := os.Stat(r.path(tid, messagesExt))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to stat thread: %w", err)
+	}
+	return true, nil
+}
+
+func (r *ThreadRepository) CreateThread(tid string) error {
+	f, err := os.OpenFile(r.path(tid, messagesExt), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%w: %s", assistant.ErrThreadExists, tid)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create thread: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to create thread: %w", err)
+	}
+	return syncDir(r.dir)
+}
+
+func (r *ThreadRepository) AppendMessage(tid string, msg assistant.Message) error {
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	line = append(line, '\n')
+
+	f, err := r.open(tid, os.O_RDWR|os.O_APPEND)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := lockFile(f, true); err != nil {
+		return fmt.Errorf("failed to lock thread: %w", err)
+	}
+	defer unlockFile(f)
+
+	if err := truncateTorn(f); err != nil {
+		return err
+	}
+	if _, err := f.Write(line); err != nil {
+		return fmt.Errorf("failed to append message: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync thread: %w", err)
+	}
+	return nil
+}
+
+func (r *ThreadRepository) GetMessages(tid string) ([]assistant.Message, error) {
+	f, err := r.open(tid, os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if err := lockFile(f, false); err != nil {
+		return nil, fmt.Errorf("failed to lock thread: %w", err)
+	}
+	defer unlockFile(f)
+
+	messages := []assistant.Message{}
+	reader := bufio.NewReader(f)
+	for n := 1; ; n++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// Bytes after the last newline are a torn write; the append that
+			// produced them never returned successfully.
+			return messages, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read thread: %w", err)
+		}
+
+		var msg assistant.Message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			return nil, fmt.Errorf("failed to decode message on line %d: %w", n, err)
+		}
+		messages = append(messages, msg)
+	}
+}
+
+func (r *ThreadRepository) GetMetadata(tid string, key string) (string, bool, error) {
+	f, err := r.open(tid, os.O_RDONLY)
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+
+	if err := lockFile(f, false); err != nil {
+		return "", false, fmt.Errorf("failed to lock thread: %w", err)
+	}
+	defer unlockFile(f)
+
+	metadata, err := r.readMetadata(tid)
+	if err != nil {
+		return "", false, err
+	}
+	value, ok := metadata[key]
+	return value, ok, nil
+}
+
+// SetMetadata rewrites the metadata file through a temporary file and a
+// rename, so readers see either the old or the new metadata.
+func (r *ThreadRepository) SetMetadata(tid string, key string, value string) error {
+	f, err := r.open(tid, os.O_RDONLY)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := lockFile(f, true); err != nil {
+		return fmt.Errorf("failed to lock thread: %w", err)
+	}
+	defer unlockFile(f)
+
+	metadata, err := r.readMetadata(tid)
+	if err != nil {
+		return err
+	}
+	metadata[key] = value
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	return r.writeFile(r.path(tid, metadataExt), data)
+}
+
+// DeleteThread removes a thread and its metadata. Deleting an unknown thread
+// is not an error.
+func (r *ThreadRepository) DeleteThread(tid string) error {
+	for _, ext := range []string{metadataExt, messagesExt} {
+		if err := os.Remove(r.path(tid, ext)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to delete thread: %w", err)
+		}
+	}
+	return syncDir(r.dir)
+}
+
+func (r *ThreadRepository) open(tid string, flag int) (*os.File, error) {
+	f, err := os.OpenFile(r.path(tid, messagesExt), flag, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", assistant.ErrThreadNotFound, tid)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open thread: %w", err)
+	}
+	return f, nil
+}
+
+// readMetadata reads the metadata of a thread. The caller holds the thread
+// lock.
+func (r *ThreadRepository) readMetadata(tid string) (map[string]string, error) {
+	metadata := map[string]string{}
+	data, err := os.ReadFile(r.path(tid, metadataExt))
+	if errors.Is(err, fs.ErrNotExist) {
+		return metadata, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to decode metadata: %w", err)
+	}
+	return metadata, nil
+}
+
+func (r *ThreadRepository) writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to write metadata: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write metadata: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync metadata: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write metadata: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write metadata: %w", err)
+	}
+	return syncDir(r.dir)
+}
+
+func (r *ThreadRepository) path(tid string, ext string) string {
+	return filepath.Join(r.dir, fileName(tid)+ext)
+}
+
+// fileName maps a thread ID to a file name that is safe on every platform and
+// unique per ID. Lowercase letters, digits and '-' are kept; every other byte,
+// including uppercase letters for case-insensitive file systems, is written
+// as '_' followed by two hex digits.
+func fileName(tid string) string {
+	var b strings.Builder
+	for i := 0; i < len(tid); i++ {
+		c := tid[i]
+		if c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "_%02x", c)
+	}
+
+	name := b.String()
+	if len(name) > maxName {
+		sum := sha256.Sum256([]byte(tid))
+		name = name[:namePrefix] + "~" + hex.EncodeToString(sum[:])
+	}
+	return name
+}
+
+// truncateTorn removes a partial final line left by an interrupted append,
+// so the next line starts on a fresh line. The caller holds the thread lock.
+func truncateTorn(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat thread: %w", err)
+	}
+
+	end := info.Size()
+	buf := make([]byte, 4096)
+	for off := end; off > 0; {
+		n := int64(len(buf))
+		if off < n {
+			n = off
+		}
+		off -= n
+		if _, err := f.ReadAt(buf[:n], off); err != nil {
+			return fmt.Errorf("failed to read thread: %w", err)
+		}
+		i := bytes.LastIndexByte(buf[:n], '\n')
+		if i < 0 {
+			continue
+		}
+		if keep := off + int64(i) + 1; keep != end {
+			return truncate(f, keep)
+		}
+		return nil
+	}
+	if end > 0 {
+		return truncate(f, 0)
+	}
+	return nil
+}
+
+func truncate(f *os.File, size int64) error {
+	if err := f.Truncate(size); err != nil {
+		return fmt.Errorf("failed to truncate torn line: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mwazovzky/assistant"
+	"github.com/mwazovzky/assistant/storage/storagetest"
+)
+
+func TestThreadRepository(t *testing.T) {
+	storagetest.RunThreadRepositoryTests(t, func(t *testing.T) assistant.ThreadRepository {
+		return newTestRepository(t)
+	})
+}
+
+func newTestRepository(t *testing.T) *ThreadRepository {
+	t.Helper()
+	r, err := NewThreadRepository(filepath.Join(t.TempDir(), "threads"))
+	require.NoError(t, err)
+	return r
+}
+
+func TestFileName(t *testing.T) {
+	tests := []struct {
+		tid  string
+		want string
+	}{
+		{"user-123", "user-123"},
+		{"User-123", "_55ser-123"},
+		{"user_123", "user_5f123"},
+		{"../user", "_2e_2e_2fuser"},
+		{"a b", "a_20b"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, fileName(tt.tid), tt.tid)
+	}
+}
+
+func TestFileName_Long(t *testing.T) {
+	a := strings.Repeat("a", 300)
+	b := strings.Repeat("a", 299) + "b"
+
+	assert.LessOrEqual(t, len(fileName(a)), maxName)
+	assert.NotEqual(t, fileName(a), fileName(b))
+	assert.Equal(t, fileName(a), fileName(a))
+}
+
+func TestThreadRepository_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewThreadRepository(dir)
+	require.NoError(t, err)
+	require.NoError(t, r.CreateThread("thread-1"))
+	require.NoError(t, r.AppendMessage("thread-1", assistant.Message{Role: assistant.RoleUser, Content: "line\none"}))
+	require.NoError(t, r.SetMetadata("thread-1", "summary", "greeting"))
+
+	r, err = NewThreadRepository(dir)
+	require.NoError(t, err)
+
+	messages, err := r.GetMessages("thread-1")
+	require.NoError(t, err)
+	assert.Equal(t, []assistant.Message{{Role: assistant.RoleUser, Content: "line\none"}}, messages)
+
+	value, ok, err := r.GetMetadata("thread-1", "summary")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "greeting", value)
+}
+
+func TestThreadRepository_TornLine(t *testing.T) {
+	r := newTestRepository(t)
+	require.NoError(t, r.CreateThread("thread-1"))
+	require.NoError(t, r.AppendMessage("thread-1", assistant.Message{Role: assistant.RoleUser, Content: "one"}))
+
+	// Simulate a crash in the middle of an append.
+	f, err := os.OpenFile(r.path("thread-1", messagesExt), os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"role":"assistant","cont`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	messages, err := r.GetMessages("thread-1")
+	require.NoError(t, err)
+	assert.Equal(t, []assistant.Message{{Role: assistant.RoleUser, Content: "one"}}, messages)
+
+	require.NoError(t, r.AppendMessage("thread-1", assistant.Message{Role: assistant.RoleAssistant, Content: "two"}))
+
+	messages, err = r.GetMessages("thread-1")
+	require.NoError(t, err)
+	assert.Equal(t, []assistant.Message{
+		{Role: assistant.RoleUser, Content: "one"},
+		{Role: assistant.RoleAssistant, Content: "two"},
+	}, messages)
+}
+
+func TestThreadRepository_TornFirstLine(t *testing.T) {
+	r := newTestRepository(t)
+	require.NoError(t, r.CreateThread("thread-1"))
+	require.NoError(t, os.WriteFile(r.path("thread-1", messagesExt), []byte(`{"role":"us`), 0o644))
+
+	require.NoError(t, r.AppendMessage("thread-1", assistant.Message{Role: assistant.RoleUser, Content: "one"}))
+
+	messages, err := r.GetMessages("thread-1")
+	require.NoError(t, err)
+	assert.Equal(t, []assistant.Message{{Role: assistant.RoleUser, Content: "one"}}, messages)
+}
+
+func TestThreadRepository_CorruptLine(t *testing.T) {
+	r := newTestRepository(t)
+	require.NoError(t, r.CreateThread("thread-1"))
+	data := "{\"role\":\"user\",\"content\":\"one\"}\nnot json\n{\"role\":\"user\",\"content\":\"two\"}\n"
+	require.NoError(t, os.WriteFile(r.path("thread-1", messagesExt), []byte(data), 0o644))
+
+	_, err := r.GetMessages("thread-1")
+	assert.ErrorContains(t, err, "line 2")
+}
+
+func TestThreadRepository_DeleteThread(t *testing.T) {
+	r := newTestRepository(t)
+	require.NoError(t, r.CreateThread("thread-1"))
+	require.NoError(t, r.SetMetadata("thread-1", "summary", "greeting"))
+
+	require.NoError(t, r.DeleteThread("thread-1"))
+	require.NoError(t, r.DeleteThread("thread-1"))
+
+	ok, err := r.ThreadExists("thread-1")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	entries, err := os.ReadDir(r.dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,27 @@
+//go:build !unix
+
+package file
+
+import (
+	"os"
+	"sync"
+)
+
+// Without flock, locks only serialise goroutines of one process, so sharing
+// a directory between processes is not safe on these platforms. Shared locks
+// are taken exclusively.
+var mu sync.Mutex
+
+func lockFile(f *os.File, exclusive bool) error {
+	mu.Lock()
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	mu.Unlock()
+	return nil
+}
+
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,77 @@
+//go:build unix
+
+package file
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mwazovzky/assistant"
+)
+
+// TestMain lets the test binary act as a writer process for
+// TestThreadRepository_MultiProcess.
+func TestMain(m *testing.M) {
+	if dir := os.Getenv("FILE_TEST_WRITER_DIR"); dir != "" {
+		if err := appendFromProcess(dir, os.Getenv("FILE_TEST_WRITER_ID")); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+const processAppends = 50
+
+func appendFromProcess(dir string, id string) error {
+	r, err := NewThreadRepository(dir)
+	if err != nil {
+		return err
+	}
+	for i := range processAppends {
+		msg := assistant.Message{Role: assistant.RoleUser, Content: fmt.Sprintf("%s/%d", id, i)}
+		if err := r.AppendMessage("thread-1", msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestThreadRepository_MultiProcess(t *testing.T) {
+	const processes = 4
+
+	r := newTestRepository(t)
+	require.NoError(t, r.CreateThread("thread-1"))
+
+	cmds := make([]*exec.Cmd, processes)
+	for p := range cmds {
+		cmd := exec.Command(os.Args[0], "-test.run=^$")
+		cmd.Env = append(os.Environ(), "FILE_TEST_WRITER_DIR="+r.dir, "FILE_TEST_WRITER_ID="+strconv.Itoa(p))
+		cmd.Stderr = os.Stderr
+		require.NoError(t, cmd.Start())
+		cmds[p] = cmd
+	}
+	for _, cmd := range cmds {
+		require.NoError(t, cmd.Wait())
+	}
+
+	messages, err := r.GetMessages("thread-1")
+	require.NoError(t, err)
+	require.Len(t, messages, processes*processAppends)
+
+	next := make([]int, processes)
+	for _, msg := range messages {
+		var p, i int
+		_, err := fmt.Sscanf(msg.Content, "%d/%d", &p, &i)
+		require.NoError(t, err)
+		assert.Equal(t, next[p], i, "messages of process %d out of order", p)
+		next[p] = i + 1
+	}
+}
@@ -0,0 +1,206 @@
+// Package memory provides a goroutine-safe in-memory ThreadRepository.
+package memory
+
+import (
+	"container/list"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mwazovzky/assistant"
+)
+
+// Option configures a ThreadRepository.
+type Option func(*ThreadRepository)
+
+// WithMaxThreads limits the number of threads kept. Creating a thread beyond
+// the limit evicts the least recently used one.
+func WithMaxThreads(n int) Option {
+	return func(r *ThreadRepository) {
+		r.maxThreads = n
+	}
+}
+
+// WithTTL expires threads that have not been used for ttl.
+func WithTTL(ttl time.Duration) Option {
+	return func(r *ThreadRepository) {
+		r.ttl = ttl
+	}
+}
+
+type thread struct {
+	id       string
+	messages []assistant.Message
+	metadata map[string]string
+	used     time.Time
+}
+
+// ThreadRepository implements assistant.ThreadRepository and
+// assistant.ThreadMetadataRepository in memory. It is safe for concurrent
+// use. Messages are copied on the way in and out, so callers never share
+// memory with the repository.
+type ThreadRepository struct {
+	maxThreads int
+	ttl        time.Duration
+	now        func() time.Time
+
+	threads map[string]*list.Element
+	// lru orders threads from most to least recently used.
+	lru *list.List
+	mu  sync.Mutex
+}
+
+func NewThreadRepository(opts ...Option) *ThreadRepository {
+	r := &ThreadRepository{
+		now:     time.Now,
+		threads: map[string]*list.Element{},
+		lru:     list.New(),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *ThreadRepository) ThreadExists(tid string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.get(tid)
+	return ok, nil
+}
+
+func (r *ThreadRepository) CreateThread(tid string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.get(tid); ok {
+		return fmt.Errorf("%w: %s", assistant.ErrThreadExists, tid)
+	}
+
+	r.threads[tid] = r.lru.PushFront(&thread{id: tid, metadata: map[string]string{}, used: r.now()})
+	r.evict()
+	return nil
+}
+
+func (r *ThreadRepository) AppendMessage(tid string, msg assistant.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.get(tid)
+	if !ok {
+		return fmt.Errorf("%w: %s", assistant.ErrThreadNotFound, tid)
+	}
+
+	t.messages = append(t.messages, copyMessage(msg))
+	return nil
+}
+
+func (r *ThreadRepository) GetMessages(tid string) ([]assistant.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.get(tid)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", assistant.ErrThreadNotFound, tid)
+	}
+
+	messages := make([]assistant.Message, len(t.messages))
+	for i, msg := range t.messages {
+		messages[i] = copyMessage(msg)
+	}
+	return messages, nil
+}
+
+func (r *ThreadRepository) GetMetadata(tid string, key string) (string, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.get(tid)
+	if !ok {
+		return "", false, fmt.Errorf("%w: %s", assistant.ErrThreadNotFound, tid)
+	}
+
+	value, ok := t.metadata[key]
+	return value, ok, nil
+}
+
+func (r *ThreadRepository) SetMetadata(tid string, key string, value string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.get(tid)
+	if !ok {
+		return fmt.Errorf("%w: %s", assistant.ErrThreadNotFound, tid)
+	}
+
+	t.metadata[key] = value
+	return nil
+}
+
+// DeleteThread removes a thread. Deleting an unknown thread is not an error.
+func (r *ThreadRepository) DeleteThread(tid string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if e, ok := r.threads[tid]; ok {
+		r.remove(e)
+	}
+	return nil
+}
+
+// Len returns the number of threads held, including expired threads that
+// have not been removed yet.
+func (r *ThreadRepository) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.lru.Len()
+}
+
+// get returns a live thread and marks it as used. An expired thread is
+// removed. The caller holds r.mu.
+func (r *ThreadRepository) get(tid string) (*thread, bool) {
+	e, ok := r.threads[tid]
+	if !ok {
+		return nil, false
+	}
+
+	t := e.Value.(*thread)
+	now := r.now()
+	if r.expired(t, now) {
+		r.remove(e)
+		return nil, false
+	}
+
+	t.used = now
+	r.lru.MoveToFront(e)
+	return t, true
+}
+
+// evict drops expired threads from the tail and then least recently used
+// threads beyond the limit. The caller holds r.mu.
+func (r *ThreadRepository) evict() {
+	now := r.now()
+	for e := r.lru.Back(); e != nil && r.expired(e.Value.(*thread), now); e = r.lru.Back() {
+		r.remove(e)
+	}
+	for r.maxThreads > 0 && r.lru.Len() > r.maxThreads {
+		r.remove(r.lru.Back())
+	}
+}
+
+func (r *ThreadRepository) expired(t *thread, now time.Time) bool {
+	return r.ttl > 0 && now.Sub(t.used) >= r.ttl
+}
+
+func (r *ThreadRepository) remove(e *list.Element) {
+	r.lru.Remove(e)
+	delete(r.threads, e.Value.(*thread).id)
+}
+
+func copyMessage(msg assistant.Message) assistant.Message {
+	msg.ToolCalls = slices.Clone(msg.ToolCalls)
+	return msg
+}
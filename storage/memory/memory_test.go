@@ -0,0 +1,166 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mwazovzky/assistant"
+	"github.com/mwazovzky/assistant/storage/storagetest"
+)
+
+func TestThreadRepository(t *testing.T) {
+	storagetest.RunThreadRepositoryTests(t, func(t *testing.T) assistant.ThreadRepository {
+		return NewThreadRepository()
+	})
+}
+
+func TestThreadRepository_WithLimits(t *testing.T) {
+	storagetest.RunThreadRepositoryTests(t, func(t *testing.T) assistant.ThreadRepository {
+		return NewThreadRepository(WithMaxThreads(100), WithTTL(time.Hour))
+	})
+}
+
+// clock is a manually advanced time source
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestRepository(c *clock, opts ...Option) *ThreadRepository {
+	r := NewThreadRepository(opts...)
+	r.now = c.Now
+	return r
+}
+
+func exists(t *testing.T, r *ThreadRepository, tid string) bool {
+	t.Helper()
+	ok, err := r.ThreadExists(tid)
+	require.NoError(t, err)
+	return ok
+}
+
+func TestThreadRepository_CreateExisting(t *testing.T) {
+	r := NewThreadRepository()
+	require.NoError(t, r.CreateThread("thread-1"))
+
+	assert.ErrorIs(t, r.CreateThread("thread-1"), assistant.ErrThreadExists)
+}
+
+func TestThreadRepository_CopiesOnWrite(t *testing.T) {
+	r := NewThreadRepository()
+	require.NoError(t, r.CreateThread("thread-1"))
+
+	calls := []assistant.ToolCall{{ID: "call-1"}}
+	require.NoError(t, r.AppendMessage("thread-1", assistant.Message{Role: assistant.RoleAssistant, ToolCalls: calls}))
+	calls[0].ID = "changed"
+
+	messages, err := r.GetMessages("thread-1")
+	require.NoError(t, err)
+	assert.Equal(t, "call-1", messages[0].ToolCalls[0].ID)
+
+	messages[0].ToolCalls[0].ID = "changed"
+	messages, err = r.GetMessages("thread-1")
+	require.NoError(t, err)
+	assert.Equal(t, "call-1", messages[0].ToolCalls[0].ID)
+}
+
+func TestThreadRepository_MaxThreads(t *testing.T) {
+	c := &clock{now: time.Now()}
+	r := newTestRepository(c, WithMaxThreads(2))
+
+	require.NoError(t, r.CreateThread("a"))
+	require.NoError(t, r.CreateThread("b"))
+	// Using a makes b the least recently used thread.
+	require.NoError(t, r.AppendMessage("a", assistant.Message{Role: assistant.RoleUser, Content: "hi"}))
+	require.NoError(t, r.CreateThread("c"))
+
+	assert.True(t, exists(t, r, "a"))
+	assert.False(t, exists(t, r, "b"))
+	assert.True(t, exists(t, r, "c"))
+	assert.Equal(t, 2, r.Len())
+}
+
+func TestThreadRepository_TTL(t *testing.T) {
+	c := &clock{now: time.Now()}
+	r := newTestRepository(c, WithTTL(time.Minute))
+
+	require.NoError(t, r.CreateThread("a"))
+	require.NoError(t, r.CreateThread("b"))
+
+	c.Advance(40 * time.Second)
+	require.NoError(t, r.AppendMessage("a", assistant.Message{Role: assistant.RoleUser, Content: "hi"}))
+	c.Advance(40 * time.Second)
+
+	assert.True(t, exists(t, r, "a"), "use extends the lifetime")
+	assert.False(t, exists(t, r, "b"))
+	_, err := r.GetMessages("b")
+	assert.ErrorIs(t, err, assistant.ErrThreadNotFound)
+
+	// An expired thread can be created again.
+	require.NoError(t, r.CreateThread("b"))
+	messages, err := r.GetMessages("b")
+	require.NoError(t, err)
+	assert.Empty(t, messages)
+}
+
+func TestThreadRepository_TTLEvictsOnCreate(t *testing.T) {
+	c := &clock{now: time.Now()}
+	r := newTestRepository(c, WithTTL(time.Minute))
+
+	require.NoError(t, r.CreateThread("a"))
+	require.NoError(t, r.CreateThread("b"))
+	c.Advance(2 * time.Minute)
+	require.NoError(t, r.CreateThread("c"))
+
+	assert.Equal(t, 1, r.Len())
+}
+
+func TestThreadRepository_DeleteThread(t *testing.T) {
+	r := NewThreadRepository()
+	require.NoError(t, r.CreateThread("a"))
+
+	require.NoError(t, r.DeleteThread("a"))
+	require.NoError(t, r.DeleteThread("missing"))
+
+	assert.False(t, exists(t, r, "a"))
+	assert.Equal(t, 0, r.Len())
+}
+
+func TestThreadRepository_Metadata(t *testing.T) {
+	r := NewThreadRepository()
+
+	_, _, err := r.GetMetadata("missing", "summary")
+	assert.ErrorIs(t, err, assistant.ErrThreadNotFound)
+	assert.ErrorIs(t, r.SetMetadata("missing", "summary", "x"), assistant.ErrThreadNotFound)
+}
+
+func TestThreadRepository_Assistant(t *testing.T) {
+	r := NewThreadRepository()
+	client := echoClient{}
+
+	a := assistant.NewAssistant("gpt-4o-mini", "You are assistant", client, r)
+	reply, err := a.Ask("thread-1", "hello")
+
+	require.NoError(t, err)
+	assert.Equal(t, "hello", reply)
+	messages, err := r.GetMessages("thread-1")
+	require.NoError(t, err)
+	assert.Len(t, messages, 3)
+}
+
+// echoClient replies with the content of the last message
+type echoClient struct{}
+
+func (echoClient) Request(model string, msgs []assistant.Message) (assistant.Message, assistant.Usage, error) {
+	return assistant.Message{Role: assistant.RoleAssistant, Content: msgs[len(msgs)-1].Content}, assistant.Usage{}, nil
+}
@@ -0,0 +1,54 @@
+package sql
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dialect describes the differences between the supported databases.
+type Dialect struct {
+	name string
+	// numbered placeholders are written as $1, $2, ... instead of ?.
+	numbered bool
+	// lockMigrations serialises concurrent Migrate calls inside the
+	// migration transaction. It is empty when the database needs no lock.
+	lockMigrations string
+}
+
+var (
+	// SQLite works with SQLite 3.24 or later, for example through
+	// github.com/mattn/go-sqlite3 or modernc.org/sqlite.
+	SQLite = Dialect{name: "sqlite"}
+	// Postgres works with PostgreSQL 9.5 or later, for example through
+	// github.com/jackc/pgx/v5/stdlib or github.com/lib/pq.
+	Postgres = Dialect{
+		name:           "postgres",
+		numbered:       true,
+		lockMigrations: "LOCK TABLE schema_migrations IN ACCESS EXCLUSIVE MODE",
+	}
+)
+
+func (d Dialect) String() string {
+	return d.name
+}
+
+// rebind rewrites the ? placeholders of query for the dialect. Queries in
+// this package never contain ? inside literals.
+func (d Dialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c != '?' {
+			b.WriteRune(c)
+			continue
+		}
+		n++
+		b.WriteByte('$')
+		b.WriteString(strconv.Itoa(n))
+	}
+	return b.String()
+}
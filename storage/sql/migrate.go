@@ -0,0 +1,95 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations
+var migrations embed.FS
+
+type migration struct {
+	version int
+	name    string
+	query   string
+}
+
+// loadMigrations returns the migrations of a dialect ordered by version. Each
+// file is named <version>_<description>.sql.
+func loadMigrations(d Dialect) ([]migration, error) {
+	dir := path.Join("migrations", d.name)
+	entries, err := fs.ReadDir(migrations, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	var list []migration
+	for _, e := range entries {
+		prefix, _, ok := strings.Cut(e.Name(), "_")
+		if !ok || !strings.HasSuffix(e.Name(), ".sql") {
+			return nil, fmt.Errorf("invalid migration name: %s", e.Name())
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration name: %s", e.Name())
+		}
+		query, err := fs.ReadFile(migrations, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration: %w", err)
+		}
+		list = append(list, migration{version: version, name: e.Name(), query: string(query)})
+	}
+
+	slices.SortFunc(list, func(a, b migration) int { return a.version - b.version })
+	return list, nil
+}
+
+// Migrate creates or upgrades the schema. Applied versions are recorded in
+// the schema_migrations table and every migration runs in its own
+// transaction, so Migrate is safe to call on every start.
+func (r *ThreadRepository) Migrate(ctx context.Context) error {
+	list, err := loadMigrations(r.dialect)
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)"); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	for _, m := range list {
+		if err := r.migrate(ctx, m); err != nil {
+			return fmt.Errorf("failed to apply migration %s: %w", m.name, err)
+		}
+	}
+	return nil
+}
+
+func (r *ThreadRepository) migrate(ctx context.Context, m migration) error {
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		if r.dialect.lockMigrations != "" {
+			if _, err := tx.ExecContext(ctx, r.dialect.lockMigrations); err != nil {
+				return err
+			}
+		}
+
+		var applied int
+		err := tx.QueryRowContext(ctx, r.dialect.rebind("SELECT COUNT(*) FROM schema_migrations WHERE version = ?"), m.version).Scan(&applied)
+		if err != nil || applied > 0 {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, m.query); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, r.dialect.rebind("INSERT INTO schema_migrations (version) VALUES (?)"), m.version)
+		return err
+	})
+}
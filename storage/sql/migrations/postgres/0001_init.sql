@@ -0,0 +1,23 @@
+CREATE TABLE threads (
+    id         TEXT PRIMARY KEY,
+    last_seq   BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE messages (
+    thread_id    TEXT NOT NULL REFERENCES threads (id),
+    seq          BIGINT NOT NULL,
+    role         TEXT NOT NULL,
+    content      TEXT NOT NULL,
+    tool_calls   JSONB,
+    tool_call_id TEXT NOT NULL DEFAULT '',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (thread_id, seq)
+);
+
+CREATE TABLE thread_metadata (
+    thread_id TEXT NOT NULL REFERENCES threads (id),
+    key       TEXT NOT NULL,
+    value     TEXT NOT NULL,
+    PRIMARY KEY (thread_id, key)
+);
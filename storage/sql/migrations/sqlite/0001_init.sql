@@ -0,0 +1,23 @@
+CREATE TABLE threads (
+    id         TEXT PRIMARY KEY,
+    last_seq   INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE messages (
+    thread_id    TEXT NOT NULL REFERENCES threads (id),
+    seq          INTEGER NOT NULL,
+    role         TEXT NOT NULL,
+    content      TEXT NOT NULL,
+    tool_calls   TEXT,
+    tool_call_id TEXT NOT NULL DEFAULT '',
+    created_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (thread_id, seq)
+);
+
+CREATE TABLE thread_metadata (
+    thread_id TEXT NOT NULL REFERENCES threads (id),
+    key       TEXT NOT NULL,
+    value     TEXT NOT NULL,
+    PRIMARY KEY (thread_id, key)
+);
@@ -0,0 +1,265 @@
+// Package sql provides a ThreadRepository over database/sql for SQLite and
+// PostgreSQL.
+//
+// Threads are rows of the threads table and messages rows of the messages
+// table, numbered per thread by a seq column. The schema is embedded and
+// applied by Migrate:
+//
+//	db, err := sql.Open("sqlite3", "threads.db?_busy_timeout=5000&_txlock=immediate")
+//	threads := storagesql.NewThreadRepository(db, storagesql.SQLite)
+//	if err := threads.Migrate(ctx); err != nil {
+//		log.Fatal(err)
+//	}
+//
+// The package does not import a driver. SQLite databases should be opened
+// with a busy timeout so concurrent appends wait for each other instead of
+// failing.
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mwazovzky/assistant"
+)
+
+// ThreadRepository implements assistant.ThreadRepository,
+// assistant.ContextThreadRepository, assistant.ThreadMetadataRepository and
+// assistant.ContextThreadMetadataRepository on a database. It is safe for
+// concurrent use by multiple goroutines and processes.
+type ThreadRepository struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+// NewThreadRepository returns a repository using db, which must be opened
+// with a driver for dialect. Call Migrate before first use.
+func NewThreadRepository(db *sql.DB, dialect Dialect) *ThreadRepository {
+	return &ThreadRepository{db: db, dialect: dialect}
+}
+
+func (r *ThreadRepository) ThreadExists(tid string) (bool, error) {
+	return r.ThreadExistsContext(context.Background(), tid)
+}
+
+func (r *ThreadRepository) CreateThread(tid string) error {
+	return r.CreateThreadContext(context.Background(), tid)
+}
+
+func (r *ThreadRepository) AppendMessage(tid string, msg assistant.Message) error {
+	return r.AppendMessageContext(context.Background(), tid, msg)
+}
+
+func (r *ThreadRepository) GetMessages(tid string) ([]assistant.Message, error) {
+	return r.GetMessagesContext(context.Background(), tid)
+}
+
+func (r *ThreadRepository) ThreadExistsContext(ctx context.Context, tid string) (bool, error) {
+	ok, err := threadExists(ctx, r.db, r.dialect, tid)
+	if err != nil {
+		return false, fmt.Errorf("failed to query thread: %w", err)
+	}
+	return ok, nil
+}
+
+func (r *ThreadRepository) CreateThreadContext(ctx context.Context, tid string) error {
+	res, err := r.db.ExecContext(ctx, r.dialect.rebind("INSERT INTO threads (id) VALUES (?) ON CONFLICT (id) DO NOTHING"), tid)
+	if err != nil {
+		return fmt.Errorf("failed to create thread: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to create thread: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %s", assistant.ErrThreadExists, tid)
+	}
+	return nil
+}
+
+// AppendMessageContext numbers the message from the thread's last_seq
+// counter and inserts it in one transaction. Updating the counter first locks
+// the thread row, so concurrent appends to a thread are serialised.
+func (r *ThreadRepository) AppendMessageContext(ctx context.Context, tid string, msg assistant.Message) error {
+	var toolCalls sql.NullString
+	if len(msg.ToolCalls) > 0 {
+		data, err := json.Marshal(msg.ToolCalls)
+		if err != nil {
+			return fmt.Errorf("failed to marshal tool calls: %w", err)
+		}
+		toolCalls = sql.NullString{String: string(data), Valid: true}
+	}
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, r.dialect.rebind("UPDATE threads SET last_seq = last_seq + 1 WHERE id = ?"), tid)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("%w: %s", assistant.ErrThreadNotFound, tid)
+		}
+
+		var seq int64
+		if err := tx.QueryRowContext(ctx, r.dialect.rebind("SELECT last_seq FROM threads WHERE id = ?"), tid).Scan(&seq); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, r.dialect.rebind(
+			"INSERT INTO messages (thread_id, seq, role, content, tool_calls, tool_call_id) VALUES (?, ?, ?, ?, ?, ?)"),
+			tid, seq, msg.Role, msg.Content, toolCalls, msg.ToolCallID)
+		return err
+	})
+	if errors.Is(err, assistant.ErrThreadNotFound) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to append message: %w", err)
+	}
+	return nil
+}
+
+func (r *ThreadRepository) GetMessagesContext(ctx context.Context, tid string) ([]assistant.Message, error) {
+	ok, err := threadExists(ctx, r.db, r.dialect, tid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query thread: %w", err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", assistant.ErrThreadNotFound, tid)
+	}
+
+	rows, err := r.db.QueryContext(ctx, r.dialect.rebind(
+		"SELECT role, content, tool_calls, tool_call_id FROM messages WHERE thread_id = ? ORDER BY seq"), tid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []assistant.Message{}
+	for rows.Next() {
+		var msg assistant.Message
+		var toolCalls sql.NullString
+		if err := rows.Scan(&msg.Role, &msg.Content, &toolCalls, &msg.ToolCallID); err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		if toolCalls.Valid {
+			if err := json.Unmarshal([]byte(toolCalls.String), &msg.ToolCalls); err != nil {
+				return nil, fmt.Errorf("failed to decode tool calls: %w", err)
+			}
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query messages: %w", err)
+	}
+	return messages, nil
+}
+
+func (r *ThreadRepository) GetMetadata(tid string, key string) (string, bool, error) {
+	return r.GetMetadataContext(context.Background(), tid, key)
+}
+
+func (r *ThreadRepository) SetMetadata(tid string, key string, value string) error {
+	return r.SetMetadataContext(context.Background(), tid, key, value)
+}
+
+func (r *ThreadRepository) GetMetadataContext(ctx context.Context, tid string, key string) (string, bool, error) {
+	var value string
+	err := r.db.QueryRowContext(ctx, r.dialect.rebind(
+		"SELECT value FROM thread_metadata WHERE thread_id = ? AND key = ?"), tid, key).Scan(&value)
+	if err == nil {
+		return value, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", false, fmt.Errorf("failed to query metadata: %w", err)
+	}
+
+	ok, err := threadExists(ctx, r.db, r.dialect, tid)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to query thread: %w", err)
+	}
+	if !ok {
+		return "", false, fmt.Errorf("%w: %s", assistant.ErrThreadNotFound, tid)
+	}
+	return "", false, nil
+}
+
+func (r *ThreadRepository) SetMetadataContext(ctx context.Context, tid string, key string, value string) error {
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		ok, err := threadExists(ctx, tx, r.dialect, tid)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w: %s", assistant.ErrThreadNotFound, tid)
+		}
+
+		_, err = tx.ExecContext(ctx, r.dialect.rebind(
+			"INSERT INTO thread_metadata (thread_id, key, value) VALUES (?, ?, ?) "+
+				"ON CONFLICT (thread_id, key) DO UPDATE SET value = excluded.value"),
+			tid, key, value)
+		return err
+	})
+	if errors.Is(err, assistant.ErrThreadNotFound) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set metadata: %w", err)
+	}
+	return nil
+}
+
+// DeleteThread removes a thread with its messages and metadata. Deleting an
+// unknown thread is not an error.
+func (r *ThreadRepository) DeleteThread(tid string) error {
+	ctx := context.Background()
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		for _, query := range []string{
+			"DELETE FROM thread_metadata WHERE thread_id = ?",
+			"DELETE FROM messages WHERE thread_id = ?",
+			"DELETE FROM threads WHERE id = ?",
+		} {
+			if _, err := tx.ExecContext(ctx, r.dialect.rebind(query), tid); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete thread: %w", err)
+	}
+	return nil
+}
+
+// inTx runs fn in a transaction, committing when fn succeeds and rolling
+// back otherwise.
+func (r *ThreadRepository) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func threadExists(ctx context.Context, q queryer, d Dialect, tid string) (bool, error) {
+	var n int
+	if err := q.QueryRowContext(ctx, d.rebind("SELECT COUNT(*) FROM threads WHERE id = ?"), tid).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
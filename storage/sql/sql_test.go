@@ -0,0 +1,167 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mwazovzky/assistant"
+	"github.com/mwazovzky/assistant/storage/storagetest"
+)
+
+func TestThreadRepository(t *testing.T) {
+	storagetest.RunThreadRepositoryTests(t, func(t *testing.T) assistant.ThreadRepository {
+		return newTestRepository(t)
+	})
+}
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "threads.db") + "?_busy_timeout=5000&_txlock=immediate"
+	db, err := sql.Open("sqlite3", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newTestRepository(t *testing.T) *ThreadRepository {
+	t.Helper()
+	r := NewThreadRepository(openTestDB(t), SQLite)
+	require.NoError(t, r.Migrate(context.Background()))
+	return r
+}
+
+func TestDialect_Rebind(t *testing.T) {
+	query := "INSERT INTO messages (thread_id, seq) VALUES (?, ?)"
+
+	assert.Equal(t, query, SQLite.rebind(query))
+	assert.Equal(t, "INSERT INTO messages (thread_id, seq) VALUES ($1, $2)", Postgres.rebind(query))
+}
+
+func TestLoadMigrations(t *testing.T) {
+	for _, d := range []Dialect{SQLite, Postgres} {
+		list, err := loadMigrations(d)
+		require.NoError(t, err, d.String())
+		require.NotEmpty(t, list, d.String())
+		for i, m := range list {
+			assert.Equal(t, i+1, m.version, m.name)
+		}
+	}
+}
+
+func TestThreadRepository_MigrateTwice(t *testing.T) {
+	db := openTestDB(t)
+	r := NewThreadRepository(db, SQLite)
+	require.NoError(t, r.Migrate(context.Background()))
+	require.NoError(t, r.Migrate(context.Background()))
+
+	var n int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&n))
+	list, err := loadMigrations(SQLite)
+	require.NoError(t, err)
+	assert.Equal(t, len(list), n)
+}
+
+func TestThreadRepository_ToolCalls(t *testing.T) {
+	r := newTestRepository(t)
+	require.NoError(t, r.CreateThread("thread-1"))
+
+	call := assistant.Message{
+		Role: assistant.RoleAssistant,
+		ToolCalls: []assistant.ToolCall{{
+			ID:       "call-1",
+			Type:     "function",
+			Function: assistant.FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`},
+		}},
+	}
+	result := assistant.Message{Role: assistant.RoleTool, Content: "sunny", ToolCallID: "call-1"}
+	require.NoError(t, r.AppendMessage("thread-1", call))
+	require.NoError(t, r.AppendMessage("thread-1", result))
+
+	messages, err := r.GetMessages("thread-1")
+	require.NoError(t, err)
+	assert.Equal(t, []assistant.Message{call, result}, messages)
+}
+
+func TestThreadRepository_Sequence(t *testing.T) {
+	r := newTestRepository(t)
+	require.NoError(t, r.CreateThread("thread-1"))
+	for i := range 3 {
+		require.NoError(t, r.AppendMessage("thread-1", assistant.Message{Role: assistant.RoleUser, Content: fmt.Sprint(i)}))
+	}
+
+	rows, err := r.db.Query("SELECT seq FROM messages WHERE thread_id = ? ORDER BY seq", "thread-1")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var seqs []int
+	for rows.Next() {
+		var seq int
+		require.NoError(t, rows.Scan(&seq))
+		seqs = append(seqs, seq)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []int{1, 2, 3}, seqs)
+}
+
+func TestThreadRepository_AppendRollsBack(t *testing.T) {
+	r := newTestRepository(t)
+	require.NoError(t, r.CreateThread("thread-1"))
+	_, err := r.db.Exec("CREATE TRIGGER reject BEFORE INSERT ON messages WHEN NEW.content = 'reject' BEGIN SELECT RAISE(ABORT, 'rejected'); END")
+	require.NoError(t, err)
+
+	assert.Error(t, r.AppendMessage("thread-1", assistant.Message{Role: assistant.RoleUser, Content: "reject"}))
+	require.NoError(t, r.AppendMessage("thread-1", assistant.Message{Role: assistant.RoleUser, Content: "accept"}))
+
+	var seq int
+	require.NoError(t, r.db.QueryRow("SELECT seq FROM messages WHERE thread_id = ?", "thread-1").Scan(&seq))
+	assert.Equal(t, 1, seq, "failed append must not consume a sequence number")
+}
+
+func TestThreadRepository_DeleteThread(t *testing.T) {
+	r := newTestRepository(t)
+	require.NoError(t, r.CreateThread("thread-1"))
+	require.NoError(t, r.AppendMessage("thread-1", assistant.Message{Role: assistant.RoleUser, Content: "hello"}))
+	require.NoError(t, r.SetMetadata("thread-1", "summary", "greeting"))
+
+	require.NoError(t, r.DeleteThread("thread-1"))
+	require.NoError(t, r.DeleteThread("thread-1"))
+
+	ok, err := r.ThreadExists("thread-1")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, r.CreateThread("thread-1"))
+	messages, err := r.GetMessages("thread-1")
+	require.NoError(t, err)
+	assert.Empty(t, messages)
+}
+
+func TestThreadRepository_MetadataContext(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRepository(t)
+	require.NoError(t, r.CreateThreadContext(ctx, "thread-1"))
+
+	require.NoError(t, r.SetMetadataContext(ctx, "thread-1", "summary", "first"))
+	value, ok, err := r.GetMetadataContext(ctx, "thread-1", "summary")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "first", value)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	assert.ErrorIs(t, r.SetMetadataContext(cancelled, "thread-1", "summary", "second"), context.Canceled)
+	_, _, err = r.GetMetadataContext(cancelled, "thread-1", "summary")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	value, _, err = r.GetMetadata("thread-1", "summary")
+	require.NoError(t, err)
+	assert.Equal(t, "first", value, "a cancelled write must not be stored")
+}
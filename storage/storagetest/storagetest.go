@@ -0,0 +1,295 @@
+// Package storagetest provides a conformance suite for
+// assistant.ThreadRepository implementations.
+//
+// A backend proves it behaves the way Assistant expects by running the suite
+// from its own tests:
+//
+//	func TestThreadRepository(t *testing.T) {
+//		storagetest.RunThreadRepositoryTests(t, func(t *testing.T) assistant.ThreadRepository {
+//			return memory.NewThreadRepository()
+//		})
+//	}
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mwazovzky/assistant"
+)
+
+// Factory returns a new, empty repository for each test.
+type Factory func(t *testing.T) assistant.ThreadRepository
+
+// RunThreadRepositoryTests runs the conformance suite against repositories
+// created by factory. It checks the contract Assistant relies on:
+//
+//   - ThreadExists reports false before and true after CreateThread
+//   - messages are returned in the order they were appended, losslessly
+//   - operations on unknown threads fail with assistant.ErrThreadNotFound
+//   - creating an existing thread fails with assistant.ErrThreadExists and
+//     keeps its messages
+//   - returned slices do not share memory with the repository
+//   - threads are isolated from each other
+//   - concurrent appends are neither lost nor reordered per writer
+//
+// Metadata tests are skipped unless the repository implements
+// assistant.ThreadMetadataRepository, and context tests unless it implements
+// assistant.ContextThreadRepository. Every subtest calls factory exactly once.
+func RunThreadRepositoryTests(t *testing.T, factory Factory) {
+	t.Run("ThreadExists", func(t *testing.T) { testThreadExists(t, factory(t)) })
+	t.Run("AppendOrder", func(t *testing.T) { testAppendOrder(t, factory(t)) })
+	t.Run("UnknownThread", func(t *testing.T) { testUnknownThread(t, factory(t)) })
+	t.Run("DuplicateCreate", func(t *testing.T) { testDuplicateCreate(t, factory(t)) })
+	t.Run("ReturnedSliceIsolation", func(t *testing.T) { testReturnedSliceIsolation(t, factory(t)) })
+	t.Run("ThreadIsolation", func(t *testing.T) { testThreadIsolation(t, factory(t)) })
+	t.Run("ThreadIDs", func(t *testing.T) { testThreadIDs(t, factory(t)) })
+	t.Run("ConcurrentAppends", func(t *testing.T) { testConcurrentAppends(t, factory(t)) })
+	t.Run("ConcurrentThreads", func(t *testing.T) { testConcurrentThreads(t, factory(t)) })
+
+	t.Run("Metadata", func(t *testing.T) {
+		repo, ok := factory(t).(assistant.ThreadMetadataRepository)
+		if !ok {
+			t.Skip("repository does not implement assistant.ThreadMetadataRepository")
+		}
+		testMetadata(t, repo)
+	})
+	t.Run("Context", func(t *testing.T) {
+		repo, ok := factory(t).(assistant.ContextThreadRepository)
+		if !ok {
+			t.Skip("repository does not implement assistant.ContextThreadRepository")
+		}
+		testContext(t, repo)
+	})
+}
+
+func testThreadExists(t *testing.T, repo assistant.ThreadRepository) {
+	exists, err := repo.ThreadExists("thread-1")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	require.NoError(t, repo.CreateThread("thread-1"))
+
+	exists, err = repo.ThreadExists("thread-1")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	messages, err := repo.GetMessages("thread-1")
+	require.NoError(t, err)
+	assert.Empty(t, messages)
+}
+
+func testAppendOrder(t *testing.T, repo assistant.ThreadRepository) {
+	require.NoError(t, repo.CreateThread("thread-1"))
+
+	want := []assistant.Message{
+		{Role: assistant.RoleSystem, Content: "You are a helpful assistant."},
+		{Role: assistant.RoleUser, Content: "What is the weather?"},
+		{Role: assistant.RoleAssistant, ToolCalls: []assistant.ToolCall{
+			{ID: "call-1", Type: "function", Function: assistant.FunctionCall{Name: "weather", Arguments: `{"city":"Paris"}`}},
+		}},
+		{Role: assistant.RoleTool, Content: "sunny", ToolCallID: "call-1"},
+		{Role: assistant.RoleAssistant, Content: "It is sunny."},
+	}
+	for _, msg := range want {
+		require.NoError(t, repo.AppendMessage("thread-1", msg))
+	}
+
+	messages, err := repo.GetMessages("thread-1")
+	require.NoError(t, err)
+	assert.Equal(t, want, messages)
+}
+
+func testDuplicateCreate(t *testing.T, repo assistant.ThreadRepository) {
+	require.NoError(t, repo.CreateThread("thread-1"))
+	require.NoError(t, repo.AppendMessage("thread-1", assistant.Message{Role: assistant.RoleUser, Content: "kept"}))
+
+	err := repo.CreateThread("thread-1")
+	assert.ErrorIs(t, err, assistant.ErrThreadExists)
+
+	messages, err := repo.GetMessages("thread-1")
+	require.NoError(t, err)
+	assert.Equal(t, []assistant.Message{{Role: assistant.RoleUser, Content: "kept"}}, messages)
+}
+
+func testUnknownThread(t *testing.T, repo assistant.ThreadRepository) {
+	err := repo.AppendMessage("missing", assistant.Message{Role: assistant.RoleUser, Content: "hi"})
+	assert.ErrorIs(t, err, assistant.ErrThreadNotFound)
+
+	_, err = repo.GetMessages("missing")
+	assert.ErrorIs(t, err, assistant.ErrThreadNotFound)
+
+	exists, err := repo.ThreadExists("missing")
+	require.NoError(t, err)
+	assert.False(t, exists, "appending to an unknown thread must not create it")
+}
+
+func testReturnedSliceIsolation(t *testing.T, repo assistant.ThreadRepository) {
+	require.NoError(t, repo.CreateThread("thread-1"))
+	require.NoError(t, repo.AppendMessage("thread-1", assistant.Message{Role: assistant.RoleUser, Content: "first"}))
+
+	messages, err := repo.GetMessages("thread-1")
+	require.NoError(t, err)
+	messages[0].Content = "changed"
+	_ = append(messages, assistant.Message{Role: assistant.RoleUser, Content: "appended"})
+
+	messages, err = repo.GetMessages("thread-1")
+	require.NoError(t, err)
+	assert.Equal(t, []assistant.Message{{Role: assistant.RoleUser, Content: "first"}}, messages)
+}
+
+func testThreadIsolation(t *testing.T, repo assistant.ThreadRepository) {
+	require.NoError(t, repo.CreateThread("thread-1"))
+	require.NoError(t, repo.CreateThread("thread-2"))
+	require.NoError(t, repo.AppendMessage("thread-1", assistant.Message{Role: assistant.RoleUser, Content: "one"}))
+	require.NoError(t, repo.AppendMessage("thread-2", assistant.Message{Role: assistant.RoleUser, Content: "two"}))
+
+	messages, err := repo.GetMessages("thread-1")
+	require.NoError(t, err)
+	assert.Equal(t, []assistant.Message{{Role: assistant.RoleUser, Content: "one"}}, messages)
+
+	messages, err = repo.GetMessages("thread-2")
+	require.NoError(t, err)
+	assert.Equal(t, []assistant.Message{{Role: assistant.RoleUser, Content: "two"}}, messages)
+}
+
+// testThreadIDs checks that thread IDs are opaque: IDs that differ only in
+// characters a backend might normalise must remain distinct threads.
+func testThreadIDs(t *testing.T, repo assistant.ThreadRepository) {
+	ids := []string{
+		"user-123",
+		"User-123",
+		"user_123",
+		"user/123",
+		"../user-123",
+		"user 123",
+		"ユーザー",
+		"550e8400-e29b-41d4-a716-446655440000",
+	}
+	for _, tid := range ids {
+		require.NoError(t, repo.CreateThread(tid), tid)
+		require.NoError(t, repo.AppendMessage(tid, assistant.Message{Role: assistant.RoleUser, Content: tid}), tid)
+	}
+
+	for _, tid := range ids {
+		messages, err := repo.GetMessages(tid)
+		require.NoError(t, err, tid)
+		assert.Equal(t, []assistant.Message{{Role: assistant.RoleUser, Content: tid}}, messages, tid)
+	}
+}
+
+func testConcurrentAppends(t *testing.T, repo assistant.ThreadRepository) {
+	const writers, appends = 8, 25
+
+	require.NoError(t, repo.CreateThread("thread-1"))
+
+	var wg sync.WaitGroup
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range appends {
+				msg := assistant.Message{Role: assistant.RoleUser, Content: fmt.Sprintf("%d/%d", w, i)}
+				assert.NoError(t, repo.AppendMessage("thread-1", msg))
+			}
+		}()
+	}
+	wg.Wait()
+
+	messages, err := repo.GetMessages("thread-1")
+	require.NoError(t, err)
+	require.Len(t, messages, writers*appends)
+
+	next := make([]int, writers)
+	for _, msg := range messages {
+		var w, i int
+		_, err := fmt.Sscanf(msg.Content, "%d/%d", &w, &i)
+		require.NoError(t, err)
+		assert.Equal(t, next[w], i, "messages of writer %d out of order", w)
+		next[w] = i + 1
+	}
+}
+
+func testConcurrentThreads(t *testing.T, repo assistant.ThreadRepository) {
+	const threads = 8
+
+	var wg sync.WaitGroup
+	for n := range threads {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tid := fmt.Sprintf("thread-%d", n)
+			assert.NoError(t, repo.CreateThread(tid))
+			for i := range 5 {
+				assert.NoError(t, repo.AppendMessage(tid, assistant.Message{Role: assistant.RoleUser, Content: fmt.Sprint(i)}))
+			}
+		}()
+	}
+	wg.Wait()
+
+	for n := range threads {
+		messages, err := repo.GetMessages(fmt.Sprintf("thread-%d", n))
+		require.NoError(t, err)
+		assert.Len(t, messages, 5)
+	}
+}
+
+func testMetadata(t *testing.T, repo assistant.ThreadMetadataRepository) {
+	threads := repo.(assistant.ThreadRepository)
+	require.NoError(t, threads.CreateThread("thread-1"))
+
+	_, ok, err := repo.GetMetadata("thread-1", "summary")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, repo.SetMetadata("thread-1", "summary", "first"))
+	require.NoError(t, repo.SetMetadata("thread-1", "summary", "second"))
+
+	value, ok, err := repo.GetMetadata("thread-1", "summary")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "second", value)
+
+	// Metadata is per thread and does not show up as messages.
+	require.NoError(t, threads.CreateThread("thread-2"))
+	_, ok, err = repo.GetMetadata("thread-2", "summary")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	messages, err := threads.GetMessages("thread-1")
+	require.NoError(t, err)
+	assert.Empty(t, messages)
+
+	_, _, err = repo.GetMetadata("missing", "summary")
+	assert.ErrorIs(t, err, assistant.ErrThreadNotFound)
+	assert.ErrorIs(t, repo.SetMetadata("missing", "summary", "x"), assistant.ErrThreadNotFound)
+}
+
+func testContext(t *testing.T, repo assistant.ContextThreadRepository) {
+	ctx := context.Background()
+	require.NoError(t, repo.CreateThreadContext(ctx, "thread-1"))
+	require.NoError(t, repo.AppendMessageContext(ctx, "thread-1", assistant.Message{Role: assistant.RoleUser, Content: "hi"}))
+
+	exists, err := repo.ThreadExistsContext(ctx, "thread-1")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	messages, err := repo.GetMessagesContext(ctx, "thread-1")
+	require.NoError(t, err)
+	assert.Equal(t, []assistant.Message{{Role: assistant.RoleUser, Content: "hi"}}, messages)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	err = repo.AppendMessageContext(cancelled, "thread-1", assistant.Message{Role: assistant.RoleUser, Content: "late"})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	messages, err = repo.GetMessagesContext(ctx, "thread-1")
+	require.NoError(t, err)
+	assert.Len(t, messages, 1, "a cancelled append must not be stored")
+}
@@ -0,0 +1,147 @@
+package storagetest_test
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/mwazovzky/assistant"
+	"github.com/mwazovzky/assistant/storage/storagetest"
+)
+
+// contextRepo is a minimal reference repository implementing the context
+// and metadata extensions, so every part of the suite runs.
+type contextRepo struct {
+	mu       sync.Mutex
+	threads  map[string][]assistant.Message
+	metadata map[string]map[string]string
+}
+
+func newContextRepo() *contextRepo {
+	return &contextRepo{threads: map[string][]assistant.Message{}, metadata: map[string]map[string]string{}}
+}
+
+func (r *contextRepo) ThreadExists(tid string) (bool, error) {
+	return r.ThreadExistsContext(context.Background(), tid)
+}
+
+func (r *contextRepo) CreateThread(tid string) error {
+	return r.CreateThreadContext(context.Background(), tid)
+}
+
+func (r *contextRepo) AppendMessage(tid string, msg assistant.Message) error {
+	return r.AppendMessageContext(context.Background(), tid, msg)
+}
+
+func (r *contextRepo) GetMessages(tid string) ([]assistant.Message, error) {
+	return r.GetMessagesContext(context.Background(), tid)
+}
+
+func (r *contextRepo) ThreadExistsContext(ctx context.Context, tid string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.threads[tid]
+	return ok, nil
+}
+
+func (r *contextRepo) CreateThreadContext(ctx context.Context, tid string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.threads[tid]; ok {
+		return fmt.Errorf("%w: %s", assistant.ErrThreadExists, tid)
+	}
+	r.threads[tid] = []assistant.Message{}
+	r.metadata[tid] = map[string]string{}
+	return nil
+}
+
+func (r *contextRepo) AppendMessageContext(ctx context.Context, tid string, msg assistant.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	messages, ok := r.threads[tid]
+	if !ok {
+		return fmt.Errorf("%w: %s", assistant.ErrThreadNotFound, tid)
+	}
+	msg.ToolCalls = slices.Clone(msg.ToolCalls)
+	r.threads[tid] = append(messages, msg)
+	return nil
+}
+
+func (r *contextRepo) GetMessagesContext(ctx context.Context, tid string) ([]assistant.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	messages, ok := r.threads[tid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", assistant.ErrThreadNotFound, tid)
+	}
+	return slices.Clone(messages), nil
+}
+
+func (r *contextRepo) GetMetadata(tid string, key string) (string, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	metadata, ok := r.metadata[tid]
+	if !ok {
+		return "", false, fmt.Errorf("%w: %s", assistant.ErrThreadNotFound, tid)
+	}
+	value, ok := metadata[key]
+	return value, ok, nil
+}
+
+func (r *contextRepo) SetMetadata(tid string, key string, value string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	metadata, ok := r.metadata[tid]
+	if !ok {
+		return fmt.Errorf("%w: %s", assistant.ErrThreadNotFound, tid)
+	}
+	metadata[key] = value
+	return nil
+}
+
+func TestRunThreadRepositoryTests(t *testing.T) {
+	storagetest.RunThreadRepositoryTests(t, func(t *testing.T) assistant.ThreadRepository {
+		return newContextRepo()
+	})
+}
+
+func TestRunThreadRepositoryTests_FactoryCalls(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+
+	storagetest.RunThreadRepositoryTests(t, func(t *testing.T) assistant.ThreadRepository {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[t.Name()]++
+		return newContextRepo()
+	})
+
+	// every repository belongs to the subtest that uses it
+	for name, n := range calls {
+		if n != 1 {
+			t.Errorf("factory called %d times for %s", n, name)
+		}
+	}
+	for _, sub := range []string{"Metadata", "Context"} {
+		if calls[t.Name()+"/"+sub] != 1 {
+			t.Errorf("subtest %s did not run", sub)
+		}
+	}
+	if calls[t.Name()] != 0 {
+		t.Errorf("factory called %d times outside subtests", calls[t.Name()])
+	}
+}
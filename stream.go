@@ -0,0 +1,81 @@
+package assistant
+
+import (
+	"context"
+	"errors"
+	"iter"
+)
+
+// errStreamStopped is returned from the delta callback when the consumer
+// stops iterating before the stream is finished.
+var errStreamStopped = errors.New("stream stopped by consumer")
+
+// AskStream sends msg to the thread and yields the response content as it is
+// generated. On failure a single ("", err) pair is yielded and iteration ends.
+//
+// The complete assistant reply is appended to the thread only after the
+// stream finishes successfully. Tool calls are executed between rounds as in
+// Ask; only content deltas are yielded. Stopping the iteration early cancels the
+// request and leaves the reply out of the thread.
+//
+// Clients that do not implement StreamHttpClient are called with a regular
+// request and the whole response is yielded as a single delta.
+func (a *Assistant) AskStream(ctx context.Context, tid string, msg string, opts ...RequestOption) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		unlock, err := a.threadLocks.Lock(ctx, tid)
+		if err != nil {
+			yield("", err)
+			return
+		}
+		defer unlock()
+
+		messages, err := a.prepare(ctx, tid, msg)
+		if err != nil {
+			yield("", err)
+			return
+		}
+
+		stopped := false
+		onDelta := func(delta string) error {
+			// yield must not be called again once it returned false, even
+			// by a client that ignores errStreamStopped.
+			if stopped {
+				return errStreamStopped
+			}
+			if !yield(delta, nil) {
+				stopped = true
+				cancel()
+				return errStreamStopped
+			}
+			return nil
+		}
+
+		_, err = a.converse(ctx, tid, messages, a.requestOptions(opts), func(ctx context.Context, msgs []Message, opts RequestOptions) (Message, Usage, error) {
+			return a.requestStream(ctx, msgs, opts, onDelta)
+		})
+		if err != nil && !stopped {
+			yield("", err)
+		}
+	}
+}
+
+func (a *Assistant) requestStream(ctx context.Context, msgs []Message, opts RequestOptions, onDelta func(string) error) (Message, Usage, error) {
+	if c, ok := a.client.(StreamHttpClient); ok {
+		return c.RequestStream(ctx, a.model, msgs, opts, onDelta)
+	}
+
+	response, usage, err := a.request(ctx, msgs, opts)
+	if err != nil {
+		return Message{}, Usage{}, err
+	}
+	if response.Content == "" {
+		return response, usage, nil
+	}
+	if err := onDelta(response.Content); err != nil {
+		return Message{}, Usage{}, err
+	}
+	return response, usage, nil
+}
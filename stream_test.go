@@ -0,0 +1,182 @@
+package assistant
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockStreamHttpClient replays the configured deltas through onDelta
+type MockStreamHttpClient struct {
+	mock.Mock
+	deltas []string
+}
+
+func (c *MockStreamHttpClient) Request(model string, msgs []Message) (Message, Usage, error) {
+	panic("Request must not be called when RequestStream is available")
+}
+
+func (c *MockStreamHttpClient) RequestStream(ctx context.Context, model string, msgs []Message, opts RequestOptions, onDelta func(string) error) (Message, Usage, error) {
+	args := c.Called(model, msgs)
+	for _, d := range c.deltas {
+		if err := onDelta(d); err != nil {
+			return Message{}, Usage{}, err
+		}
+	}
+	return args.Get(0).(Message), args.Get(1).(Usage), args.Error(2)
+}
+
+func collect(t *testing.T, a *Assistant, tid string, msg string) ([]string, error) {
+	t.Helper()
+	var deltas []string
+	for delta, err := range a.AskStream(context.Background(), tid, msg) {
+		if err != nil {
+			return deltas, err
+		}
+		deltas = append(deltas, delta)
+	}
+	return deltas, nil
+}
+
+func TestAskStream_Success(t *testing.T) {
+	tid := "thread-1"
+	expectedRequest := Message{Role: RoleUser, Content: "What is 2+2?"}
+	expectedResponse := Message{Role: RoleAssistant, Content: "2+2=4"}
+	expectedUsage := Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}
+
+	client := &MockStreamHttpClient{deltas: []string{"2+2", "=4"}}
+	threads := &MockThreadRepo{}
+	threads.On("ThreadExists", tid).Return(true, nil)
+	threads.On("AppendMessage", tid, expectedRequest).Return(nil)
+	threads.On("GetMessages", tid).Return([]Message{expectedRequest}, nil)
+	client.On("RequestStream", "gpt-4", []Message{expectedRequest}).Return(expectedResponse, expectedUsage, nil)
+	threads.On("AppendMessage", tid, expectedResponse).Return(nil)
+
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads)
+	deltas, err := collect(t, assistant, tid, "What is 2+2?")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2+2", "=4"}, deltas)
+	assert.Equal(t, expectedUsage, assistant.usage)
+	threads.AssertExpectations(t)
+	client.AssertExpectations(t)
+}
+
+func TestAskStream_FallbackToRequest(t *testing.T) {
+	tid := "thread-1"
+	expectedRequest := Message{Role: RoleUser, Content: "What is 2+2?"}
+	expectedResponse := Message{Role: RoleAssistant, Content: "Mock response"}
+
+	client := &MockHttpClient{}
+	threads := &MockThreadRepo{}
+	threads.On("ThreadExists", tid).Return(true, nil)
+	threads.On("AppendMessage", tid, expectedRequest).Return(nil)
+	threads.On("GetMessages", tid).Return([]Message{expectedRequest}, nil)
+	client.On("Request", "gpt-4", mock.Anything).Return(expectedResponse, Usage{}, nil)
+	threads.On("AppendMessage", tid, expectedResponse).Return(nil)
+
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads)
+	deltas, err := collect(t, assistant, tid, "What is 2+2?")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Mock response"}, deltas)
+	threads.AssertExpectations(t)
+}
+
+func TestAskStream_Error_Request(t *testing.T) {
+	tid := "thread-1"
+	expectedRequest := Message{Role: RoleUser, Content: "What is 2+2?"}
+
+	client := &MockStreamHttpClient{deltas: []string{"2+2"}}
+	threads := &MockThreadRepo{}
+	threads.On("ThreadExists", tid).Return(true, nil)
+	threads.On("AppendMessage", tid, expectedRequest).Return(nil)
+	threads.On("GetMessages", tid).Return([]Message{expectedRequest}, nil)
+	client.On("RequestStream", "gpt-4", mock.Anything).Return(Message{}, Usage{}, errors.New("mock error"))
+
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads)
+	deltas, err := collect(t, assistant, tid, "What is 2+2?")
+
+	assert.EqualError(t, err, "mock error")
+	assert.Equal(t, []string{"2+2"}, deltas)
+	threads.AssertNumberOfCalls(t, "AppendMessage", 1)
+}
+
+func TestAskStream_StopEarly(t *testing.T) {
+	tid := "thread-1"
+	expectedRequest := Message{Role: RoleUser, Content: "What is 2+2?"}
+
+	client := &MockStreamHttpClient{deltas: []string{"2+2", "=4"}}
+	threads := &MockThreadRepo{}
+	threads.On("ThreadExists", tid).Return(true, nil)
+	threads.On("AppendMessage", tid, expectedRequest).Return(nil)
+	threads.On("GetMessages", tid).Return([]Message{expectedRequest}, nil)
+	client.On("RequestStream", "gpt-4", mock.Anything).Return(Message{}, Usage{}, nil)
+
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads)
+	for delta, err := range assistant.AskStream(context.Background(), tid, "What is 2+2?") {
+		assert.NoError(t, err)
+		assert.Equal(t, "2+2", delta)
+		break
+	}
+
+	threads.AssertNumberOfCalls(t, "AppendMessage", 1)
+}
+
+// StubbornStreamHttpClient keeps sending deltas after onDelta fails
+type StubbornStreamHttpClient struct {
+	MockStreamHttpClient
+}
+
+func (c *StubbornStreamHttpClient) RequestStream(ctx context.Context, model string, msgs []Message, opts RequestOptions, onDelta func(string) error) (Message, Usage, error) {
+	args := c.Called(model, msgs)
+	for _, d := range c.deltas {
+		onDelta(d)
+	}
+	return args.Get(0).(Message), args.Get(1).(Usage), args.Error(2)
+}
+
+func TestAskStream_StopEarly_ClientIgnoresStop(t *testing.T) {
+	tid := "thread-1"
+	expectedRequest := Message{Role: RoleUser, Content: "What is 2+2?"}
+
+	client := &StubbornStreamHttpClient{MockStreamHttpClient{deltas: []string{"2+2", "=4", "!"}}}
+	threads := &MockThreadRepo{}
+	threads.On("ThreadExists", tid).Return(true, nil)
+	threads.On("AppendMessage", tid, expectedRequest).Return(nil)
+	threads.On("GetMessages", tid).Return([]Message{expectedRequest}, nil)
+	client.On("RequestStream", "gpt-4", mock.Anything).Return(Message{Role: RoleAssistant, Content: "2+2=4!"}, Usage{}, nil)
+
+	// ranging past a false yield would panic
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads)
+	var deltas []string
+	for delta := range assistant.AskStream(context.Background(), tid, "What is 2+2?") {
+		deltas = append(deltas, delta)
+		break
+	}
+
+	assert.Equal(t, []string{"2+2"}, deltas)
+	threads.AssertNumberOfCalls(t, "AppendMessage", 1)
+}
+
+func TestAskStream_Error_AppendResponseMessage(t *testing.T) {
+	tid := "thread-1"
+	expectedRequest := Message{Role: RoleUser, Content: "What is 2+2?"}
+	expectedResponse := Message{Role: RoleAssistant, Content: "2+2=4"}
+
+	client := &MockStreamHttpClient{deltas: []string{"2+2=4"}}
+	threads := &MockThreadRepo{}
+	threads.On("ThreadExists", tid).Return(true, nil)
+	threads.On("AppendMessage", tid, expectedRequest).Return(nil)
+	threads.On("GetMessages", tid).Return([]Message{expectedRequest}, nil)
+	client.On("RequestStream", "gpt-4", mock.Anything).Return(expectedResponse, Usage{}, nil)
+	threads.On("AppendMessage", tid, expectedResponse).Return(errors.New("mock error"))
+
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads)
+	_, err := collect(t, assistant, tid, "What is 2+2?")
+
+	assert.EqualError(t, err, "mock error")
+}
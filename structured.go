@@ -0,0 +1,258 @@
+package assistant
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultMaxDecodeRetries is the number of times AskInto re-prompts the model
+// after a reply that cannot be decoded, unless changed with
+// SetMaxDecodeRetries.
+const DefaultMaxDecodeRetries = 2
+
+// ResponseFormat constrains the model reply to JSON matching Schema.
+type ResponseFormat struct {
+	Name   string
+	Schema json.RawMessage
+	Strict bool
+}
+
+// Validator may be implemented by AskInto result types to reject replies that
+// decode successfully but are semantically invalid.
+type Validator interface {
+	Validate() error
+}
+
+// SetMaxDecodeRetries limits how many times AskInto re-prompts the model
+// after a reply fails to decode or validate.
+func (a *Assistant) SetMaxDecodeRetries(n int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.maxDecodeRetries = n
+}
+
+// AskInto sends msg to the thread, requesting a reply that matches the JSON
+// Schema derived from T, and decodes the reply into T.
+func AskInto[T any](a *Assistant, tid string, msg string, opts ...RequestOption) (T, error) {
+	return AskIntoContext[T](context.Background(), a, tid, msg, opts...)
+}
+
+// AskIntoContext is like AskInto but bound to ctx. When the reply cannot be
+// decoded into T, or T implements Validator and rejects it, the error is sent
+// back to the model and the request repeated up to the configured number of
+// retries.
+func AskIntoContext[T any](ctx context.Context, a *Assistant, tid string, msg string, opts ...RequestOption) (T, error) {
+	var result T
+
+	format, err := responseFormatFor(reflect.TypeOf(result))
+	if err != nil {
+		return result, err
+	}
+
+	unlock, err := a.threadLocks.Lock(ctx, tid)
+	if err != nil {
+		return result, err
+	}
+	defer unlock()
+
+	messages, err := a.prepare(ctx, tid, msg)
+	if err != nil {
+		return result, err
+	}
+
+	a.mu.RLock()
+	maxRetries := a.maxDecodeRetries
+	a.mu.RUnlock()
+
+	options := a.requestOptions(opts)
+	options.ResponseFormat = format
+
+	for i := 0; ; i++ {
+		response, err := a.converse(ctx, tid, messages, options, a.sender(tid))
+		if err != nil && !errors.Is(err, ErrResponseStateNotStored) {
+			return result, err
+		}
+
+		decodeErr := decodeInto(response.Content, &result)
+		if decodeErr == nil {
+			return result, err
+		}
+		if i >= maxRetries {
+			return result, fmt.Errorf("failed to decode response: %w", decodeErr)
+		}
+
+		retry := fmt.Sprintf("The previous reply was rejected: %s. Reply again with JSON that matches the schema.", decodeErr)
+		if messages, err = a.prepare(ctx, tid, retry); err != nil {
+			return result, err
+		}
+	}
+}
+
+func decodeInto[T any](content string, result *T) error {
+	var v T
+	dec := json.NewDecoder(bytes.NewReader([]byte(content)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return fmt.Errorf("unexpected data after JSON value")
+	}
+	if err := validate(v); err != nil {
+		return err
+	}
+	*result = v
+	return nil
+}
+
+func validate[T any](v T) error {
+	if validator, ok := any(v).(Validator); ok {
+		return validator.Validate()
+	}
+	if validator, ok := any(&v).(Validator); ok {
+		return validator.Validate()
+	}
+	return nil
+}
+
+var schemaNameRe = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+func responseFormatFor(t reflect.Type) (*ResponseFormat, error) {
+	schema, err := JSONSchema(t)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal schema: %w", err)
+	}
+
+	name := schemaNameRe.ReplaceAllString(t.Name(), "_")
+	if name == "" {
+		name = "response"
+	}
+
+	return &ResponseFormat{Name: name, Schema: raw, Strict: true}, nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// JSONSchema derives a JSON Schema for t that satisfies the constraints of
+// OpenAI strict structured outputs: the root is an object, every property is
+// required and additional properties are rejected. Optional values are
+// expressed with pointer fields, which become nullable.
+//
+// Property names follow encoding/json tags and a `description` struct tag is
+// copied into the schema. Maps, interfaces, channels, functions and recursive
+// types are not supported.
+func JSONSchema(t reflect.Type) (map[string]any, error) {
+	if t == nil {
+		return nil, fmt.Errorf("schema: nil type")
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType {
+		return nil, fmt.Errorf("schema: root type %s must be a struct", t)
+	}
+	return schemaFor(t, map[reflect.Type]bool{})
+}
+
+func schemaFor(t reflect.Type, visiting map[reflect.Type]bool) (map[string]any, error) {
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}, nil
+	case reflect.String:
+		return map[string]any{"type": "string"}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := schemaFor(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "array", "items": items}, nil
+	case reflect.Pointer:
+		inner, err := schemaFor(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"anyOf": []any{inner, map[string]any{"type": "null"}}}, nil
+	case reflect.Struct:
+		return structSchema(t, visiting)
+	default:
+		return nil, fmt.Errorf("schema: unsupported type %s", t)
+	}
+}
+
+func structSchema(t reflect.Type, visiting map[reflect.Type]bool) (map[string]any, error) {
+	if visiting[t] {
+		return nil, fmt.Errorf("schema: recursive type %s", t)
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	properties := map[string]any{}
+	required := []string{}
+	if err := addFields(t, visiting, properties, &required); err != nil {
+		return nil, err
+	}
+
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}, nil
+}
+
+func addFields(t reflect.Type, visiting map[reflect.Type]bool, properties map[string]any, required *[]string) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			if err := addFields(f.Type, visiting, properties, required); err != nil {
+				return err
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		schema, err := schemaFor(f.Type, visiting)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		if desc := f.Tag.Get("description"); desc != "" {
+			schema["description"] = desc
+		}
+
+		properties[name] = schema
+		*required = append(*required, name)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package assistant
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type City struct {
+	Name       string  `json:"name" description:"City name"`
+	Population int     `json:"population"`
+	Mayor      *string `json:"mayor"`
+}
+
+type Answer struct {
+	City      City      `json:"city"`
+	Tags      []string  `json:"tags"`
+	Updated   time.Time `json:"updated"`
+	Ignored   string    `json:"-"`
+	unexposed string
+}
+
+type Positive struct {
+	Value int `json:"value"`
+}
+
+func (p Positive) Validate() error {
+	if p.Value <= 0 {
+		return errors.New("value must be positive")
+	}
+	return nil
+}
+
+func TestJSONSchema(t *testing.T) {
+	schema, err := JSONSchema(reflect.TypeOf(Answer{}))
+
+	assert.NoError(t, err)
+	expected := `{
+		"type": "object",
+		"additionalProperties": false,
+		"required": ["city", "tags", "updated"],
+		"properties": {
+			"city": {
+				"type": "object",
+				"additionalProperties": false,
+				"required": ["name", "population", "mayor"],
+				"properties": {
+					"name": {"type": "string", "description": "City name"},
+					"population": {"type": "integer"},
+					"mayor": {"anyOf": [{"type": "string"}, {"type": "null"}]}
+				}
+			},
+			"tags": {"type": "array", "items": {"type": "string"}},
+			"updated": {"type": "string", "format": "date-time"}
+		}
+	}`
+	actual, _ := json.Marshal(schema)
+	assert.JSONEq(t, expected, string(actual))
+}
+
+func TestJSONSchema_Errors(t *testing.T) {
+	type Node struct {
+		Next *Node `json:"next"`
+	}
+	type WithMap struct {
+		Values map[string]int `json:"values"`
+	}
+
+	tests := []struct {
+		name     string
+		typ      reflect.Type
+		expected string
+	}{
+		{"Not A Struct", reflect.TypeOf(""), "schema: root type string must be a struct"},
+		{"Recursive", reflect.TypeOf(Node{}), "recursive type"},
+		{"Map", reflect.TypeOf(WithMap{}), "unsupported type map[string]int"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := JSONSchema(tt.typ)
+			assert.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+func TestAskInto_Success(t *testing.T) {
+	tid := "thread-1"
+	question := Message{Role: RoleUser, Content: "Largest city in France?"}
+	response := Message{Role: RoleAssistant, Content: `{"name": "Paris", "population": 2100000, "mayor": null}`}
+
+	client := &MockContextHttpClient{}
+	threads := &MockThreadRepo{}
+	threads.On("ThreadExists", tid).Return(true, nil)
+	threads.On("AppendMessage", tid, question).Return(nil)
+	threads.On("GetMessages", tid).Return([]Message{question}, nil)
+	client.On("RequestContext", mock.Anything, "gpt-4", []Message{question}, mock.MatchedBy(func(opts RequestOptions) bool {
+		return opts.ResponseFormat != nil && opts.ResponseFormat.Name == "City" && opts.ResponseFormat.Strict
+	})).Return(response, Usage{}, nil)
+	threads.On("AppendMessage", tid, response).Return(nil)
+
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads)
+	city, err := AskInto[City](assistant, tid, "Largest city in France?")
+
+	assert.NoError(t, err)
+	assert.Equal(t, City{Name: "Paris", Population: 2100000}, city)
+	threads.AssertExpectations(t)
+	client.AssertExpectations(t)
+}
+
+func TestAskInto_Retry(t *testing.T) {
+	tid := "thread-1"
+	invalid := Message{Role: RoleAssistant, Content: `{"value": 0}`}
+	valid := Message{Role: RoleAssistant, Content: `{"value": 3}`}
+
+	client := &MockContextHttpClient{}
+	threads := &MockThreadRepo{}
+	threads.On("ThreadExists", tid).Return(true, nil)
+	threads.On("AppendMessage", tid, mock.Anything).Return(nil)
+	threads.On("GetMessages", tid).Return([]Message{}, nil)
+	client.On("RequestContext", mock.Anything, "gpt-4", mock.Anything, mock.Anything).Return(invalid, Usage{}, nil).Once()
+	client.On("RequestContext", mock.Anything, "gpt-4", mock.Anything, mock.Anything).Return(valid, Usage{}, nil).Once()
+
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads)
+	result, err := AskInto[Positive](assistant, tid, "Pick a number")
+
+	assert.NoError(t, err)
+	assert.Equal(t, Positive{Value: 3}, result)
+	threads.AssertCalled(t, "AppendMessage", tid, Message{
+		Role:    RoleUser,
+		Content: "The previous reply was rejected: value must be positive. Reply again with JSON that matches the schema.",
+	})
+	client.AssertExpectations(t)
+}
+
+func TestAskInto_RetriesExhausted(t *testing.T) {
+	tid := "thread-1"
+
+	client := &MockContextHttpClient{}
+	threads := &MockThreadRepo{}
+	threads.On("ThreadExists", tid).Return(true, nil)
+	threads.On("AppendMessage", tid, mock.Anything).Return(nil)
+	threads.On("GetMessages", tid).Return([]Message{}, nil)
+	client.On("RequestContext", mock.Anything, "gpt-4", mock.Anything, mock.Anything).Return(Message{Role: RoleAssistant, Content: "not json"}, Usage{}, nil)
+
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads)
+	assistant.SetMaxDecodeRetries(1)
+	_, err := AskInto[Positive](assistant, tid, "Pick a number")
+
+	assert.ErrorContains(t, err, "failed to decode response")
+	client.AssertNumberOfCalls(t, "RequestContext", 2)
+}
+
+func TestAskInto_Error_Request(t *testing.T) {
+	tid := "thread-1"
+
+	client := &MockContextHttpClient{}
+	threads := &MockThreadRepo{}
+	threads.On("ThreadExists", tid).Return(true, nil)
+	threads.On("AppendMessage", tid, mock.Anything).Return(nil)
+	threads.On("GetMessages", tid).Return([]Message{}, nil)
+	client.On("RequestContext", mock.Anything, "gpt-4", mock.Anything, mock.Anything).Return(Message{}, Usage{}, errors.New("mock error"))
+
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads)
+	_, err := AskInto[Positive](assistant, tid, "Pick a number")
+
+	assert.EqualError(t, err, "mock error")
+}
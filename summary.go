@@ -0,0 +1,187 @@
+package assistant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MetadataSummary is the thread metadata key under which the rolling
+// conversation summary is stored.
+const MetadataSummary = "summary"
+
+// DefaultSummaryPrompt instructs the model how to condense a conversation.
+const DefaultSummaryPrompt = "Summarize the conversation below. Keep facts, decisions, names and open questions that are needed to continue it. Reply with the summary only."
+
+// ErrMetadataUnsupported is returned when a feature needs thread metadata but
+// the repository does not implement ThreadMetadataRepository.
+var ErrMetadataUnsupported = errors.New("thread repository does not support metadata")
+
+// Summarizer condenses the oldest part of a thread once the history sent to
+// the model exceeds Threshold tokens. The summary is kept in thread metadata
+// and sent in place of the messages it covers; stored messages are untouched.
+type Summarizer struct {
+	// Threshold is the token count above which the history is summarized.
+	Threshold int
+	// KeepRecent is the number of most recent messages always sent verbatim.
+	// Values below 1 are treated as 1.
+	KeepRecent int
+	// Counter counts message tokens. EstimateTokens is used when nil.
+	Counter TokenCounter
+	// Prompt is the summarization instruction. DefaultSummaryPrompt is used
+	// when empty.
+	Prompt string
+	// Model overrides the assistant model for summarization requests.
+	Model string
+}
+
+type summary struct {
+	Content string `json:"content"`
+	Through int    `json:"through"`
+}
+
+// SetSummarizer enables rolling summarization. The thread repository must
+// implement ThreadMetadataRepository. A nil summarizer disables it.
+func (a *Assistant) SetSummarizer(s *Summarizer) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.summarizer = s
+}
+
+// summarize replaces the part of messages covered by the stored summary with
+// the summary itself and, when the result is still over the threshold, folds
+// older messages into a new summary.
+func (a *Assistant) summarize(ctx context.Context, tid string, messages []Message) ([]Message, error) {
+	a.mu.RLock()
+	s := a.summarizer
+	a.mu.RUnlock()
+
+	if s == nil {
+		return messages, nil
+	}
+
+	repo, ok := a.threads.(ThreadMetadataRepository)
+	if !ok {
+		return nil, ErrMetadataUnsupported
+	}
+
+	current, err := loadSummary(ctx, repo, tid)
+	if err != nil {
+		return nil, err
+	}
+
+	head, rest := splitSystem(messages)
+	offset := len(head)
+	if current.Through > offset {
+		rest = messages[min(current.Through, len(messages)):]
+	}
+
+	view := summaryView(head, current.Content, rest)
+	if countTokens(view, s.counter()) <= s.Threshold {
+		return view, nil
+	}
+
+	// The newest message is the turn being answered and is always kept.
+	cut := len(rest) - max(s.KeepRecent, 1)
+	for cut > 0 && cut < len(rest) && rest[cut].Role == RoleTool {
+		cut++
+	}
+	if cut <= 0 || cut >= len(rest) {
+		return view, nil
+	}
+
+	content, err := a.requestSummary(ctx, tid, s, current.Content, rest[:cut])
+	if err != nil {
+		return nil, err
+	}
+
+	next := summary{Content: content, Through: len(messages) - len(rest) + cut}
+	if err := storeSummary(ctx, repo, tid, next); err != nil {
+		return nil, err
+	}
+
+	return summaryView(head, next.Content, rest[cut:]), nil
+}
+
+func (a *Assistant) requestSummary(ctx context.Context, tid string, s *Summarizer, previous string, msgs []Message) (string, error) {
+	var b strings.Builder
+	if previous != "" {
+		fmt.Fprintf(&b, "Existing summary:\n%s\n\n", previous)
+	}
+	b.WriteString("Conversation:\n")
+	for _, m := range msgs {
+		content := m.Content
+		for _, call := range m.ToolCalls {
+			content += fmt.Sprintf(" [calls %s(%s)]", call.Function.Name, call.Function.Arguments)
+		}
+		fmt.Fprintf(&b, "%s: %s\n", m.Role, content)
+	}
+
+	prompt := s.Prompt
+	if prompt == "" {
+		prompt = DefaultSummaryPrompt
+	}
+	model := s.Model
+	if model == "" {
+		model = a.model
+	}
+
+	if err := a.checkBudget(tid); err != nil {
+		return "", err
+	}
+
+	response, usage, err := a.requestModel(ctx, model, []Message{
+		{Role: RoleSystem, Content: prompt},
+		{Role: RoleUser, Content: b.String()},
+	}, RequestOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize thread: %w", err)
+	}
+
+	if _, err := a.recordUsage(tid, model, usage); err != nil {
+		return "", err
+	}
+
+	return response.Content, nil
+}
+
+func (s *Summarizer) counter() TokenCounter {
+	if s.Counter == nil {
+		return EstimateTokens
+	}
+	return s.Counter
+}
+
+func summaryView(head []Message, content string, rest []Message) []Message {
+	if content == "" {
+		return join(head, rest)
+	}
+	out := make([]Message, 0, len(head)+len(rest)+1)
+	out = append(out, head...)
+	out = append(out, Message{Role: RoleSystem, Content: "Summary of the earlier conversation:\n" + content})
+	return append(out, rest...)
+}
+
+func loadSummary(ctx context.Context, repo ThreadMetadataRepository, tid string) (summary, error) {
+	value, ok, err := getMetadata(ctx, repo, tid, MetadataSummary)
+	if err != nil || !ok {
+		return summary{}, err
+	}
+
+	var s summary
+	if err := json.Unmarshal([]byte(value), &s); err != nil {
+		return summary{}, fmt.Errorf("failed to decode thread summary: %w", err)
+	}
+	return s, nil
+}
+
+func storeSummary(ctx context.Context, repo ThreadMetadataRepository, tid string, s summary) error {
+	value, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to encode thread summary: %w", err)
+	}
+	return setMetadata(ctx, repo, tid, MetadataSummary, string(value))
+}
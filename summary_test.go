@@ -0,0 +1,195 @@
+package assistant
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockMetadataThreadRepo adds metadata support to MockThreadRepo
+type MockMetadataThreadRepo struct {
+	MockThreadRepo
+}
+
+func (r *MockMetadataThreadRepo) GetMetadata(tid string, key string) (string, bool, error) {
+	args := r.Called(tid, key)
+	return args.String(0), args.Bool(1), args.Error(2)
+}
+
+func (r *MockMetadataThreadRepo) SetMetadata(tid string, key string, value string) error {
+	return r.Called(tid, key, value).Error(0)
+}
+
+type ctxKey struct{}
+
+// MockContextMetadataThreadRepo adds context metadata support to
+// MockMetadataThreadRepo
+type MockContextMetadataThreadRepo struct {
+	MockMetadataThreadRepo
+}
+
+func (r *MockContextMetadataThreadRepo) GetMetadataContext(ctx context.Context, tid string, key string) (string, bool, error) {
+	args := r.Called(ctx, tid, key)
+	return args.String(0), args.Bool(1), args.Error(2)
+}
+
+func (r *MockContextMetadataThreadRepo) SetMetadataContext(ctx context.Context, tid string, key string, value string) error {
+	return r.Called(ctx, tid, key, value).Error(0)
+}
+
+func TestAsk_Summarizer_BelowThreshold(t *testing.T) {
+	tid := "thread-1"
+	response := Message{Role: RoleAssistant, Content: "Mock response"}
+
+	client := &MockContextHttpClient{}
+	threads := &MockMetadataThreadRepo{}
+	threads.On("ThreadExists", tid).Return(true, nil)
+	threads.On("AppendMessage", tid, mock.Anything).Return(nil)
+	threads.On("GetMessages", tid).Return(history, nil)
+	threads.On("GetMetadata", tid, MetadataSummary).Return("", false, nil)
+	client.On("RequestContext", mock.Anything, "gpt-4", history, RequestOptions{}).Return(response, Usage{}, nil)
+
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads)
+	assistant.SetSummarizer(&Summarizer{Threshold: 10, KeepRecent: 2, Counter: unitTokens})
+	_, err := assistant.Ask(tid, "What is 2+2?")
+
+	assert.NoError(t, err)
+	threads.AssertNotCalled(t, "SetMetadata", mock.Anything, mock.Anything, mock.Anything)
+	client.AssertExpectations(t)
+}
+
+func TestAsk_Summarizer_ContextMetadata(t *testing.T) {
+	tid := "thread-1"
+	response := Message{Role: RoleAssistant, Content: "Mock response"}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "request")
+
+	client := &MockContextHttpClient{}
+	threads := &MockContextMetadataThreadRepo{}
+	threads.On("ThreadExists", tid).Return(true, nil)
+	threads.On("AppendMessage", tid, mock.Anything).Return(nil)
+	threads.On("GetMessages", tid).Return(history, nil)
+	threads.On("GetMetadataContext", ctx, tid, MetadataSummary).Return("", false, nil)
+	client.On("RequestContext", ctx, "gpt-4", history, RequestOptions{}).Return(response, Usage{}, nil)
+
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads)
+	assistant.SetSummarizer(&Summarizer{Threshold: 10, KeepRecent: 2, Counter: unitTokens})
+	_, err := assistant.AskContext(ctx, tid, "What is 2+2?")
+
+	assert.NoError(t, err)
+	threads.AssertExpectations(t)
+	threads.AssertNotCalled(t, "GetMetadata", mock.Anything, mock.Anything)
+	client.AssertExpectations(t)
+}
+
+func TestAsk_Summarizer_Summarizes(t *testing.T) {
+	tid := "thread-1"
+	response := Message{Role: RoleAssistant, Content: "Mock response"}
+	summaryMessage := Message{Role: RoleSystem, Content: "Summary of the earlier conversation:\nUser asked about tools."}
+
+	client := &MockContextHttpClient{}
+	threads := &MockMetadataThreadRepo{}
+	threads.On("ThreadExists", tid).Return(true, nil)
+	threads.On("AppendMessage", tid, mock.Anything).Return(nil)
+	threads.On("GetMessages", tid).Return(history, nil)
+	threads.On("GetMetadata", tid, MetadataSummary).Return("", false, nil)
+	client.On("RequestContext", mock.Anything, "gpt-4-mini", []Message{
+		{Role: RoleSystem, Content: DefaultSummaryPrompt},
+		{Role: RoleUser, Content: "Conversation:\nuser: user-1\nassistant:  [calls ()]\ntool: result-1\nassistant: answer-1\n"},
+	}, RequestOptions{}).Return(Message{Role: RoleAssistant, Content: "User asked about tools."}, Usage{}, nil)
+	threads.On("SetMetadata", tid, MetadataSummary, `{"content":"User asked about tools.","through":5}`).Return(nil)
+	client.On("RequestContext", mock.Anything, "gpt-4", []Message{system, summaryMessage, user2, answer2}, RequestOptions{}).Return(response, Usage{}, nil)
+
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads)
+	assistant.SetSummarizer(&Summarizer{Threshold: 5, KeepRecent: 2, Counter: unitTokens, Model: "gpt-4-mini"})
+	_, err := assistant.Ask(tid, "What is 2+2?")
+
+	assert.NoError(t, err)
+	assert.Equal(t, snapshot, history)
+	threads.AssertExpectations(t)
+	client.AssertExpectations(t)
+}
+
+func TestAsk_Summarizer_ZeroKeepRecent(t *testing.T) {
+	tid := "thread-1"
+	response := Message{Role: RoleAssistant, Content: "Mock response"}
+	summaryMessage := Message{Role: RoleSystem, Content: "Summary of the earlier conversation:\nUser asked about tools."}
+
+	client := &MockContextHttpClient{}
+	threads := &MockMetadataThreadRepo{}
+	threads.On("ThreadExists", tid).Return(true, nil)
+	threads.On("AppendMessage", tid, mock.Anything).Return(nil)
+	threads.On("GetMessages", tid).Return(history, nil)
+	threads.On("GetMetadata", tid, MetadataSummary).Return("", false, nil)
+	client.On("RequestContext", mock.Anything, "gpt-4", []Message{
+		{Role: RoleSystem, Content: DefaultSummaryPrompt},
+		{Role: RoleUser, Content: "Conversation:\nuser: user-1\nassistant:  [calls ()]\ntool: result-1\nassistant: answer-1\nuser: user-2\n"},
+	}, RequestOptions{}).Return(Message{Role: RoleAssistant, Content: "User asked about tools."}, Usage{}, nil)
+	threads.On("SetMetadata", tid, MetadataSummary, `{"content":"User asked about tools.","through":6}`).Return(nil)
+	client.On("RequestContext", mock.Anything, "gpt-4", []Message{system, summaryMessage, answer2}, RequestOptions{}).Return(response, Usage{}, nil)
+
+	// the zero value keeps only the newest message
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads)
+	assistant.SetSummarizer(&Summarizer{Threshold: 1, Counter: unitTokens})
+	_, err := assistant.Ask(tid, "What is 2+2?")
+
+	assert.NoError(t, err)
+	threads.AssertExpectations(t)
+	client.AssertExpectations(t)
+}
+
+func TestAsk_Summarizer_UsesStoredSummary(t *testing.T) {
+	tid := "thread-1"
+	response := Message{Role: RoleAssistant, Content: "Mock response"}
+	summaryMessage := Message{Role: RoleSystem, Content: "Summary of the earlier conversation:\nEarlier."}
+
+	client := &MockContextHttpClient{}
+	threads := &MockMetadataThreadRepo{}
+	threads.On("ThreadExists", tid).Return(true, nil)
+	threads.On("AppendMessage", tid, mock.Anything).Return(nil)
+	threads.On("GetMessages", tid).Return(history, nil)
+	threads.On("GetMetadata", tid, MetadataSummary).Return(`{"content":"Earlier.","through":5}`, true, nil)
+	client.On("RequestContext", mock.Anything, "gpt-4", []Message{system, summaryMessage, user2, answer2}, RequestOptions{}).Return(response, Usage{}, nil)
+
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads)
+	assistant.SetSummarizer(&Summarizer{Threshold: 10, KeepRecent: 2, Counter: unitTokens})
+	_, err := assistant.Ask(tid, "What is 2+2?")
+
+	assert.NoError(t, err)
+	client.AssertExpectations(t)
+}
+
+func TestAsk_Summarizer_Errors(t *testing.T) {
+	tid := "thread-1"
+
+	t.Run("Metadata Unsupported", func(t *testing.T) {
+		threads := &MockThreadRepo{}
+		threads.On("ThreadExists", tid).Return(true, nil)
+		threads.On("AppendMessage", tid, mock.Anything).Return(nil)
+		threads.On("GetMessages", tid).Return(history, nil)
+
+		assistant := NewAssistant("gpt-4", "You are a helpful assistant.", &MockContextHttpClient{}, threads)
+		assistant.SetSummarizer(&Summarizer{Threshold: 10})
+		_, err := assistant.Ask(tid, "What is 2+2?")
+
+		assert.ErrorIs(t, err, ErrMetadataUnsupported)
+	})
+
+	t.Run("Summary Request", func(t *testing.T) {
+		client := &MockContextHttpClient{}
+		threads := &MockMetadataThreadRepo{}
+		threads.On("ThreadExists", tid).Return(true, nil)
+		threads.On("AppendMessage", tid, mock.Anything).Return(nil)
+		threads.On("GetMessages", tid).Return(history, nil)
+		threads.On("GetMetadata", tid, MetadataSummary).Return("", false, nil)
+		client.On("RequestContext", mock.Anything, "gpt-4", mock.Anything, mock.Anything).Return(Message{}, Usage{}, errors.New("mock error"))
+
+		assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads)
+		assistant.SetSummarizer(&Summarizer{Threshold: 1, KeepRecent: 2, Counter: unitTokens})
+		_, err := assistant.Ask(tid, "What is 2+2?")
+
+		assert.EqualError(t, err, "failed to summarize thread: mock error")
+	})
+}
@@ -0,0 +1,132 @@
+package tokenizer
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+)
+
+//go:generate go run fetch_ranks.go
+
+const (
+	Cl100kBase = "cl100k_base"
+	O200kBase  = "o200k_base"
+)
+
+// Pre-tokenization patterns of the OpenAI encodings.
+const (
+	Cl100kPattern = `(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+`
+	O200kPattern  = `[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+(?!\S)|\s+`
+)
+
+// ErrUnknownModel is returned when no encoding is known for a model.
+var ErrUnknownModel = errors.New("no encoding known for model")
+
+// ErrEncodingUnavailable is returned when the rank file of an encoding is not
+// embedded.
+var ErrEncodingUnavailable = errors.New("encoding rank file is not available")
+
+//go:embed ranks
+var rankFiles embed.FS
+
+var patterns = map[string]string{
+	Cl100kBase: Cl100kPattern,
+	O200kBase:  O200kPattern,
+}
+
+// modelPrefixes maps model name prefixes to encodings. Longer prefixes are
+// listed before the shorter ones they extend.
+var modelPrefixes = []struct {
+	prefix   string
+	encoding string
+}{
+	{"gpt-4o", O200kBase},
+	{"gpt-4.1", O200kBase},
+	{"gpt-4.5", O200kBase},
+	{"gpt-5", O200kBase},
+	{"chatgpt-4o", O200kBase},
+	{"o1", O200kBase},
+	{"o3", O200kBase},
+	{"o4", O200kBase},
+	{"gpt-4", Cl100kBase},
+	{"gpt-3.5-turbo", Cl100kBase},
+	{"gpt-35-turbo", Cl100kBase},
+	{"text-embedding-3", Cl100kBase},
+	{"text-embedding-ada-002", Cl100kBase},
+}
+
+var (
+	mu        sync.Mutex
+	encodings = map[string]*Encoding{}
+)
+
+// EncodingName returns the name of the encoding used by model.
+func EncodingName(model string) (string, error) {
+	for _, p := range modelPrefixes {
+		if strings.HasPrefix(model, p.prefix) {
+			return p.encoding, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownModel, model)
+}
+
+// ForModel returns the encoding used by model.
+func ForModel(model string) (*Encoding, error) {
+	name, err := EncodingName(model)
+	if err != nil {
+		return nil, err
+	}
+	return Get(name)
+}
+
+// Get returns a registered encoding or loads one of the embedded encodings.
+// Loaded encodings are cached.
+func Get(name string) (*Encoding, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if enc, ok := encodings[name]; ok {
+		return enc, nil
+	}
+
+	pattern, ok := patterns[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown encoding %s", name)
+	}
+
+	f, err := rankFiles.Open("ranks/" + name + ".tiktoken")
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrEncodingUnavailable, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ranks, err := ParseRanks(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", name, err)
+	}
+	enc, err := NewEncoding(name, pattern, ranks)
+	if err != nil {
+		return nil, err
+	}
+
+	encodings[name] = enc
+	return enc, nil
+}
+
+// Register makes enc available under its name, replacing the embedded
+// encoding of the same name. It allows rank files to be loaded from
+// elsewhere when the embedded ones are not wanted.
+func Register(enc *Encoding) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	encodings[enc.name] = enc
+}
@@ -0,0 +1,56 @@
+//go:build ignore
+
+// fetch_ranks downloads the tiktoken rank files embedded by the tokenizer
+// package and verifies their checksums. The files are committed; run it to
+// refresh them.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+const baseURL = "https://openaipublic.blob.core.windows.net/encodings/"
+
+var files = map[string]string{
+	"cl100k_base.tiktoken": "223921b76ee99bde995b7ff738513eef100fb51d18c93597a113bcffe865b2a7",
+	"o200k_base.tiktoken":  "446a9538cb6c348e3516120d7c08b09f57c36495e2acfffe59a5bf8b0cfb1a2d",
+}
+
+func main() {
+	for name, sum := range files {
+		if err := fetch(name, sum); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+func fetch(name string, sum string) error {
+	res, err := http.Get(baseURL + name)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", name, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download %s: status %d", name, res.StatusCode)
+	}
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	hash := sha256.Sum256(data)
+	if hex.EncodeToString(hash[:]) != sum {
+		return fmt.Errorf("checksum mismatch for %s", name)
+	}
+
+	return os.WriteFile(filepath.Join("ranks", name), data, 0o644)
+}
@@ -0,0 +1,54 @@
+package tokenizer
+
+import "github.com/mwazovzky/assistant"
+
+// Per-message overhead of the chat format: every message is wrapped in
+// <|start|>{role}\n{content}<|end|>\n and every reply is primed with
+// <|start|>assistant<|message|>.
+const (
+	tokensPerMessage = 3
+	tokensPerReply   = 3
+)
+
+// CountTokens returns the number of prompt tokens msgs occupy when sent to
+// model, including the chat format overhead, the way OpenAI counts them.
+func CountTokens(model string, msgs []assistant.Message) (int, error) {
+	enc, err := ForModel(model)
+	if err != nil {
+		return 0, err
+	}
+	return enc.CountMessages(msgs), nil
+}
+
+// CountMessages returns the number of prompt tokens of msgs including the
+// chat format overhead.
+func (e *Encoding) CountMessages(msgs []assistant.Message) int {
+	n := tokensPerReply
+	for _, msg := range msgs {
+		n += e.CountMessage(msg)
+	}
+	return n
+}
+
+// CountMessage returns the number of tokens of a single message including its
+// framing. It satisfies assistant.TokenCounter.
+func (e *Encoding) CountMessage(msg assistant.Message) int {
+	n := tokensPerMessage + e.Count(msg.Role) + e.Count(msg.Content)
+	for _, call := range msg.ToolCalls {
+		n += e.Count(call.Function.Name) + e.Count(call.Function.Arguments)
+	}
+	if msg.ToolCallID != "" {
+		n += e.Count(msg.ToolCallID)
+	}
+	return n
+}
+
+// Counter returns an assistant.TokenCounter for model, for use with context
+// strategies and summarization.
+func Counter(model string) (assistant.TokenCounter, error) {
+	enc, err := ForModel(model)
+	if err != nil {
+		return nil, err
+	}
+	return enc.CountMessage, nil
+}
@@ -0,0 +1,49 @@
+package tokenizer_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mwazovzky/assistant"
+	"github.com/mwazovzky/assistant/tokenizer"
+)
+
+func TestCountMessages(t *testing.T) {
+	enc := testEncoding(t)
+
+	msgs := []assistant.Message{
+		{Role: assistant.RoleUser, Content: "hello"},
+		{Role: assistant.RoleAssistant, ToolCalls: []assistant.ToolCall{{Function: assistant.FunctionCall{Name: "hi", Arguments: "hello"}}}},
+		{Role: assistant.RoleTool, Content: "hello", ToolCallID: "ll"},
+	}
+
+	// user: 3 + 4 (u,s,e,r) + 1 (hello)
+	assert.Equal(t, 8, enc.CountMessage(msgs[0]))
+	// assistant: 3 + role + 2 (h,i) + 1 (hello)
+	assert.Equal(t, 3+enc.Count("assistant")+2+1, enc.CountMessage(msgs[1]))
+	// tool: 3 + 4 (t,o,o,l) + 1 (hello) + 1 (ll)
+	assert.Equal(t, 9, enc.CountMessage(msgs[2]))
+	// every reply is primed with 3 tokens
+	assert.Equal(t, 3+8+enc.CountMessage(msgs[1])+9, enc.CountMessages(msgs))
+}
+
+func TestCountTokens(t *testing.T) {
+	msgs := []assistant.Message{
+		{Role: assistant.RoleSystem, Content: "You are a helpful assistant."},
+		{Role: assistant.RoleUser, Content: "hello world"},
+	}
+
+	n, err := tokenizer.CountTokens("gpt-4", msgs)
+
+	// system: 3 + 1 + 6, user: 3 + 1 + 2, reply priming: 3
+	require.NoError(t, err, "rank file missing from tokenizer/ranks")
+	assert.Equal(t, 19, n)
+}
+
+func TestCountTokens_UnknownModel(t *testing.T) {
+	_, err := tokenizer.CountTokens("llama3", nil)
+
+	assert.ErrorIs(t, err, tokenizer.ErrUnknownModel)
+}
@@ -0,0 +1,131 @@
+// Package tokenizer counts tokens offline using tiktoken-compatible byte pair
+// encodings, so prompts can be budgeted and priced before they are sent.
+package tokenizer
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/dlclark/regexp2"
+)
+
+// Encoding is a byte pair encoding defined by a pre-tokenization pattern and
+// the merge ranks of its vocabulary.
+type Encoding struct {
+	name    string
+	pattern *regexp2.Regexp
+	ranks   map[string]int
+}
+
+// NewEncoding creates an encoding from a pre-tokenization pattern and merge
+// ranks, as found in tiktoken definitions.
+func NewEncoding(name string, pattern string, ranks map[string]int) (*Encoding, error) {
+	re, err := regexp2.Compile(pattern, regexp2.None)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile pattern for %s: %w", name, err)
+	}
+	return &Encoding{name: name, pattern: re, ranks: ranks}, nil
+}
+
+// ParseRanks reads a tiktoken rank file: one base64 encoded token and its rank
+// per line.
+func ParseRanks(r io.Reader) (map[string]int, error) {
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		token, rank, ok := strings.Cut(text, " ")
+		if !ok {
+			return nil, fmt.Errorf("invalid rank line %d", line)
+		}
+		b, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token on rank line %d: %w", line, err)
+		}
+		n, err := strconv.Atoi(rank)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rank on line %d: %w", line, err)
+		}
+		ranks[string(b)] = n
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ranks: %w", err)
+	}
+	return ranks, nil
+}
+
+func (e *Encoding) Name() string {
+	return e.name
+}
+
+// Encode returns the token ids of text. Special tokens are encoded as
+// ordinary text.
+func (e *Encoding) Encode(text string) []int {
+	var tokens []int
+	for _, piece := range e.split(text) {
+		if rank, ok := e.ranks[piece]; ok {
+			tokens = append(tokens, rank)
+			continue
+		}
+		tokens = append(tokens, e.merge([]byte(piece))...)
+	}
+	return tokens
+}
+
+// Count returns the number of tokens in text.
+func (e *Encoding) Count(text string) int {
+	return len(e.Encode(text))
+}
+
+func (e *Encoding) split(text string) []string {
+	var pieces []string
+	m, _ := e.pattern.FindStringMatch(text)
+	for m != nil {
+		pieces = append(pieces, m.String())
+		m, _ = e.pattern.FindNextMatch(m)
+	}
+	return pieces
+}
+
+// merge applies byte pair merges to piece, always joining the adjacent pair
+// with the lowest rank, until no ranked pair remains.
+func (e *Encoding) merge(piece []byte) []int {
+	// parts[i] is the start offset of the i-th part; the last entry is len(piece).
+	parts := make([]int, len(piece)+1)
+	for i := range parts {
+		parts[i] = i
+	}
+
+	for len(parts) > 2 {
+		best, at := math.MaxInt, -1
+		for i := 0; i+2 < len(parts); i++ {
+			if rank, ok := e.ranks[string(piece[parts[i]:parts[i+2]])]; ok && rank < best {
+				best, at = rank, i
+			}
+		}
+		if at < 0 {
+			break
+		}
+		parts = append(parts[:at+1], parts[at+2:]...)
+	}
+
+	tokens := make([]int, 0, len(parts)-1)
+	for i := 0; i+1 < len(parts); i++ {
+		rank, ok := e.ranks[string(piece[parts[i]:parts[i+1]])]
+		if !ok {
+			// Vocabularies cover every single byte; an unknown part can only
+			// come from an incomplete rank table.
+			rank = -1
+		}
+		tokens = append(tokens, rank)
+	}
+	return tokens
+}
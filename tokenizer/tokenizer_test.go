@@ -0,0 +1,143 @@
+package tokenizer_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mwazovzky/assistant/tokenizer"
+)
+
+// testRanks builds a rank file with every single byte and a few merges
+func testRanks() string {
+	var b strings.Builder
+	for i := 0; i < 256; i++ {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(i)}), i)
+	}
+	for i, token := range []string{"ll", "he", "hell", "hello", " w", "or", " wor", "ld"} {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(token)), 256+i)
+	}
+	return b.String()
+}
+
+func testEncoding(t *testing.T) *tokenizer.Encoding {
+	t.Helper()
+	ranks, err := tokenizer.ParseRanks(strings.NewReader(testRanks()))
+	require.NoError(t, err)
+	enc, err := tokenizer.NewEncoding("test_base", tokenizer.Cl100kPattern, ranks)
+	require.NoError(t, err)
+	return enc
+}
+
+func TestEncode(t *testing.T) {
+	enc := testEncoding(t)
+
+	tests := []struct {
+		text     string
+		expected []int
+	}{
+		{"", nil},
+		{"hello", []int{259}},
+		{"hello world", []int{259, 262, 263}},
+		{"hi", []int{'h', 'i'}},
+		{"hell's", []int{258, '\'', 's'}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			assert.Equal(t, tt.expected, enc.Encode(tt.text))
+			assert.Equal(t, len(tt.expected), enc.Count(tt.text))
+		})
+	}
+}
+
+func TestParseRanks_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"Missing Rank", "aGk=\n", "invalid rank line 1"},
+		{"Invalid Token", "!!! 1\n", "invalid token on rank line 1"},
+		{"Invalid Rank", "aGk= x\n", "invalid rank on line 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tokenizer.ParseRanks(strings.NewReader(tt.input))
+			assert.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+func TestNewEncoding_InvalidPattern(t *testing.T) {
+	_, err := tokenizer.NewEncoding("broken", "(", nil)
+
+	assert.ErrorContains(t, err, "failed to compile pattern for broken")
+}
+
+func TestEncodingName(t *testing.T) {
+	tests := []struct {
+		model    string
+		expected string
+	}{
+		{"gpt-4o-mini", tokenizer.O200kBase},
+		{"gpt-4.1", tokenizer.O200kBase},
+		{"o3-mini", tokenizer.O200kBase},
+		{"gpt-4", tokenizer.Cl100kBase},
+		{"gpt-4-turbo", tokenizer.Cl100kBase},
+		{"gpt-3.5-turbo", tokenizer.Cl100kBase},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			name, err := tokenizer.EncodingName(tt.model)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, name)
+		})
+	}
+
+	_, err := tokenizer.EncodingName("llama3")
+	assert.ErrorIs(t, err, tokenizer.ErrUnknownModel)
+}
+
+func TestRegister(t *testing.T) {
+	enc := testEncoding(t)
+	tokenizer.Register(enc)
+
+	got, err := tokenizer.Get("test_base")
+
+	assert.NoError(t, err)
+	assert.Same(t, enc, got)
+}
+
+// embeddedEncoding fails the test when the rank file is not embedded
+func embeddedEncoding(t *testing.T, name string) *tokenizer.Encoding {
+	t.Helper()
+	enc, err := tokenizer.Get(name)
+	require.NoError(t, err, "rank file missing from tokenizer/ranks")
+	return enc
+}
+
+func TestKnownCounts(t *testing.T) {
+	tests := []struct {
+		encoding string
+		text     string
+		expected []int
+	}{
+		{tokenizer.Cl100kBase, "hello world", []int{15339, 1917}},
+		{tokenizer.Cl100kBase, "tiktoken is great!", []int{83, 1609, 5963, 374, 2294, 0}},
+		{tokenizer.O200kBase, "hello world", []int{24912, 2375}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.encoding+"/"+tt.text, func(t *testing.T) {
+			enc := embeddedEncoding(t, tt.encoding)
+			assert.Equal(t, tt.expected, enc.Encode(tt.text))
+		})
+	}
+}
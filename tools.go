@@ -0,0 +1,198 @@
+package assistant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// DefaultMaxToolIterations is the number of tool call rounds Ask performs
+// before giving up, unless changed with SetMaxToolIterations.
+const DefaultMaxToolIterations = 10
+
+// ErrMaxToolIterations is returned when the model keeps requesting tool calls
+// after the configured number of rounds.
+var ErrMaxToolIterations = errors.New("maximum tool iterations exceeded")
+
+// ToolCall is a function invocation requested by the model.
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+// FunctionCall holds the function name and its JSON encoded arguments.
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ToolDefinition describes a tool offered to the model. Parameters is a JSON
+// Schema of the function arguments.
+type ToolDefinition struct {
+	Name        string
+	Description string
+	Parameters  json.RawMessage
+}
+
+// ToolFunc executes a tool call. It receives the raw JSON arguments produced
+// by the model and returns the content of the tool message.
+type ToolFunc func(ctx context.Context, arguments string) (string, error)
+
+// Tool is a Go function the model may call while answering.
+type Tool struct {
+	ToolDefinition
+	Func ToolFunc
+}
+
+// RegisterTool makes tool available to the model on subsequent calls. A tool
+// registered under an existing name replaces the previous one.
+func (a *Assistant) RegisterTool(tool Tool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Copy on write: in-flight turns keep iterating the previous slice.
+	tools := make([]Tool, 0, len(a.tools)+1)
+	replaced := false
+	for _, t := range a.tools {
+		if t.Name == tool.Name {
+			t, replaced = tool, true
+		}
+		tools = append(tools, t)
+	}
+	if !replaced {
+		tools = append(tools, tool)
+	}
+	a.tools = tools
+}
+
+// SetMaxToolIterations limits how many rounds of tool calls a single Ask may
+// perform before returning ErrMaxToolIterations.
+func (a *Assistant) SetMaxToolIterations(n int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.maxToolIterations = n
+}
+
+type sendFunc func(ctx context.Context, msgs []Message, opts RequestOptions) (Message, Usage, error)
+
+// converse sends messages to the model, executes the tool calls it requests
+// and repeats until the model replies without tool calls. Every response and
+// tool result is appended to the thread. Usage is summed across rounds. The
+// context strategy trims what is sent, never what is stored.
+//
+// A response requesting tool calls is stored only together with all of its
+// tool results, so the thread never ends in unanswered tool calls, which
+// providers reject on the next request. The final response is returned with
+// an error matching ErrResponseStateNotStored when only saving its
+// server-side state failed.
+func (a *Assistant) converse(ctx context.Context, tid string, messages []Message, opts RequestOptions, send sendFunc) (Message, error) {
+	a.mu.RLock()
+	maxIterations := a.maxToolIterations
+	a.mu.RUnlock()
+
+	total := Usage{}
+	// stateErr reports a reply whose server-side state was not saved. The
+	// reply is still stored and the conversation continues.
+	var stateErr error
+
+	for i := 0; ; i++ {
+		if err := ctx.Err(); err != nil {
+			return Message{}, err
+		}
+		if err := a.checkBudget(tid); err != nil {
+			return Message{}, err
+		}
+
+		response, usage, err := send(ctx, a.trim(messages), opts)
+		stateErr = nil
+		if errors.Is(err, ErrResponseStateNotStored) {
+			stateErr, err = err, nil
+		}
+		if err != nil {
+			return Message{}, err
+		}
+
+		if len(response.ToolCalls) > 0 && i >= maxIterations {
+			usage, err = a.recordUsage(tid, a.model, usage)
+			total = total.Add(usage)
+			total.Model = usage.Model
+			a.setUsage(total)
+			if err != nil {
+				return Message{}, err
+			}
+			return Message{}, ErrMaxToolIterations
+		}
+
+		results := make([]Message, 0, len(response.ToolCalls))
+		for _, call := range response.ToolCalls {
+			results = append(results, Message{Role: RoleTool, Content: a.callTool(ctx, call), ToolCallID: call.ID})
+		}
+
+		// The tools have run, so the round is stored even if ctx has been
+		// cancelled meanwhile; the next request then fails instead.
+		store := ctx
+		if len(results) > 0 {
+			store = context.WithoutCancel(ctx)
+		}
+		for _, msg := range append([]Message{response}, results...) {
+			if err := a.appendMessage(store, tid, msg); err != nil {
+				return Message{}, err
+			}
+		}
+
+		usage, err = a.recordUsage(tid, a.model, usage)
+		total = total.Add(usage)
+		// Add sums the counts only; the turn is reported under the model
+		// that served its last round.
+		total.Model = usage.Model
+		a.setUsage(total)
+		if err != nil {
+			return Message{}, err
+		}
+
+		if len(response.ToolCalls) == 0 {
+			return response, stateErr
+		}
+
+		messages = append(messages, response)
+		messages = append(messages, results...)
+	}
+}
+
+// callTool runs the requested tool. Failures are reported back to the model
+// as the tool result so it can recover.
+func (a *Assistant) callTool(ctx context.Context, call ToolCall) string {
+	a.mu.RLock()
+	tools := a.tools
+	a.mu.RUnlock()
+
+	for _, t := range tools {
+		if t.Name != call.Function.Name {
+			continue
+		}
+		result, err := t.Func(ctx, call.Function.Arguments)
+		if err != nil {
+			return fmt.Sprintf("error: %s", err)
+		}
+		return result
+	}
+	return fmt.Sprintf("error: unknown tool %q", call.Function.Name)
+}
+
+func (a *Assistant) toolDefinitions() []ToolDefinition {
+	a.mu.RLock()
+	tools := a.tools
+	a.mu.RUnlock()
+
+	if len(tools) == 0 {
+		return nil
+	}
+	defs := make([]ToolDefinition, len(tools))
+	for i, t := range tools {
+		defs[i] = t.ToolDefinition
+	}
+	return defs
+}
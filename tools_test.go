@@ -0,0 +1,192 @@
+package assistant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func weatherTool(calls *[]string) Tool {
+	return Tool{
+		ToolDefinition: ToolDefinition{
+			Name:        "get_weather",
+			Description: "Get the current weather in a city",
+			Parameters:  json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}`),
+		},
+		Func: func(ctx context.Context, arguments string) (string, error) {
+			*calls = append(*calls, arguments)
+			return "sunny", nil
+		},
+	}
+}
+
+func toolCallMessage(id string, name string, arguments string) Message {
+	return Message{
+		Role: RoleAssistant,
+		ToolCalls: []ToolCall{
+			{ID: id, Type: "function", Function: FunctionCall{Name: name, Arguments: arguments}},
+		},
+	}
+}
+
+func TestAsk_ToolCalls(t *testing.T) {
+	tid := "thread-1"
+	question := Message{Role: RoleUser, Content: "Weather in Paris?"}
+	call := toolCallMessage("call-1", "get_weather", `{"city":"Paris"}`)
+	result := Message{Role: RoleTool, Content: "sunny", ToolCallID: "call-1"}
+	answer := Message{Role: RoleAssistant, Content: "It is sunny in Paris."}
+
+	var calls []string
+	tool := weatherTool(&calls)
+	opts := RequestOptions{Tools: []ToolDefinition{tool.ToolDefinition}}
+
+	client := &MockContextHttpClient{}
+	threads := &MockThreadRepo{}
+	threads.On("ThreadExists", tid).Return(true, nil)
+	threads.On("AppendMessage", tid, question).Return(nil)
+	threads.On("GetMessages", tid).Return([]Message{question}, nil)
+	client.On("RequestContext", mock.Anything, "gpt-4", []Message{question}, opts).Return(call, Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}, nil).Once()
+	threads.On("AppendMessage", tid, call).Return(nil)
+	threads.On("AppendMessage", tid, result).Return(nil)
+	client.On("RequestContext", mock.Anything, "gpt-4", []Message{question, call, result}, opts).Return(answer, Usage{PromptTokens: 20, CompletionTokens: 5, TotalTokens: 25}, nil).Once()
+	threads.On("AppendMessage", tid, answer).Return(nil)
+
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads)
+	assistant.RegisterTool(tool)
+	response, err := assistant.Ask(tid, "Weather in Paris?")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "It is sunny in Paris.", response)
+	assert.Equal(t, []string{`{"city":"Paris"}`}, calls)
+	assert.Equal(t, Usage{PromptTokens: 30, CompletionTokens: 10, TotalTokens: 40}, assistant.usage)
+	threads.AssertExpectations(t)
+	client.AssertExpectations(t)
+}
+
+func TestAsk_ToolCalls_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		call     Message
+		expected string
+	}{
+		{
+			name:     "Unknown Tool",
+			call:     toolCallMessage("call-1", "get_time", `{}`),
+			expected: `error: unknown tool "get_time"`,
+		},
+		{
+			name:     "Tool Error",
+			call:     toolCallMessage("call-1", "fail", `{}`),
+			expected: "error: mock error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tid := "thread-1"
+			result := Message{Role: RoleTool, Content: tt.expected, ToolCallID: "call-1"}
+			answer := Message{Role: RoleAssistant, Content: "Sorry."}
+
+			client := &MockContextHttpClient{}
+			threads := &MockThreadRepo{}
+			threads.On("ThreadExists", tid).Return(true, nil)
+			threads.On("AppendMessage", tid, mock.Anything).Return(nil)
+			threads.On("GetMessages", tid).Return([]Message{}, nil)
+			client.On("RequestContext", mock.Anything, "gpt-4", []Message{}, mock.Anything).Return(tt.call, Usage{}, nil).Once()
+			client.On("RequestContext", mock.Anything, "gpt-4", []Message{tt.call, result}, mock.Anything).Return(answer, Usage{}, nil).Once()
+
+			assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads)
+			assistant.RegisterTool(Tool{
+				ToolDefinition: ToolDefinition{Name: "fail"},
+				Func: func(ctx context.Context, arguments string) (string, error) {
+					return "", errors.New("mock error")
+				},
+			})
+			response, err := assistant.Ask(tid, "Question")
+
+			assert.NoError(t, err)
+			assert.Equal(t, "Sorry.", response)
+			threads.AssertCalled(t, "AppendMessage", tid, result)
+			client.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAsk_ToolCalls_MaxIterations(t *testing.T) {
+	tid := "thread-1"
+	call := toolCallMessage("call-1", "get_weather", `{"city":"Paris"}`)
+	result := Message{Role: RoleTool, Content: "sunny", ToolCallID: "call-1"}
+
+	var calls []string
+	client := &MockContextHttpClient{}
+	threads := newSyncThreadRepo()
+	client.On("RequestContext", mock.Anything, "gpt-4", mock.Anything, mock.Anything).Return(call, Usage{TotalTokens: 10}, nil)
+
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads)
+	assistant.RegisterTool(weatherTool(&calls))
+	assistant.SetMaxToolIterations(2)
+	_, err := assistant.Ask(tid, "Weather in Paris?")
+
+	assert.ErrorIs(t, err, ErrMaxToolIterations)
+	assert.Len(t, calls, 2)
+	client.AssertNumberOfCalls(t, "RequestContext", 3)
+	assert.Equal(t, 30, assistant.usage.TotalTokens)
+
+	// the unanswered third round of tool calls is not stored
+	messages, err := threads.GetMessages(tid)
+	assert.NoError(t, err)
+	assert.Equal(t, []Message{
+		{Role: RoleSystem, Content: "You are a helpful assistant."},
+		{Role: RoleUser, Content: "Weather in Paris?"},
+		call, result,
+		call, result,
+	}, messages)
+}
+
+func TestAsk_ToolCalls_Cancelled(t *testing.T) {
+	tid := "thread-1"
+	call := toolCallMessage("call-1", "slow", `{}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &MockContextHttpClient{}
+	threads := newSyncThreadRepo()
+	client.On("RequestContext", mock.Anything, "gpt-4", mock.Anything, mock.Anything).Return(call, Usage{}, nil).Once()
+
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads)
+	assistant.RegisterTool(Tool{
+		ToolDefinition: ToolDefinition{Name: "slow"},
+		Func: func(ctx context.Context, arguments string) (string, error) {
+			cancel()
+			return "", ctx.Err()
+		},
+	})
+	_, err := assistant.AskContext(ctx, tid, "Question")
+
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// every stored tool call is followed by its result
+	messages, err := threads.GetMessages(tid)
+	assert.NoError(t, err)
+	assert.Equal(t, []Message{
+		{Role: RoleSystem, Content: "You are a helpful assistant."},
+		{Role: RoleUser, Content: "Question"},
+		call,
+		{Role: RoleTool, Content: "error: context canceled", ToolCallID: "call-1"},
+	}, messages)
+}
+
+func TestRegisterTool_Replace(t *testing.T) {
+	var calls []string
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", &MockHttpClient{}, &MockThreadRepo{})
+	assistant.RegisterTool(weatherTool(&calls))
+	assistant.RegisterTool(Tool{ToolDefinition: ToolDefinition{Name: "get_weather", Description: "v2"}})
+
+	defs := assistant.toolDefinitions()
+
+	assert.Len(t, defs, 1)
+	assert.Equal(t, "v2", defs[0].Description)
+}
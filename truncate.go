@@ -0,0 +1,165 @@
+package assistant
+
+// ContextStrategy selects the messages sent to the model from the full thread
+// history. It must not modify msgs; the thread in storage is never changed.
+type ContextStrategy interface {
+	Trim(msgs []Message) []Message
+}
+
+// ContextStrategyFunc adapts a function to ContextStrategy.
+type ContextStrategyFunc func(msgs []Message) []Message
+
+func (f ContextStrategyFunc) Trim(msgs []Message) []Message {
+	return f(msgs)
+}
+
+// TokenCounter returns the number of tokens msg occupies in the prompt.
+// tokenizer.Counter provides exact counts for OpenAI models.
+type TokenCounter func(msg Message) int
+
+// SetContextStrategy sets the strategy applied to the thread history before
+// every request. A nil strategy sends the full history.
+func (a *Assistant) SetContextStrategy(strategy ContextStrategy) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.strategy = strategy
+}
+
+func (a *Assistant) trim(msgs []Message) []Message {
+	a.mu.RLock()
+	strategy := a.strategy
+	a.mu.RUnlock()
+
+	if strategy == nil {
+		return msgs
+	}
+	return strategy.Trim(msgs)
+}
+
+// EstimateTokens approximates the token count of msg at four characters per
+// token plus a fixed per-message overhead.
+func EstimateTokens(msg Message) int {
+	n := len(msg.Content)
+	for _, call := range msg.ToolCalls {
+		n += len(call.Function.Name) + len(call.Function.Arguments)
+	}
+	return (n+3)/4 + 4
+}
+
+// KeepLastN keeps the leading system message and the last n other messages.
+// A negative n is treated as zero.
+func KeepLastN(n int) ContextStrategy {
+	n = max(n, 0)
+	return ContextStrategyFunc(func(msgs []Message) []Message {
+		head, rest := splitSystem(msgs)
+		if len(rest) > n {
+			rest = rest[len(rest)-n:]
+		}
+		return join(head, alignStart(rest))
+	})
+}
+
+// TokenBudget keeps the leading system message and as many of the most recent
+// messages as fit in budget tokens. A nil counter uses EstimateTokens.
+func TokenBudget(budget int, counter TokenCounter) ContextStrategy {
+	if counter == nil {
+		counter = EstimateTokens
+	}
+	return ContextStrategyFunc(func(msgs []Message) []Message {
+		head, rest := splitSystem(msgs)
+		return join(head, window(rest, budget-countTokens(head, counter), counter))
+	})
+}
+
+// DropToolResultsFirst removes the oldest tool exchanges, an assistant tool
+// call message together with its tool results, until the history fits in
+// budget tokens. If it still does not fit, it falls back to TokenBudget.
+func DropToolResultsFirst(budget int, counter TokenCounter) ContextStrategy {
+	if counter == nil {
+		counter = EstimateTokens
+	}
+	fallback := TokenBudget(budget, counter)
+	return ContextStrategyFunc(func(msgs []Message) []Message {
+		out := msgs
+		for countTokens(out, counter) > budget {
+			next, ok := dropOldestToolExchange(out)
+			if !ok {
+				return fallback.Trim(out)
+			}
+			out = next
+		}
+		return out
+	})
+}
+
+// ChainStrategies applies strategies in order, each to the output of the
+// previous one.
+func ChainStrategies(strategies ...ContextStrategy) ContextStrategy {
+	return ContextStrategyFunc(func(msgs []Message) []Message {
+		for _, s := range strategies {
+			msgs = s.Trim(msgs)
+		}
+		return msgs
+	})
+}
+
+func splitSystem(msgs []Message) ([]Message, []Message) {
+	if len(msgs) > 0 && msgs[0].Role == RoleSystem {
+		return msgs[:1], msgs[1:]
+	}
+	return nil, msgs
+}
+
+func join(head []Message, rest []Message) []Message {
+	out := make([]Message, 0, len(head)+len(rest))
+	out = append(out, head...)
+	return append(out, rest...)
+}
+
+// window returns the longest suffix of msgs that fits in budget tokens.
+func window(msgs []Message, budget int, counter TokenCounter) []Message {
+	start := len(msgs)
+	for start > 0 {
+		cost := counter(msgs[start-1])
+		if cost > budget {
+			break
+		}
+		budget -= cost
+		start--
+	}
+	return alignStart(msgs[start:])
+}
+
+// alignStart drops leading tool results whose tool call message was cut off,
+// since providers reject tool messages without a preceding tool call.
+func alignStart(msgs []Message) []Message {
+	for len(msgs) > 0 && msgs[0].Role == RoleTool {
+		msgs = msgs[1:]
+	}
+	return msgs
+}
+
+func dropOldestToolExchange(msgs []Message) ([]Message, bool) {
+	for i, m := range msgs {
+		if m.Role != RoleAssistant || len(m.ToolCalls) == 0 {
+			continue
+		}
+		j := i + 1
+		for j < len(msgs) && msgs[j].Role == RoleTool {
+			j++
+		}
+		out := make([]Message, 0, len(msgs)-(j-i))
+		out = append(out, msgs[:i]...)
+		return append(out, msgs[j:]...), true
+	}
+	return msgs, false
+}
+
+func countTokens(msgs []Message, counter TokenCounter) int {
+	n := 0
+	for _, m := range msgs {
+		n += counter(m)
+	}
+	return n
+}
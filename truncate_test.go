@@ -0,0 +1,119 @@
+package assistant
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// unitTokens counts every message as one token
+func unitTokens(Message) int {
+	return 1
+}
+
+var (
+	system   = Message{Role: RoleSystem, Content: "system"}
+	user1    = Message{Role: RoleUser, Content: "user-1"}
+	call1    = Message{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "call-1"}}}
+	result1  = Message{Role: RoleTool, Content: "result-1", ToolCallID: "call-1"}
+	answer1  = Message{Role: RoleAssistant, Content: "answer-1"}
+	user2    = Message{Role: RoleUser, Content: "user-2"}
+	answer2  = Message{Role: RoleAssistant, Content: "answer-2"}
+	history  = []Message{system, user1, call1, result1, answer1, user2, answer2}
+	snapshot = append([]Message{}, history...)
+)
+
+func TestKeepLastN(t *testing.T) {
+	tests := []struct {
+		name     string
+		n        int
+		msgs     []Message
+		expected []Message
+	}{
+		{"Short History", 10, history, history},
+		{"Keeps System", 2, history, []Message{system, user2, answer2}},
+		{"Skips Orphan Tool Result", 4, history, []Message{system, answer1, user2, answer2}},
+		{"No System", 1, []Message{user1, answer1}, []Message{answer1}},
+		{"Zero", 0, history, []Message{system}},
+		{"Negative", -1, history, []Message{system}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, KeepLastN(tt.n).Trim(tt.msgs))
+			assert.Equal(t, snapshot, history)
+		})
+	}
+}
+
+func TestTokenBudget(t *testing.T) {
+	tests := []struct {
+		name     string
+		budget   int
+		expected []Message
+	}{
+		{"Fits", 7, history},
+		{"Sliding Window", 4, []Message{system, answer1, user2, answer2}},
+		{"Skips Orphan Tool Result", 5, []Message{system, answer1, user2, answer2}},
+		{"System Only", 1, []Message{system}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, TokenBudget(tt.budget, unitTokens).Trim(history))
+			assert.Equal(t, snapshot, history)
+		})
+	}
+}
+
+func TestDropToolResultsFirst(t *testing.T) {
+	tests := []struct {
+		name     string
+		budget   int
+		expected []Message
+	}{
+		{"Fits", 7, history},
+		{"Drops Tool Exchange", 5, []Message{system, user1, answer1, user2, answer2}},
+		{"Falls Back To Window", 3, []Message{system, user2, answer2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, DropToolResultsFirst(tt.budget, unitTokens).Trim(history))
+			assert.Equal(t, snapshot, history)
+		})
+	}
+}
+
+func TestChainStrategies(t *testing.T) {
+	strategy := ChainStrategies(KeepLastN(5), TokenBudget(3, unitTokens))
+
+	assert.Equal(t, []Message{system, user2, answer2}, strategy.Trim(history))
+}
+
+func TestEstimateTokens(t *testing.T) {
+	assert.Equal(t, 4, EstimateTokens(Message{}))
+	assert.Equal(t, 7, EstimateTokens(Message{Content: "Hello, world"}))
+	assert.Equal(t, 5, EstimateTokens(Message{ToolCalls: []ToolCall{{Function: FunctionCall{Name: "f", Arguments: "{}"}}}}))
+}
+
+func TestAsk_ContextStrategy(t *testing.T) {
+	tid := "thread-1"
+	response := Message{Role: RoleAssistant, Content: "Mock response"}
+
+	client := &MockContextHttpClient{}
+	threads := &MockThreadRepo{}
+	threads.On("ThreadExists", tid).Return(true, nil)
+	threads.On("AppendMessage", tid, mock.Anything).Return(nil)
+	threads.On("GetMessages", tid).Return(history, nil)
+	client.On("RequestContext", mock.Anything, "gpt-4", []Message{system, answer2}, RequestOptions{}).Return(response, Usage{}, nil)
+
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads)
+	assistant.SetContextStrategy(KeepLastN(1))
+	_, err := assistant.Ask(tid, "What is 2+2?")
+
+	assert.NoError(t, err)
+	assert.Equal(t, snapshot, history)
+	client.AssertExpectations(t)
+}
@@ -0,0 +1,238 @@
+package assistant
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Add returns the sum of u and other.
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		TotalTokens:      u.TotalTokens + other.TotalTokens,
+		CachedTokens:     u.CachedTokens + other.CachedTokens,
+		Cost:             u.Cost + other.Cost,
+	}
+}
+
+// UsageSnapshot is a point-in-time copy of accumulated usage.
+type UsageSnapshot struct {
+	Total   Usage            `json:"total"`
+	Threads map[string]Usage `json:"threads"`
+	Models  map[string]Usage `json:"models"`
+}
+
+// UsageStore persists usage totals so they survive restarts.
+type UsageStore interface {
+	Load() (UsageSnapshot, error)
+	Save(snapshot UsageSnapshot) error
+}
+
+// UsageTracker accumulates token usage globally, per thread and per model.
+// It is safe for concurrent use.
+type UsageTracker struct {
+	mu       sync.Mutex
+	store    UsageStore
+	snapshot UsageSnapshot
+	prices   PriceTable
+}
+
+// NewUsageTracker creates a tracker backed by store. Existing totals are
+// loaded from the store; a nil store keeps totals in memory only.
+func NewUsageTracker(store UsageStore) (*UsageTracker, error) {
+	t := &UsageTracker{store: store, snapshot: newUsageSnapshot()}
+	if store == nil {
+		return t, nil
+	}
+
+	snapshot, err := store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load usage: %w", err)
+	}
+	t.snapshot = snapshot.clone()
+	return t, nil
+}
+
+// Record adds usage of a request made in thread tid with model and persists
+// the new totals.
+func (t *UsageTracker) Record(tid string, model string, usage Usage) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.snapshot.Total = t.snapshot.Total.Add(usage)
+	t.snapshot.Threads[tid] = t.snapshot.Threads[tid].Add(usage)
+	t.snapshot.Models[model] = t.snapshot.Models[model].Add(usage)
+
+	if t.store == nil {
+		return nil
+	}
+	if err := t.store.Save(t.snapshot); err != nil {
+		return fmt.Errorf("failed to save usage: %w", err)
+	}
+	return nil
+}
+
+func (t *UsageTracker) Total() Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.snapshot.Total
+}
+
+func (t *UsageTracker) Thread(tid string) Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.snapshot.Threads[tid]
+}
+
+func (t *UsageTracker) Model(model string) Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.snapshot.Models[model]
+}
+
+// Snapshot returns a copy of all accumulated totals.
+func (t *UsageTracker) Snapshot() UsageSnapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.snapshot.clone()
+}
+
+func newUsageSnapshot() UsageSnapshot {
+	return UsageSnapshot{Threads: map[string]Usage{}, Models: map[string]Usage{}}
+}
+
+func (s UsageSnapshot) clone() UsageSnapshot {
+	c := newUsageSnapshot()
+	c.Total = s.Total
+	for k, v := range s.Threads {
+		c.Threads[k] = v
+	}
+	for k, v := range s.Models {
+		c.Models[k] = v
+	}
+	return c
+}
+
+// FileUsageStore keeps usage totals in a JSON file.
+type FileUsageStore struct {
+	path string
+}
+
+func NewFileUsageStore(path string) *FileUsageStore {
+	return &FileUsageStore{path: path}
+}
+
+// Load reads the totals from the file. A missing file yields empty totals.
+func (s *FileUsageStore) Load() (UsageSnapshot, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return newUsageSnapshot(), nil
+	}
+	if err != nil {
+		return UsageSnapshot{}, err
+	}
+
+	var snapshot UsageSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return UsageSnapshot{}, fmt.Errorf("failed to decode usage file: %w", err)
+	}
+	return snapshot, nil
+}
+
+// Save writes the totals to a temporary file in the same directory, syncs it
+// and renames it over the previous one, so a crash leaves either the old or
+// the new totals and never a partially written file. The file holds totals
+// per thread and model, not a log of requests, so its size does not grow
+// with the number of requests.
+func (s *FileUsageStore) Save(snapshot UsageSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode usage: %w", err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write usage file: %w", err)
+	}
+	tmp := f.Name()
+	if err := writeSynced(f, data); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write usage file: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write usage file: %w", err)
+	}
+	return nil
+}
+
+// writeSynced writes data to f, flushes it to disk and closes f.
+func writeSynced(f *os.File, data []byte) error {
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// SetUsageTracker replaces the tracker that accumulates usage of every
+// request, for example with one backed by a persistent store or shared
+// between assistants. A nil tracker resets to a new in-memory tracker.
+func (a *Assistant) SetUsageTracker(tracker *UsageTracker) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if tracker == nil {
+		tracker = &UsageTracker{snapshot: newUsageSnapshot()}
+	}
+	a.tracker = tracker
+}
+
+// GetThreadUsage returns the usage accumulated by thread tid.
+func (a *Assistant) GetThreadUsage(tid string) Usage {
+	return a.usageTracker().Thread(tid)
+}
+
+// GetUsageSnapshot returns the accumulated usage globally, per thread and per
+// model.
+func (a *Assistant) GetUsageSnapshot() UsageSnapshot {
+	return a.usageTracker().Snapshot()
+}
+
+// recordUsage prices usage and adds it to the tracker. It returns the priced
+// usage. A model reported in usage takes precedence over the requested one.
+func (a *Assistant) recordUsage(tid string, model string, usage Usage) (Usage, error) {
+	if usage.Model != "" {
+		model = usage.Model
+	}
+	tracker := a.usageTracker()
+	usage.Cost = tracker.Cost(model, usage)
+	return usage, tracker.Record(tid, model, usage)
+}
+
+func (a *Assistant) usageTracker() *UsageTracker {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.tracker
+}
+
+func (a *Assistant) setUsage(usage Usage) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.usage = usage
+}
@@ -0,0 +1,189 @@
+package assistant
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockUsageStore using testify's mock
+type MockUsageStore struct {
+	mock.Mock
+}
+
+func (s *MockUsageStore) Load() (UsageSnapshot, error) {
+	args := s.Called()
+	return args.Get(0).(UsageSnapshot), args.Error(1)
+}
+
+func (s *MockUsageStore) Save(snapshot UsageSnapshot) error {
+	return s.Called(snapshot).Error(0)
+}
+
+func TestUsageTracker_Record(t *testing.T) {
+	tracker, err := NewUsageTracker(nil)
+	require.NoError(t, err)
+
+	assert.NoError(t, tracker.Record("thread-1", "gpt-4", Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}))
+	assert.NoError(t, tracker.Record("thread-1", "gpt-4o", Usage{PromptTokens: 20, CompletionTokens: 5, TotalTokens: 25}))
+	assert.NoError(t, tracker.Record("thread-2", "gpt-4", Usage{PromptTokens: 1, CompletionTokens: 1, TotalTokens: 2}))
+
+	assert.Equal(t, Usage{PromptTokens: 31, CompletionTokens: 11, TotalTokens: 42}, tracker.Total())
+	assert.Equal(t, Usage{PromptTokens: 30, CompletionTokens: 10, TotalTokens: 40}, tracker.Thread("thread-1"))
+	assert.Equal(t, Usage{PromptTokens: 11, CompletionTokens: 6, TotalTokens: 17}, tracker.Model("gpt-4"))
+	assert.Equal(t, Usage{}, tracker.Thread("unknown"))
+}
+
+func TestUsageTracker_Snapshot_IsCopy(t *testing.T) {
+	tracker, _ := NewUsageTracker(nil)
+	_ = tracker.Record("thread-1", "gpt-4", Usage{TotalTokens: 15})
+
+	snapshot := tracker.Snapshot()
+	snapshot.Threads["thread-1"] = Usage{}
+
+	assert.Equal(t, Usage{TotalTokens: 15}, tracker.Thread("thread-1"))
+}
+
+func TestUsageTracker_Store(t *testing.T) {
+	loaded := UsageSnapshot{
+		Total:   Usage{TotalTokens: 100},
+		Threads: map[string]Usage{"thread-1": {TotalTokens: 100}},
+		Models:  map[string]Usage{"gpt-4": {TotalTokens: 100}},
+	}
+	saved := UsageSnapshot{
+		Total:   Usage{TotalTokens: 115},
+		Threads: map[string]Usage{"thread-1": {TotalTokens: 115}},
+		Models:  map[string]Usage{"gpt-4": {TotalTokens: 115}},
+	}
+
+	store := &MockUsageStore{}
+	store.On("Load").Return(loaded, nil)
+	store.On("Save", saved).Return(nil)
+
+	tracker, err := NewUsageTracker(store)
+	require.NoError(t, err)
+	err = tracker.Record("thread-1", "gpt-4", Usage{TotalTokens: 15})
+
+	assert.NoError(t, err)
+	store.AssertExpectations(t)
+}
+
+func TestUsageTracker_StoreErrors(t *testing.T) {
+	store := &MockUsageStore{}
+	store.On("Load").Return(UsageSnapshot{}, errors.New("mock error")).Once()
+
+	_, err := NewUsageTracker(store)
+	assert.EqualError(t, err, "failed to load usage: mock error")
+
+	store.On("Load").Return(newUsageSnapshot(), nil)
+	store.On("Save", mock.Anything).Return(errors.New("mock error"))
+
+	tracker, err := NewUsageTracker(store)
+	require.NoError(t, err)
+	assert.EqualError(t, tracker.Record("thread-1", "gpt-4", Usage{}), "failed to save usage: mock error")
+}
+
+func TestFileUsageStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+
+	tracker, err := NewUsageTracker(NewFileUsageStore(path))
+	require.NoError(t, err)
+	require.NoError(t, tracker.Record("thread-1", "gpt-4", Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}))
+
+	restored, err := NewUsageTracker(NewFileUsageStore(path))
+	require.NoError(t, err)
+	assert.Equal(t, tracker.Snapshot(), restored.Snapshot())
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	require.NoError(t, os.WriteFile(path, []byte("invalid-json"), 0o644))
+	_, err = NewUsageTracker(NewFileUsageStore(path))
+	assert.ErrorContains(t, err, "failed to decode usage file")
+}
+
+func TestFileUsageStore_SaveFailureKeepsPrevious(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "usage.json")
+	store := NewFileUsageStore(path)
+	snapshot := newUsageSnapshot()
+	snapshot.Total = Usage{TotalTokens: 15}
+	require.NoError(t, store.Save(snapshot))
+
+	// a directory in place of the temporary file's target makes the rename fail
+	require.NoError(t, os.Remove(path))
+	require.NoError(t, os.Mkdir(path, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(path, "keep"), nil, 0o644))
+
+	assert.Error(t, store.Save(snapshot))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary file removed after a failed save")
+}
+
+func TestSetUsageTracker_Nil(t *testing.T) {
+	client := &MockHttpClient{}
+	threads := &MockThreadRepo{}
+	threads.On("ThreadExists", "thread-1").Return(true, nil)
+	threads.On("AppendMessage", "thread-1", mock.Anything).Return(nil)
+	threads.On("GetMessages", "thread-1").Return([]Message{}, nil)
+	client.On("Request", "gpt-4", mock.Anything).Return(Message{Role: RoleAssistant}, Usage{TotalTokens: 15}, nil)
+
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads)
+	assistant.SetBudget(Budget{Total: 1})
+	assistant.SetUsageTracker(nil)
+
+	_, err := assistant.Ask("thread-1", "Question")
+
+	require.NoError(t, err)
+	assert.Equal(t, 15, assistant.GetThreadUsage("thread-1").TotalTokens)
+}
+
+func TestAsk_RecordsUsage(t *testing.T) {
+	response := Message{Role: RoleAssistant, Content: "Mock response"}
+
+	client := &MockHttpClient{}
+	threads := &MockThreadRepo{}
+	threads.On("ThreadExists", mock.Anything).Return(true, nil)
+	threads.On("AppendMessage", mock.Anything, mock.Anything).Return(nil)
+	threads.On("GetMessages", mock.Anything).Return([]Message{}, nil)
+	client.On("Request", "gpt-4", mock.Anything).Return(response, Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}, nil)
+
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads)
+	_, _ = assistant.Ask("thread-1", "Question")
+	_, _ = assistant.Ask("thread-1", "Question")
+	_, _ = assistant.Ask("thread-2", "Question")
+
+	assert.Equal(t, Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}, assistant.GetUsage())
+	assert.Equal(t, Usage{PromptTokens: 20, CompletionTokens: 10, TotalTokens: 30}, assistant.GetThreadUsage("thread-1"))
+	snapshot := assistant.GetUsageSnapshot()
+	assert.Equal(t, Usage{PromptTokens: 30, CompletionTokens: 15, TotalTokens: 45}, snapshot.Total)
+	assert.Equal(t, Usage{PromptTokens: 30, CompletionTokens: 15, TotalTokens: 45}, snapshot.Models["gpt-4"])
+}
+
+func TestAsk_Error_RecordUsage(t *testing.T) {
+	store := &MockUsageStore{}
+	store.On("Load").Return(newUsageSnapshot(), nil)
+	store.On("Save", mock.Anything).Return(errors.New("mock error"))
+	tracker, _ := NewUsageTracker(store)
+
+	client := &MockHttpClient{}
+	threads := &MockThreadRepo{}
+	threads.On("ThreadExists", mock.Anything).Return(true, nil)
+	threads.On("AppendMessage", mock.Anything, mock.Anything).Return(nil)
+	threads.On("GetMessages", mock.Anything).Return([]Message{}, nil)
+	client.On("Request", "gpt-4", mock.Anything).Return(Message{Role: RoleAssistant}, Usage{}, nil)
+
+	assistant := NewAssistant("gpt-4", "You are a helpful assistant.", client, threads)
+	assistant.SetUsageTracker(tracker)
+	_, err := assistant.Ask("thread-1", "Question")
+
+	assert.EqualError(t, err, "failed to save usage: mock error")
+}